@@ -0,0 +1,257 @@
+/*
+ * // Copyright 2025 getDragon-dev
+ * // Licensed under the Apache License, Version 2.0 (the "License");
+ * // you may not use this file except in compliance with the License.
+ * // You may obtain a copy of the License at http://www.apache.org/licenses/LICENSE-2.0
+ * // Unless required by applicable law or agreed to in writing, software
+ * // distributed under the License is distributed on an "AS IS" BASIS,
+ * // WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * // See the License for the specific language governing permissions and
+ * // limitations under the License.
+ */
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// Credential is what `dragon login` stores for a registry.
+type Credential struct {
+	Host     string `json:"host"`
+	Token    string `json:"token,omitempty"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+}
+
+func credentialsPath() string { return filepath.Join(configDir(), "credentials.json") }
+
+func readCredentials() (map[string]Credential, error) {
+	b, err := os.ReadFile(credentialsPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return map[string]Credential{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	creds := map[string]Credential{}
+	if err := json.Unmarshal(b, &creds); err != nil {
+		return nil, err
+	}
+	return creds, nil
+}
+
+func writeCredentials(creds map[string]Credential) error {
+	if err := os.MkdirAll(configDir(), 0o755); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(creds, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(credentialsPath(), b, 0o600); err != nil {
+		return err
+	}
+	resetStoredCredentials()
+	return nil
+}
+
+// stored holds what applyAuth matches requests against. It is read once per
+// credentials file; writeCredentials drops it.
+var stored struct {
+	sync.Mutex
+	path  string
+	creds map[string]Credential
+}
+
+func storedCredentials() map[string]Credential {
+	p := credentialsPath()
+	stored.Lock()
+	defer stored.Unlock()
+	if stored.path != p {
+		stored.path, stored.creds = p, loadStoredCredentials()
+	}
+	return stored.creds
+}
+
+func resetStoredCredentials() {
+	stored.Lock()
+	stored.path = ""
+	stored.Unlock()
+}
+
+func loadStoredCredentials() map[string]Credential {
+	creds, err := readCredentials()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: ignoring %s: %v\n", credentialsPath(), err)
+	}
+	return creds
+}
+
+func hostOf(loc string) string {
+	u, err := url.Parse(loc)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
+
+type authRegistryKey struct{}
+
+// withRegistryAuth makes applyAuth consider r, ahead of the configured
+// registries, for requests made under ctx. It lets a registry be probed with
+// its own auth before it is saved to the config.
+func withRegistryAuth(ctx context.Context, r Registry) context.Context {
+	return context.WithValue(ctx, authRegistryKey{}, r)
+}
+
+// applyAuth adds credentials to req when its host belongs to a configured
+// registry. Stored logins win over credentials referenced from the config,
+// and ~/.netrc is consulted last. Registry indexes and bundle downloads go
+// through here, so assets hosted next to a private index are covered too.
+func applyAuth(req *http.Request) {
+	if req.Header.Get("Authorization") != "" {
+		return
+	}
+	host := req.URL.Host
+	creds := storedCredentials()
+	// A broken config has already failed the command that loaded it.
+	var regs []Registry
+	if m, err := loadEffectiveConfig(); err == nil {
+		regs = m.cfg.Registries
+	}
+	if r, ok := req.Context().Value(authRegistryKey{}).(Registry); ok {
+		regs = append([]Registry{r}, regs...)
+	}
+	for _, r := range regs {
+		if hostOf(r.URL) != host {
+			continue
+		}
+		if c, ok := creds[r.Name]; ok && (c.Host == "" || c.Host == host) {
+			if setCredential(req, c) {
+				return
+			}
+		}
+		if r.Auth != nil && setCredential(req, r.Auth.resolve()) {
+			return
+		}
+	}
+	for _, c := range creds {
+		if c.Host == host && setCredential(req, c) {
+			return
+		}
+	}
+	if login, password, ok := netrcLookup(req.URL.Hostname()); ok {
+		req.SetBasicAuth(login, password)
+	}
+}
+
+func setCredential(req *http.Request, c Credential) bool {
+	switch {
+	case c.Token != "":
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+		return true
+	case c.Username != "":
+		req.SetBasicAuth(c.Username, c.Password)
+		return true
+	}
+	return false
+}
+
+// resolve turns the env var references of a configured auth block into a
+// concrete credential.
+func (a RegistryAuth) resolve() Credential {
+	c := Credential{Username: a.Username}
+	if a.TokenEnv != "" {
+		c.Token = os.Getenv(a.TokenEnv)
+	}
+	if a.PasswordEnv != "" {
+		c.Password = os.Getenv(a.PasswordEnv)
+	}
+	if a.Type == "basic" {
+		c.Token = ""
+	}
+	return c
+}
+
+func netrcPath() string {
+	if p := os.Getenv("NETRC"); p != "" {
+		return p
+	}
+	name := ".netrc"
+	if runtime.GOOS == "windows" {
+		name = "_netrc"
+	}
+	return filepath.Join(os.Getenv("HOME"), name)
+}
+
+// netrcLookup returns the login and password for host from the user's netrc
+// file, falling back to its "default" entry.
+func netrcLookup(host string) (string, string, bool) {
+	b, err := os.ReadFile(netrcPath())
+	if err != nil {
+		return "", "", false
+	}
+	fields := strings.Fields(string(b))
+	var login, password string
+	var defLogin, defPassword string
+	found, inDefault, matched := false, false, false
+	for i := 0; i < len(fields); i++ {
+		switch fields[i] {
+		case "machine":
+			if matched {
+				return login, password, true
+			}
+			inDefault = false
+			if i+1 < len(fields) {
+				i++
+				matched = fields[i] == host
+			}
+		case "default":
+			if matched {
+				return login, password, true
+			}
+			matched, inDefault = false, true
+		case "login", "password", "account":
+			if i+1 >= len(fields) {
+				break
+			}
+			key, val := fields[i], fields[i+1]
+			i++
+			switch {
+			case matched && key == "login":
+				login = val
+			case matched && key == "password":
+				password = val
+			case inDefault && key == "login":
+				defLogin, found = val, true
+			case inDefault && key == "password":
+				defPassword, found = val, true
+			}
+		case "macdef":
+			// Macro bodies run until a blank line, which Fields has already
+			// collapsed; nothing after a macdef can be trusted.
+			if matched {
+				return login, password, true
+			}
+			if found {
+				return defLogin, defPassword, true
+			}
+			return "", "", false
+		}
+	}
+	if matched {
+		return login, password, true
+	}
+	return defLogin, defPassword, found
+}
@@ -0,0 +1,205 @@
+/*
+ * // Copyright 2025 getDragon-dev
+ * // Licensed under the Apache License, Version 2.0 (the "License");
+ * // you may not use this file except in compliance with the License.
+ * // You may obtain a copy of the License at http://www.apache.org/licenses/LICENSE-2.0
+ * // Unless required by applicable law or agreed to in writing, software
+ * // distributed under the License is distributed on an "AS IS" BASIS,
+ * // WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * // See the License for the specific language governing permissions and
+ * // limitations under the License.
+ */
+
+package cmd
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// authSetup points the config, credentials and netrc at a temp directory.
+func authSetup(t *testing.T, config string, creds map[string]Credential, netrc string) {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(dir, "config"))
+	t.Setenv("NETRC", filepath.Join(dir, "netrc"))
+	t.Chdir(dir)
+	if err := os.MkdirAll(configDir(), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(configPath(), []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if creds != nil {
+		if err := writeCredentials(creds); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if netrc != "" {
+		if err := os.WriteFile(filepath.Join(dir, "netrc"), []byte(netrc), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func authHeader(t *testing.T, ctx context.Context, loc string) string {
+	t.Helper()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, loc, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	applyAuth(req)
+	return req.Header.Get("Authorization")
+}
+
+func TestApplyAuthFollowsHost(t *testing.T) {
+	t.Setenv("PRIVATE_TOKEN", "private-token")
+	t.Setenv("TEAM_PASSWORD", "team-pass")
+	authSetup(t, `{"registries": [
+  {"name": "private", "url": "https://private.example/registry.json",
+   "auth": {"type": "bearer", "token_env": "PRIVATE_TOKEN"}},
+  {"name": "team", "url": "https://team.example:8443/registry.json",
+   "auth": {"type": "basic", "username": "dev", "password_env": "TEAM_PASSWORD"}},
+  {"name": "public", "url": "https://public.example/registry.json"},
+  {"name": "logged-in", "url": "https://login.example/registry.json",
+   "auth": {"type": "bearer", "token_env": "PRIVATE_TOKEN"}}
+]}`, map[string]Credential{
+		"logged-in": {Host: "login.example", Token: "stored"},
+		"moved":     {Host: "elsewhere.example", Token: "stale"},
+	}, "")
+
+	ctx := context.Background()
+	probe := withRegistryAuth(ctx, Registry{Name: "new", URL: "https://new.example/r.json", Auth: &RegistryAuth{TokenEnv: "PRIVATE_TOKEN"}})
+	tests := []struct {
+		name string
+		ctx  context.Context
+		loc  string
+		want string
+	}{
+		{"configured token", ctx, "https://private.example/bundles/a.zip", "Bearer private-token"},
+		{"basic auth", ctx, "https://team.example:8443/a.zip", "Basic ZGV2OnRlYW0tcGFzcw=="},
+		{"other port", ctx, "https://team.example/a.zip", ""},
+		{"public registry", ctx, "https://public.example/registry.json", ""},
+		{"unrelated host", ctx, "https://cdn.example/a.zip", ""},
+		{"lookalike host", ctx, "https://private.example.evil/a.zip", ""},
+		{"stored login wins", ctx, "https://login.example/registry.json", "Bearer stored"},
+		{"login by host", ctx, "https://elsewhere.example/x", "Bearer stale"},
+		{"probed registry", probe, "https://new.example/r.json", "Bearer private-token"},
+		{"probe stays scoped", probe, "https://public.example/r.json", ""},
+	}
+	for _, tt := range tests {
+		if got := authHeader(t, tt.ctx, tt.loc); got != tt.want {
+			t.Errorf("%s: Authorization = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestApplyAuthRedirect(t *testing.T) {
+	t.Setenv("PRIVATE_TOKEN", "private-token")
+	var cdnAuth []string
+	cdn := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cdnAuth = append(cdnAuth, r.Header.Get("Authorization"))
+		_, _ = w.Write([]byte("bundle"))
+	}))
+	defer cdn.Close()
+	// The registry and the CDN differ by hostname, as they would in
+	// practice; Go only forwards Authorization within the same host.
+	cdnURL := strings.Replace(cdn.URL, "127.0.0.1", "localhost", 1)
+	reg := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer private-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		http.Redirect(w, r, cdnURL+"/a.zip", http.StatusFound)
+	}))
+	defer reg.Close()
+	authSetup(t, `{"registries": [{"name": "private", "url": "`+reg.URL+`/registry.json", "auth": {"type": "bearer", "token_env": "PRIVATE_TOKEN"}}]}`, nil, "")
+
+	req, err := http.NewRequest(http.MethodGet, reg.URL+"/bundles/a.zip", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	applyAuth(req)
+	resp, err := doHTTP(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status %d", resp.StatusCode)
+	}
+	if len(cdnAuth) != 1 || cdnAuth[0] != "" {
+		t.Errorf("CDN saw Authorization %q", cdnAuth)
+	}
+}
+
+func TestNetrcLookup(t *testing.T) {
+	const netrc = `machine a.example login alice password apass
+machine b.example
+  login bob
+  password bpass
+default login anon password anonpass
+`
+	tests := []struct {
+		name, netrc, host string
+		login, password   string
+		ok                bool
+	}{
+		{"first machine", netrc, "a.example", "alice", "apass", true},
+		{"multi-line machine", netrc, "b.example", "bob", "bpass", true},
+		{"default", netrc, "c.example", "anon", "anonpass", true},
+		{"no default", "machine a.example login alice password apass\n", "c.example", "", "", false},
+		{"default before machine", "default login anon password anonpass\nmachine a.example login alice password apass\n", "a.example", "alice", "apass", true},
+		{"prefix is not a match", netrc, "a.example.org", "anon", "anonpass", true},
+		{"macdef ends parsing", "machine a.example login alice password apass\nmacdef init\ncd /\n\nmachine b.example login bob password bpass\n", "b.example", "", "", false},
+		{"macdef after match", "machine a.example login alice password apass macdef x y\n", "a.example", "alice", "apass", true},
+		{"account ignored", "machine a.example account acct login alice password apass\n", "a.example", "alice", "apass", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := filepath.Join(t.TempDir(), "netrc")
+			if err := os.WriteFile(p, []byte(tt.netrc), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			t.Setenv("NETRC", p)
+			login, password, ok := netrcLookup(tt.host)
+			if login != tt.login || password != tt.password || ok != tt.ok {
+				t.Errorf("netrcLookup(%q) = %q, %q, %v; want %q, %q, %v", tt.host, login, password, ok, tt.login, tt.password, tt.ok)
+			}
+		})
+	}
+
+	t.Setenv("NETRC", filepath.Join(t.TempDir(), "missing"))
+	if _, _, ok := netrcLookup("a.example"); ok {
+		t.Error("found credentials without a netrc file")
+	}
+}
+
+func TestWriteCredentialsMode(t *testing.T) {
+	authSetup(t, "{}", nil, "")
+	if err := os.MkdirAll(configDir(), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(credentialsPath(), []byte("{}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeCredentials(map[string]Credential{"private": {Token: "secret"}}); err != nil {
+		t.Fatal(err)
+	}
+	fi, err := os.Stat(credentialsPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runtime.GOOS != "windows" && fi.Mode().Perm() != 0o600 {
+		t.Errorf("mode = %v, want 0600", fi.Mode().Perm())
+	}
+	if c := storedCredentials()["private"]; c.Token != "secret" {
+		t.Errorf("stored credentials not reloaded after the write: %+v", c)
+	}
+}
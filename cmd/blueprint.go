@@ -0,0 +1,75 @@
+/*
+ * // Copyright 2025 getDragon-dev
+ * // Licensed under the Apache License, Version 2.0 (the "License");
+ * // you may not use this file except in compliance with the License.
+ * // You may obtain a copy of the License at http://www.apache.org/licenses/LICENSE-2.0
+ * // Unless required by applicable law or agreed to in writing, software
+ * // distributed under the License is distributed on an "AS IS" BASIS,
+ * // WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * // See the License for the specific language governing permissions and
+ * // limitations under the License.
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var blueprintCmd = &cobra.Command{Use: "blueprint", Short: "Work with blueprint bundles"}
+
+var blueprintPushCmd = &cobra.Command{Use: "push <bundle> <oci-ref>", Args: cobra.ExactArgs(2), Short: "Push a bundle (or a registry.json) as an OCI artifact",
+	Long: `Push a bundle (zip, tar, tar.gz or tar.zst) to an OCI registry or an
+on-disk image layout, e.g.
+
+  dragon blueprint push dist/api-service-1.2.0.zip oci://registry.example.com/blueprints/api-service:1.2.0
+  dragon blueprint push dist/api-service-1.2.0.zip oci-layout://./layout:api-service-1.2.0
+
+A .json file is pushed as a registry index instead, so a whole registry can
+be served from oci:// as well.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ref, err := parseOCIRef(args[1])
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(args[0])
+		if err != nil {
+			return err
+		}
+		artifactType, layerType := ociBlueprintType, ociBundleLayerType
+		if strings.EqualFold(filepath.Ext(args[0]), ".json") {
+			artifactType, layerType = ociRegistryType, ociIndexLayerType
+		} else {
+			format, err := detectFormat(bytes.NewReader(data), "", args[0])
+			if err != nil {
+				return err
+			}
+			layerType = map[bundleFormat]string{
+				formatZip:    ociBundleLayerType,
+				formatTar:    ociBundleTarType,
+				formatTarGz:  ociBundleTarType + "+gzip",
+				formatTarZst: ociBundleTarType + "+zstd",
+			}[format]
+		}
+		d, err := pushOCI(context.Background(), ref, artifactType, layerType, filepath.Base(args[0]), data)
+		if err != nil {
+			return err
+		}
+		pinned := ref
+		pinned.Tag, pinned.Digest = "", d.Digest
+		fmt.Printf("Pushed %s\n  pinned: %s\n  bundle digest: %s (%d bytes)\n", ref, pinned, sha256Digest(data), len(data))
+		return nil
+	},
+}
+
+func init() {
+	blueprintCmd.AddCommand(blueprintPushCmd)
+	rootCmd.AddCommand(blueprintCmd)
+}
@@ -0,0 +1,239 @@
+/*
+ * // Copyright 2025 getDragon-dev
+ * // Licensed under the Apache License, Version 2.0 (the "License");
+ * // you may not use this file except in compliance with the License.
+ * // You may obtain a copy of the License at http://www.apache.org/licenses/LICENSE-2.0
+ * // Unless required by applicable law or agreed to in writing, software
+ * // distributed under the License is distributed on an "AS IS" BASIS,
+ * // WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * // See the License for the specific language governing permissions and
+ * // limitations under the License.
+ */
+
+package cmd
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	corereg "github.com/getDragon-dev/dragon-core/registry"
+	"github.com/spf13/cobra"
+)
+
+var (
+	buildDir     string
+	buildOut     string
+	buildBaseURL string
+	buildRepo    string
+)
+
+// zipEpoch is stamped on every archive entry so that rebuilding an unchanged
+// blueprint yields a byte-identical bundle and digest.
+var zipEpoch = time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+
+var registryBuildCmd = &cobra.Command{Use: "build", Short: "Build bundles and a registry.json from a blueprints tree", RunE: func(cmd *cobra.Command, args []string) error {
+	if buildBaseURL == "" {
+		return errors.New("--base-url required")
+	}
+	if err := os.MkdirAll(buildOut, 0o755); err != nil {
+		return err
+	}
+	indexPath := filepath.Join(buildOut, "registry.json")
+	previous := registrySet{Extras: map[string]indexEntry{}}
+	if data, err := os.ReadFile(indexPath); err == nil {
+		if err := json.Unmarshal(data, &previous.DB); err != nil {
+			return fmt.Errorf("%s: %w", indexPath, err)
+		}
+		if previous.Extras, err = decodeIndexExtras(data); err != nil {
+			return fmt.Errorf("%s: %w", indexPath, err)
+		}
+	}
+
+	entries := map[string]map[string]json.RawMessage{}
+	seen := map[string]string{}
+	err := filepath.WalkDir(buildDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if d.Name() == "template" || (strings.HasPrefix(d.Name(), ".") && p != buildDir) {
+			return filepath.SkipDir
+		}
+		manifest := filepath.Join(p, "manifest.yaml")
+		if _, err := os.Stat(manifest); err != nil {
+			return nil
+		}
+		m, err := readManifest(manifest)
+		if err != nil {
+			return fmt.Errorf("%s: %w", manifest, err)
+		}
+		if other, dup := seen[m.Name]; dup {
+			return fmt.Errorf("blueprint %q is defined in both %s and %s", m.Name, other, p)
+		}
+		seen[m.Name] = p
+		e, err := buildBlueprint(p, m, previous)
+		if err != nil {
+			return fmt.Errorf("%s: %w", p, err)
+		}
+		entries[m.Name] = e
+		fmt.Printf("- %s (%s)\n", m.Name, m.Version)
+		return filepath.SkipDir
+	})
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(entries))
+	for n := range entries {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	list := make([]map[string]json.RawMessage, 0, len(names))
+	for _, n := range names {
+		list = append(list, entries[n])
+	}
+	data, err := json.MarshalIndent(map[string]any{"blueprints": list}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(indexPath, append(data, '\n'), 0o644); err != nil {
+		return err
+	}
+	fmt.Printf("Wrote %s with %d blueprints\n", indexPath, len(entries))
+	return nil
+}}
+
+// buildBlueprint packages dir/template and returns its registry entry.
+// Releases already listed in the previous index are carried over into the
+// entry's version history.
+func buildBlueprint(dir string, m vmanifest, previous registrySet) (map[string]json.RawMessage, error) {
+	tpl := filepath.Join(dir, "template")
+	if fi, err := os.Stat(tpl); err != nil || !fi.IsDir() {
+		return nil, errors.New("no template/ directory")
+	}
+	bundle, err := zipBlueprint(dir)
+	if err != nil {
+		return nil, err
+	}
+	file := m.Name + "-" + m.Version + ".zip"
+	if err := os.WriteFile(filepath.Join(buildOut, file), bundle, 0o644); err != nil {
+		return nil, err
+	}
+	rel, err := filepath.Rel(buildDir, dir)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(bundle)
+	bp := corereg.Blueprint{
+		Name:        m.Name,
+		Version:     m.Version,
+		Description: m.Description,
+		Tags:        m.Tags,
+		DownloadURL: strings.TrimSuffix(buildBaseURL, "/") + "/" + file,
+		Repo:        buildRepo,
+		Path:        filepath.ToSlash(rel),
+	}
+	e, err := jsonFields(bp)
+	if err != nil {
+		return nil, err
+	}
+	extra := indexEntry{Digest: "sha256:" + hex.EncodeToString(sum[:]), Size: int64(len(bundle)), Deprecated: m.Deprecated}
+	if old, err := corereg.Find(previous.DB, m.Name); err == nil {
+		for _, r := range previous.releases(*old) {
+			if r.Version != m.Version {
+				v := indexVersion{Version: r.Version, DownloadURL: r.DownloadURL, Digest: r.Digest, Size: r.Size, Yanked: r.Yanked}
+				// Keep per-release deprecations; the entry-wide one now
+				// comes from the manifest.
+				if r.Deprecated != previous.Extras[m.Name].Deprecated {
+					v.Deprecated = r.Deprecated
+				}
+				extra.Versions = append(extra.Versions, v)
+			}
+		}
+	}
+	fields, err := jsonFields(extra)
+	if err != nil {
+		return nil, err
+	}
+	delete(fields, "name")
+	for k, v := range fields {
+		e[k] = v
+	}
+	return e, nil
+}
+
+// zipBlueprint archives dir's manifest.yaml and template/ directory, the
+// layout downloadAndExtractTemplate expects, with sorted entries and fixed
+// timestamps so the output is reproducible. gen reads the variable schema
+// from the bundled manifest.
+func zipBlueprint(dir string) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if d.IsDir() {
+			if rel != "." && rel != "template" && !strings.HasPrefix(rel, "template/") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if rel != "manifest.yaml" && !strings.HasPrefix(rel, "template/") {
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return fmt.Errorf("%s: only regular files can be bundled", p)
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		h := &zip.FileHeader{Name: rel, Method: zip.Deflate, Modified: zipEpoch}
+		h.SetMode(info.Mode().Perm())
+		w, err := zw.CreateHeader(h)
+		if err != nil {
+			return err
+		}
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(w, f)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func init() {
+	registryBuildCmd.Flags().StringVar(&buildDir, "dir", "../dragon-blueprints", "Blueprints tree to scan for manifest.yaml files")
+	registryBuildCmd.Flags().StringVar(&buildOut, "out", "dist", "Output directory for bundles and registry.json")
+	registryBuildCmd.Flags().StringVar(&buildBaseURL, "base-url", "", "URL the bundles will be published under (required)")
+	registryBuildCmd.Flags().StringVar(&buildRepo, "repo", "", "Source repository recorded on every entry")
+	registryCmd.AddCommand(registryBuildCmd)
+}
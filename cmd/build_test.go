@@ -0,0 +1,174 @@
+/*
+ * // Copyright 2025 getDragon-dev
+ * // Licensed under the Apache License, Version 2.0 (the "License");
+ * // you may not use this file except in compliance with the License.
+ * // You may obtain a copy of the License at http://www.apache.org/licenses/LICENSE-2.0
+ * // Unless required by applicable law or agreed to in writing, software
+ * // distributed under the License is distributed on an "AS IS" BASIS,
+ * // WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * // See the License for the specific language governing permissions and
+ * // limitations under the License.
+ */
+
+package cmd
+
+import (
+	"archive/zip"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// buildTree writes a blueprints tree with one blueprint, api, at version.
+func buildTree(t *testing.T, root, version string) {
+	t.Helper()
+	files := map[string]string{
+		"services/api/manifest.yaml":         "name: api\nversion: " + version + "\ndescription: An API\n",
+		"services/api/template/main.go":      "package main\n",
+		"services/api/template/cmd/serve.go": "package cmd\n",
+		"services/api/README.md":             "not bundled\n",
+	}
+	for name, body := range files {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func runBuild(t *testing.T, dir, out string) error {
+	t.Helper()
+	old := []string{buildDir, buildOut, buildBaseURL, buildRepo}
+	t.Cleanup(func() { buildDir, buildOut, buildBaseURL, buildRepo = old[0], old[1], old[2], old[3] })
+	buildDir, buildOut, buildBaseURL, buildRepo = dir, out, "https://example.com/bundles/", ""
+	return registryBuildCmd.RunE(registryBuildCmd, nil)
+}
+
+func TestRegistryBuildReproducible(t *testing.T) {
+	tmp := t.TempDir()
+	src, out := filepath.Join(tmp, "blueprints"), filepath.Join(tmp, "dist")
+	buildTree(t, src, "1.0.0")
+	if err := runBuild(t, src, out); err != nil {
+		t.Fatal(err)
+	}
+	bundle := filepath.Join(out, "api-1.0.0.zip")
+	first, err := os.ReadFile(bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	index, err := os.ReadFile(filepath.Join(out, "registry.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Touching the sources changes nothing but their timestamps.
+	later := time.Now().Add(time.Hour)
+	_ = filepath.WalkDir(src, func(p string, _ os.DirEntry, _ error) error {
+		return os.Chtimes(p, later, later)
+	})
+	if err := runBuild(t, src, out); err != nil {
+		t.Fatal(err)
+	}
+	second, err := os.ReadFile(bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(first, second) {
+		t.Error("rebuilding an unchanged blueprint changed its bundle")
+	}
+	if again, _ := os.ReadFile(filepath.Join(out, "registry.json")); !bytes.Equal(index, again) {
+		t.Errorf("registry.json changed:\n%s\n%s", index, again)
+	}
+
+	set := registrySet{Name: "built", URL: filepath.Join(out, "registry.json")}
+	if err := decodeIndex(&set, index); err != nil {
+		t.Fatal(err)
+	}
+	if len(set.DB.Blueprints) != 1 {
+		t.Fatalf("index lists %d blueprints", len(set.DB.Blueprints))
+	}
+	r := set.resolve(set.DB.Blueprints[0])
+	if r.Path != "services/api" || r.DownloadURL != "https://example.com/bundles/api-1.0.0.zip" || r.Size != int64(len(first)) {
+		t.Errorf("entry = %+v", r)
+	}
+	if err := verifyDigest(r.Digest, first); err != nil {
+		t.Errorf("digest: %v", err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(first), int64(len(first)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+	}
+	if got, want := strings.Join(names, " "), "manifest.yaml template/cmd/serve.go template/main.go"; got != want {
+		t.Errorf("bundle holds %s, want %s", got, want)
+	}
+}
+
+func TestRegistryBuildKeepsReleases(t *testing.T) {
+	tmp := t.TempDir()
+	src, out := filepath.Join(tmp, "blueprints"), filepath.Join(tmp, "dist")
+	buildTree(t, src, "1.0.0")
+	if err := runBuild(t, src, out); err != nil {
+		t.Fatal(err)
+	}
+	first, err := os.ReadFile(filepath.Join(out, "api-1.0.0.zip"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	buildTree(t, src, "1.1.0")
+	if err := runBuild(t, src, out); err != nil {
+		t.Fatal(err)
+	}
+	index, err := os.ReadFile(filepath.Join(out, "registry.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	set := registrySet{Name: "built"}
+	if err := decodeIndex(&set, index); err != nil {
+		t.Fatal(err)
+	}
+	rels := set.releases(set.DB.Blueprints[0])
+	if len(rels) != 2 || rels[0].Version != "1.1.0" || rels[1].Version != "1.0.0" {
+		t.Fatalf("releases = %+v", rels)
+	}
+	old := rels[1]
+	if old.DownloadURL != "https://example.com/bundles/api-1.0.0.zip" || verifyDigest(old.Digest, first) != nil {
+		t.Errorf("1.0.0 was not carried over intact: %+v", old)
+	}
+}
+
+func TestRegistryBuildInvalidManifest(t *testing.T) {
+	tests := []struct{ name, manifest string }{
+		{"no name", "version: 1.0.0\n"},
+		{"no version", "name: api\n"},
+		{"bad version", "name: api\nversion: one\n"},
+		{"bad yaml", "name: [api\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmp := t.TempDir()
+			src, out := filepath.Join(tmp, "blueprints"), filepath.Join(tmp, "dist")
+			buildTree(t, src, "1.0.0")
+			if err := os.WriteFile(filepath.Join(src, "services", "api", "manifest.yaml"), []byte(tt.manifest), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			err := runBuild(t, src, out)
+			if err == nil || !strings.Contains(err.Error(), "manifest.yaml") {
+				t.Fatalf("err = %v, want the manifest named", err)
+			}
+			if _, err := os.Stat(filepath.Join(out, "registry.json")); err == nil {
+				t.Error("registry.json written despite the error")
+			}
+		})
+	}
+}
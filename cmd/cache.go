@@ -0,0 +1,703 @@
+/*
+ * // Copyright 2025 getDragon-dev
+ * // Licensed under the Apache License, Version 2.0 (the "License");
+ * // you may not use this file except in compliance with the License.
+ * // You may obtain a copy of the License at http://www.apache.org/licenses/LICENSE-2.0
+ * // Unless required by applicable law or agreed to in writing, software
+ * // distributed under the License is distributed on an "AS IS" BASIS,
+ * // WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * // See the License for the specific language governing permissions and
+ * // limitations under the License.
+ */
+
+package cmd
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// Extracted bundles are cached under blueprints/<name>/<version>/<key>,
+// where key is the bundle digest when the registry publishes one, the hash
+// of the file for local archives, and the hash of the URL otherwise. Each
+// entry holds the unpacked bundle in files/ and its metadata in meta.json.
+// Entries are built in a temporary directory and renamed into place while
+// holding <entry>.lock, so concurrent invocations never see half an entry
+// and fetch each bundle only once.
+
+func blueprintCacheDir() string { return filepath.Join(cacheDir(), "blueprints") }
+
+type cacheEntry struct {
+	Name     string    `json:"name"`
+	Version  string    `json:"version"`
+	Registry string    `json:"registry,omitempty"`
+	Location string    `json:"location"`
+	Digest   string    `json:"digest,omitempty"`
+	Size     int64     `json:"size"`
+	Fetched  time.Time `json:"fetched"`
+	LastUsed time.Time `json:"last_used"`
+	dir      string
+}
+
+func (e cacheEntry) files() string { return filepath.Join(e.dir, "files") }
+
+func (e cacheEntry) key() string { return filepath.Base(e.dir) }
+
+// touch records that the entry was just used, for cache prune.
+func (e cacheEntry) touch() {
+	e.LastUsed = time.Now().UTC()
+	if b, err := json.MarshalIndent(e, "", "  "); err == nil {
+		_ = writeFileAtomic(filepath.Join(e.dir, "meta.json"), b, 0o600)
+	}
+}
+
+func readCacheEntry(dir string) (cacheEntry, bool) {
+	var e cacheEntry
+	b, err := os.ReadFile(filepath.Join(dir, "meta.json"))
+	if err != nil || json.Unmarshal(b, &e) != nil {
+		return e, false
+	}
+	e.dir = dir
+	if fi, err := os.Stat(e.files()); err != nil || !fi.IsDir() {
+		return e, false
+	}
+	return e, true
+}
+
+// bundleCacheKey names the cache entry for the bundle at loc; local is the
+// bundle's path when it is a local file.
+func bundleCacheKey(loc, digest, local string) (string, error) {
+	if digest != "" {
+		_, sum, err := parseDigest(digest)
+		if err != nil {
+			return "", err
+		}
+		algo := strings.ToLower(digest[:strings.IndexAny(digest, ":-")])
+		return algo + "-" + hex.EncodeToString(sum), nil
+	}
+	h := sha256.New()
+	if local == "" {
+		h.Write([]byte(loc))
+		return "url-" + hex.EncodeToString(h.Sum(nil))[:32], nil
+	}
+	f, err := os.Open(local)
+	if err != nil {
+		return "", fmt.Errorf("bundle %s: %w", loc, err)
+	}
+	defer f.Close()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("bundle %s: %w", loc, err)
+	}
+	return "sha256-" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// cacheEntryDir returns where the entry for bp lives. Name and version come
+// from a registry, so ones that would escape the cache directory are refused.
+func cacheEntryDir(bp resolvedBlueprint, key string) (string, error) {
+	for _, s := range []string{bp.Name, bp.Version} {
+		if s == "" || s == "." || s == ".." {
+			return "", fmt.Errorf("blueprint %q version %q cannot be cached", bp.Name, bp.Version)
+		}
+	}
+	return filepath.Join(blueprintCacheDir(), url.PathEscape(bp.Name), url.PathEscape(bp.Version), key), nil
+}
+
+// cachedBundle returns the directory holding the unpacked bundle of bp,
+// fetching and extracting it into the cache first when needed. local is the
+// bundle's path when loc is a local archive. With --refresh, a bundle that
+// is not pinned by digest is fetched again.
+func cachedBundle(ctx context.Context, bp resolvedBlueprint, loc, local string) (string, error) {
+	key, err := bundleCacheKey(loc, bp.Digest, local)
+	if err != nil {
+		return "", err
+	}
+	dir, err := cacheEntryDir(bp, key)
+	if err != nil {
+		return "", err
+	}
+	start := time.Now()
+	stale := refresh && bp.Digest == "" && local == ""
+	reuse := func() (string, bool) {
+		e, ok := readCacheEntry(dir)
+		// An entry fetched after we started was refreshed by another
+		// invocation waiting on the same lock.
+		if !ok || (stale && e.Fetched.Before(start)) {
+			return "", false
+		}
+		e.touch()
+		return e.files(), true
+	}
+	if p, ok := reuse(); ok {
+		return p, nil
+	}
+	// Unpacked bundles are as private as the registries they came from.
+	if err := privateDir(blueprintCacheDir()); err != nil {
+		return "", err
+	}
+	release, err := acquireLock(ctx, dir+".lock", "fetching "+bp.Name+" "+bp.Version)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+	if p, ok := reuse(); ok {
+		return p, nil
+	}
+	if err := fillCacheEntry(ctx, bp, loc, local, dir); err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "files"), nil
+}
+
+// fillCacheEntry fetches and verifies the bundle, unpacks it next to dir and
+// renames the result into place. The caller holds the entry's lock.
+func fillCacheEntry(ctx context.Context, bp resolvedBlueprint, loc, local, dir string) error {
+	maxBundle, maxUnpacked, err := bundleLimits()
+	if err != nil {
+		return err
+	}
+	p, mediaType := local, ""
+	if p == "" {
+		if p, mediaType, err = fetchBundle(ctx, loc, bp.Size, maxBundle); err != nil {
+			return err
+		}
+		defer discardDownload(p)
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if fi.Size() > maxBundle {
+		return fmt.Errorf("bundle %s is %s, over the %s limit (max_bundle_size)", loc, formatBytes(fi.Size()), formatBytes(maxBundle))
+	}
+	if bp.Size > 0 && fi.Size() != bp.Size {
+		return fmt.Errorf("bundle %s: size %d does not match registry (%d)", loc, fi.Size(), bp.Size)
+	}
+	if bp.Digest != "" {
+		if err := verifyDigestReader(bp.Digest, f); err != nil {
+			return fmt.Errorf("bundle %s: %w", loc, err)
+		}
+	}
+	format, err := detectFormat(f, mediaType, loc)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dir), 0o700); err != nil {
+		return err
+	}
+	tmp, err := tempDir(filepath.Dir(dir), ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer removeTemp(tmp)
+	files := filepath.Join(tmp, "files")
+	if err := os.Mkdir(files, 0o700); err != nil {
+		return err
+	}
+	if err := extractBundle(f, fi.Size(), format, files, "", maxUnpacked); err != nil {
+		return fmt.Errorf("bundle %s: %w", loc, err)
+	}
+	now := time.Now().UTC()
+	e := cacheEntry{Name: bp.Name, Version: bp.Version, Registry: bp.Registry, Location: loc, Digest: bp.Digest, Size: dirSize(files), Fetched: now, LastUsed: now}
+	b, err := json.MarshalIndent(e, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(tmp, "meta.json"), b, 0o600); err != nil {
+		return err
+	}
+	// Only a --refresh gets here with an entry already in place.
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	return os.Rename(tmp, dir)
+}
+
+func dirSize(dir string) int64 {
+	var n int64
+	_ = filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err == nil && d.Type().IsRegular() {
+			if fi, err := d.Info(); err == nil {
+				n += fi.Size()
+			}
+		}
+		return nil
+	})
+	return n
+}
+
+// cacheEntries lists the cached blueprints, sorted by name and newest
+// version first.
+func cacheEntries() ([]cacheEntry, error) {
+	metas, err := filepath.Glob(filepath.Join(blueprintCacheDir(), "*", "*", "*", "meta.json"))
+	if err != nil {
+		return nil, err
+	}
+	var out []cacheEntry
+	for _, m := range metas {
+		if e, ok := readCacheEntry(filepath.Dir(m)); ok {
+			out = append(out, e)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Name != out[j].Name {
+			return out[i].Name < out[j].Name
+		}
+		return compareVersions(out[i].Version, out[j].Version) > 0
+	})
+	return out, nil
+}
+
+// matchEntries filters entries by a name[@version] argument; an empty ref
+// matches everything.
+func matchEntries(entries []cacheEntry, ref string) []cacheEntry {
+	if ref == "" {
+		return entries
+	}
+	name, version, _ := strings.Cut(ref, "@")
+	var out []cacheEntry
+	for _, e := range entries {
+		if e.Name == name && (version == "" || e.Version == version) {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// removeCacheEntry deletes an entry, waiting for anyone filling it.
+func removeCacheEntry(e cacheEntry) error {
+	release, err := acquireLock(context.Background(), e.dir+".lock", "fetching "+e.Name+" "+e.Version)
+	if err != nil {
+		return err
+	}
+	defer release()
+	if err := os.RemoveAll(e.dir); err != nil {
+		return err
+	}
+	// Drop the version and name directories once they are empty.
+	_ = os.Remove(filepath.Dir(e.dir))
+	_ = os.Remove(filepath.Dir(filepath.Dir(e.dir)))
+	return nil
+}
+
+// parseAge reads durations such as "90m", "36h", "30d" or "2w".
+func parseAge(s string) (time.Duration, error) {
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if n, ok := strings.CutSuffix(s, suffix); ok {
+			v, err := strconv.ParseFloat(n, 64)
+			if err != nil || v < 0 {
+				return 0, fmt.Errorf("invalid age %q", s)
+			}
+			return time.Duration(v * float64(unit)), nil
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid age %q (use e.g. 12h, 30d or 2w)", s)
+	}
+	return d, nil
+}
+
+func formatAge(t time.Time) string {
+	d := time.Since(t)
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm ago", int(d.Minutes()))
+	case d < 48*time.Hour:
+		return fmt.Sprintf("%dh ago", int(d.Hours()))
+	}
+	return fmt.Sprintf("%dd ago", int(d.Hours()/24))
+}
+
+// cacheArea is a part of the cache directory that clean and info report on;
+// items matches one file per cached item.
+type cacheArea struct {
+	name, dir, items string
+}
+
+func cacheAreas() []cacheArea {
+	return []cacheArea{
+		{"Blueprints", blueprintCacheDir(), "*/*/*/meta.json"},
+		{"Downloads", filepath.Join(cacheDir(), "downloads"), "*.part"},
+		{"Registries", registryCacheDir(), "*.meta.json"},
+		{"Git mirrors", filepath.Join(cacheDir(), "git"), "*.git"},
+	}
+}
+
+var (
+	cacheJSON        bool
+	cacheCleanAll    bool
+	cachePruneAge    string
+	cachePruneDryRun bool
+	cacheWarmAll     bool
+)
+
+var cacheCmd = &cobra.Command{Use: "cache", Short: "Inspect and manage the local blueprint cache",
+	Long: `Inspect and manage the local blueprint cache.
+
+Downloaded bundles are unpacked once into the cache directory, keyed by
+blueprint name, version and digest, and reused by later runs of gen and get
+(also with --offline). Bundles without a digest are fetched again with
+--refresh. The cache lives in $XDG_CACHE_HOME/dragon (~/.cache/dragon).`}
+
+var cacheListCmd = &cobra.Command{Use: "list", Short: "List cached blueprints", Args: cobra.NoArgs, RunE: func(cmd *cobra.Command, args []string) error {
+	entries, err := cacheEntries()
+	if err != nil {
+		return err
+	}
+	if cacheJSON {
+		type row struct {
+			cacheEntry
+			Key  string `json:"key"`
+			Path string `json:"path"`
+		}
+		rows := []row{}
+		for _, e := range entries {
+			rows = append(rows, row{e, e.key(), e.files()})
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(rows)
+	}
+	if len(entries) == 0 {
+		fmt.Println("No cached blueprints.")
+		return nil
+	}
+	for _, e := range entries {
+		fmt.Printf("- %s (%s) — %s, last used %s [%s]\n", e.Name, e.Version, formatBytes(e.Size), formatAge(e.LastUsed), e.key())
+	}
+	return nil
+}}
+
+var cacheInfoCmd = &cobra.Command{Use: "info [name[@version]]", Short: "Show cache location and usage, or details of cached blueprints", Args: cobra.MaximumNArgs(1), RunE: func(cmd *cobra.Command, args []string) error {
+	if len(args) == 1 {
+		entries, err := cacheEntries()
+		if err != nil {
+			return err
+		}
+		matched := matchEntries(entries, args[0])
+		if len(matched) == 0 {
+			return fmt.Errorf("%s is not cached", args[0])
+		}
+		for i, e := range matched {
+			if i > 0 {
+				fmt.Println()
+			}
+			fmt.Printf("%s %s\n", e.Name, e.Version)
+			fmt.Printf("  Path:      %s\n", e.files())
+			if e.Registry != "" {
+				fmt.Printf("  Registry:  %s\n", e.Registry)
+			}
+			fmt.Printf("  Location:  %s\n", e.Location)
+			if e.Digest != "" {
+				fmt.Printf("  Digest:    %s\n", e.Digest)
+			}
+			fmt.Printf("  Size:      %s\n", formatBytes(e.Size))
+			fmt.Printf("  Fetched:   %s\n", e.Fetched.Local().Format(time.DateTime))
+			fmt.Printf("  Last used: %s (%s)\n", e.LastUsed.Local().Format(time.DateTime), formatAge(e.LastUsed))
+		}
+		return nil
+	}
+	fmt.Printf("Cache: %s\n", cacheDir())
+	var total int64
+	for _, a := range cacheAreas() {
+		n := dirSize(a.dir)
+		total += n
+		items, _ := filepath.Glob(filepath.Join(a.dir, filepath.FromSlash(a.items)))
+		fmt.Printf("  %-12s %4d  %s\n", a.name+":", len(items), formatBytes(n))
+	}
+	fmt.Printf("Total: %s\n", formatBytes(total))
+	return nil
+}}
+
+// removeLeftovers removes partial downloads and the temp directories of
+// interrupted extractions last touched before cutoff, and returns the bytes
+// freed. Both are written under a lock, so a .part file or temp directory
+// whose lock is still held is left alone however old it looks.
+func removeLeftovers(cutoff time.Time, dryRun bool) int64 {
+	var leftovers []string
+	parts, _ := filepath.Glob(filepath.Join(cacheDir(), "downloads", "*.part"))
+	leftovers = append(leftovers, parts...)
+	tmps, _ := filepath.Glob(filepath.Join(blueprintCacheDir(), "*", "*", ".tmp-*"))
+	leftovers = append(leftovers, tmps...)
+	var freed int64
+	for _, p := range leftovers {
+		fi, err := os.Stat(p)
+		if err != nil || !fi.ModTime().Before(cutoff) {
+			continue
+		}
+		if fi.IsDir() {
+			if heldLock(filepath.Dir(p)) {
+				continue
+			}
+			freed += dirSize(p)
+		} else {
+			if strings.HasSuffix(p, ".part") && heldLockFile(p+".lock") {
+				continue
+			}
+			freed += fi.Size()
+		}
+		if !dryRun {
+			if strings.HasSuffix(p, ".part") {
+				discardDownload(p)
+			} else {
+				os.RemoveAll(p)
+			}
+		}
+	}
+	return freed
+}
+
+// removeRegistryCache removes the cached registry indexes and returns the
+// bytes freed. Indexes are replaced by a rename, so removing one never tears
+// it; only temp files written after cutoff are left to their writer.
+func removeRegistryCache(cutoff time.Time) int64 {
+	files, _ := os.ReadDir(registryCacheDir())
+	var freed int64
+	for _, f := range files {
+		fi, err := f.Info()
+		if err != nil || strings.Contains(f.Name(), ".tmp-") && !fi.ModTime().Before(cutoff) {
+			continue
+		}
+		if os.Remove(filepath.Join(registryCacheDir(), f.Name())) == nil {
+			freed += fi.Size()
+		}
+	}
+	os.Remove(registryCacheDir())
+	return freed
+}
+
+// removeGitMirrors removes the git mirrors and returns the bytes freed. Each
+// mirror is taken under its lock, so one a running dragon is reading from is
+// removed once it is done; clones started after cutoff are left alone.
+func removeGitMirrors(cutoff time.Time) (int64, error) {
+	dir := filepath.Join(cacheDir(), "git")
+	mirrors, _ := filepath.Glob(filepath.Join(dir, "*.git"))
+	var freed int64
+	for _, m := range mirrors {
+		release, err := acquireLock(context.Background(), m+".lock", "using "+m)
+		if err != nil {
+			return freed, err
+		}
+		n := dirSize(m)
+		err = os.RemoveAll(m)
+		release()
+		if err != nil {
+			return freed, err
+		}
+		freed += n
+	}
+	clones, _ := filepath.Glob(filepath.Join(dir, "clone-*"))
+	for _, c := range clones {
+		if fi, err := os.Stat(c); err == nil && fi.ModTime().Before(cutoff) {
+			freed += dirSize(c)
+			os.RemoveAll(c)
+		}
+	}
+	os.Remove(dir)
+	return freed, nil
+}
+
+// heldLock reports whether dir holds a lock file that is not stale.
+func heldLock(dir string) bool {
+	locks, _ := filepath.Glob(filepath.Join(dir, "*.lock"))
+	return slices.ContainsFunc(locks, heldLockFile)
+}
+
+// heldLockFile reports whether the lock file p exists and is not stale.
+func heldLockFile(p string) bool {
+	fi, err := os.Stat(p)
+	return err == nil && time.Since(fi.ModTime()) <= lockStale
+}
+
+var cacheCleanCmd = &cobra.Command{Use: "clean [name[@version]]", Short: "Remove cached blueprints and partial downloads", Args: cobra.MaximumNArgs(1), RunE: func(cmd *cobra.Command, args []string) error {
+	if len(args) == 1 && cacheCleanAll {
+		return errors.New("--all cannot be combined with a blueprint name")
+	}
+	entries, err := cacheEntries()
+	if err != nil {
+		return err
+	}
+	ref := ""
+	if len(args) == 1 {
+		ref = args[0]
+	}
+	matched := matchEntries(entries, ref)
+	if ref != "" && len(matched) == 0 {
+		return fmt.Errorf("%s is not cached", ref)
+	}
+	var freed int64
+	for _, e := range matched {
+		if err := removeCacheEntry(e); err != nil {
+			return err
+		}
+		freed += e.Size
+	}
+	if ref == "" {
+		// Entries and downloads of a running dragon are not ours to take;
+		// only what has sat untouched for as long as a stale lock goes.
+		freed += removeLeftovers(time.Now().Add(-lockStale), false)
+		if cacheCleanAll {
+			freed += removeRegistryCache(time.Now().Add(-lockStale))
+			n, err := removeGitMirrors(time.Now().Add(-lockStale))
+			freed += n
+			if err != nil {
+				return err
+			}
+		}
+	}
+	fmt.Printf("Removed %d cached blueprint(s), freed %s\n", len(matched), formatBytes(freed))
+	return nil
+}}
+
+var cachePruneCmd = &cobra.Command{Use: "prune", Short: "Remove cached blueprints not used recently", Args: cobra.NoArgs, RunE: func(cmd *cobra.Command, args []string) error {
+	age, err := parseAge(cachePruneAge)
+	if err != nil {
+		return err
+	}
+	cutoff := time.Now().Add(-age)
+	entries, err := cacheEntries()
+	if err != nil {
+		return err
+	}
+	verb := "Removed"
+	if cachePruneDryRun {
+		verb = "Would remove"
+	}
+	var n int
+	var freed int64
+	for _, e := range entries {
+		if !e.LastUsed.Before(cutoff) {
+			continue
+		}
+		if !cachePruneDryRun {
+			if err := removeCacheEntry(e); err != nil {
+				return err
+			}
+		}
+		fmt.Printf("%s %s %s (last used %s)\n", verb, e.Name, e.Version, formatAge(e.LastUsed))
+		n++
+		freed += e.Size
+	}
+	freed += removeLeftovers(cutoff, cachePruneDryRun)
+	fmt.Printf("%s %d cached blueprint(s) unused for %s, %s in total\n", verb, n, cachePruneAge, formatBytes(freed))
+	return nil
+}}
+
+var cacheWarmCmd = &cobra.Command{Use: "warm [registry...]", Short: "Prefetch blueprints from the configured registries for offline use", RunE: func(cmd *cobra.Command, args []string) error {
+	sets, err := loadAllRegistries()
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	found := map[string]bool{}
+	var cached, skipped, failed int
+	for _, s := range sets {
+		if len(args) > 0 && !slices.Contains(args, s.Name) {
+			continue
+		}
+		found[s.Name] = true
+		for _, bp := range s.DB.Blueprints {
+			var rels []resolvedBlueprint
+			if cacheWarmAll {
+				for _, r := range s.releases(bp) {
+					if !r.Yanked {
+						rels = append(rels, r)
+					}
+				}
+			} else if r, ok := s.listed(bp, false); ok {
+				rels = append(rels, r)
+			}
+			for _, r := range rels {
+				if r.DownloadURL == "" {
+					skipped++
+					continue
+				}
+				if err := warmBlueprint(ctx, r); err != nil {
+					fmt.Fprintf(os.Stderr, "warning: %s/%s %s: %v\n", s.Name, r.Name, r.Version, err)
+					failed++
+					continue
+				}
+				fmt.Printf("cached %s/%s %s\n", s.Name, r.Name, r.Version)
+				cached++
+			}
+		}
+	}
+	for _, a := range args {
+		if !found[a] {
+			return fmt.Errorf("registry %q not found or could not be loaded", a)
+		}
+	}
+	fmt.Printf("Cached %d blueprint(s)", cached)
+	if skipped > 0 {
+		fmt.Printf(", %d without a bundle skipped", skipped)
+	}
+	fmt.Println()
+	if failed > 0 {
+		return fmt.Errorf("%d blueprint(s) could not be cached", failed)
+	}
+	return nil
+}}
+
+// warmBlueprint makes r available offline: bundles are unpacked into the
+// cache, git sources are mirrored, and local directories need nothing.
+func warmBlueprint(ctx context.Context, r resolvedBlueprint) error {
+	loc := bundleLocation(r.DownloadURL, r.Source)
+	if isGit(loc) {
+		g, err := parseGitSource(loc)
+		if err != nil {
+			return err
+		}
+		_, _, release, err := g.resolve(ctx)
+		if err != nil {
+			return err
+		}
+		release()
+		return nil
+	}
+	local, ok := localBundlePath(loc)
+	if ok {
+		fi, err := os.Stat(local)
+		if err != nil {
+			return err
+		}
+		if fi.IsDir() {
+			return nil
+		}
+	}
+	_, err := cachedBundle(ctx, r, loc, local)
+	return err
+}
+
+func init() {
+	cacheListCmd.Flags().BoolVar(&cacheJSON, "json", false, "output JSON")
+	cacheCleanCmd.Flags().BoolVar(&cacheCleanAll, "all", false, "Also remove cached registry indexes and git mirrors")
+	cachePruneCmd.Flags().StringVar(&cachePruneAge, "older-than", "30d", "Remove blueprints last used longer ago than this (e.g. 12h, 30d, 2w)")
+	cachePruneCmd.Flags().BoolVar(&cachePruneDryRun, "dry-run", false, "Show what would be removed without removing it")
+	cacheWarmCmd.Flags().BoolVar(&cacheWarmAll, "all-versions", false, "Prefetch every release that is not yanked, not just the newest")
+	cacheCmd.AddCommand(cacheListCmd, cacheInfoCmd, cacheCleanCmd, cachePruneCmd, cacheWarmCmd)
+	rootCmd.AddCommand(cacheCmd)
+}
@@ -0,0 +1,141 @@
+/*
+ * // Copyright 2025 getDragon-dev
+ * // Licensed under the Apache License, Version 2.0 (the "License");
+ * // you may not use this file except in compliance with the License.
+ * // You may obtain a copy of the License at http://www.apache.org/licenses/LICENSE-2.0
+ * // Unless required by applicable law or agreed to in writing, software
+ * // distributed under the License is distributed on an "AS IS" BASIS,
+ * // WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * // See the License for the specific language governing permissions and
+ * // limitations under the License.
+ */
+
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCacheEntryDirEscapes(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	for _, nv := range [][2]string{{"..", "1.0.0"}, {"api", ".."}, {".", "1.0.0"}, {"api", ""}} {
+		bp := resolvedBlueprint{}
+		bp.Name, bp.Version = nv[0], nv[1]
+		if dir, err := cacheEntryDir(bp, "sha256-00"); err == nil {
+			t.Errorf("%q %q: cached at %s", nv[0], nv[1], dir)
+		}
+		if _, err := cachedBundle(context.Background(), bp, "https://example.com/b.zip", ""); err == nil {
+			t.Errorf("%q %q: cachedBundle accepted it", nv[0], nv[1])
+		}
+	}
+	bp := resolvedBlueprint{}
+	bp.Name, bp.Version = "team/api", "1.0.0"
+	dir, err := cacheEntryDir(bp, "sha256-00")
+	if err != nil || filepath.Dir(filepath.Dir(filepath.Dir(dir))) != blueprintCacheDir() {
+		t.Errorf("team/api: %s, %v", dir, err)
+	}
+}
+
+func TestCacheCleanLeavesLockedWork(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	old := time.Now().Add(-2 * lockStale)
+	mk := func(p string, dir bool, mtime time.Time) string {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		var err error
+		if dir {
+			err = os.MkdirAll(p, 0o755)
+		} else {
+			err = os.WriteFile(p, []byte("x"), 0o644)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(p, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	ver := filepath.Join(blueprintCacheDir(), "api", "1.0.0")
+	entry := filepath.Join(ver, "sha256-aa")
+	mk(filepath.Join(entry, "files", "main.go"), false, time.Now())
+	if err := os.WriteFile(filepath.Join(entry, "meta.json"), []byte(`{"name":"api","version":"1.0.0"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// Another dragon is filling api 1.0.0 under a different key.
+	filling := mk(filepath.Join(ver, ".tmp-busy"), true, old)
+	mk(filepath.Join(ver, "sha256-bb.lock"), false, time.Now())
+	abandoned := mk(filepath.Join(blueprintCacheDir(), "web", "2.0.0", ".tmp-dead"), true, old)
+	downloads := filepath.Join(cacheDir(), "downloads")
+	active := mk(filepath.Join(downloads, "active.part"), false, time.Now())
+	stale := mk(filepath.Join(downloads, "stale.part"), false, old)
+
+	if err := cacheCleanCmd.RunE(cacheCleanCmd, nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{entry, abandoned, stale} {
+		if _, err := os.Stat(p); err == nil {
+			t.Errorf("%s was not removed", strings.TrimPrefix(p, cacheDir()))
+		}
+	}
+	for _, p := range []string{filling, filepath.Join(ver, "sha256-bb.lock"), active} {
+		if _, err := os.Stat(p); err != nil {
+			t.Errorf("%s was removed: %v", strings.TrimPrefix(p, cacheDir()), err)
+		}
+	}
+}
+
+func TestCacheCleanAllWaitsForMirrors(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	cacheCleanAll = true
+	t.Cleanup(func() { cacheCleanAll = false })
+	mirror := gitMirrorDir("https://example.com/blueprints.git")
+	if err := os.MkdirAll(filepath.Join(mirror, "objects"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	index := filepath.Join(registryCacheDir(), "abc.json")
+	writing := index + ".tmp-1"
+	for _, p := range []string{index, writing} {
+		if err := os.MkdirAll(filepath.Dir(p), 0o700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte("{}"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	release, err := acquireLock(context.Background(), mirror+".lock", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error)
+	go func() { done <- cacheCleanCmd.RunE(cacheCleanCmd, nil) }()
+	select {
+	case err := <-done:
+		t.Fatalf("clean finished while the mirror was in use: %v", err)
+	case <-time.After(3 * lockPoll):
+	}
+	if _, err := os.Stat(mirror); err != nil {
+		t.Fatalf("mirror removed while in use: %v", err)
+	}
+	release()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{mirror, index} {
+		if _, err := os.Stat(p); err == nil {
+			t.Errorf("%s was not removed", strings.TrimPrefix(p, cacheDir()))
+		}
+	}
+	if _, err := os.Stat(writing); err != nil {
+		t.Errorf("index being written was removed: %v", err)
+	}
+}
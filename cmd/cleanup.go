@@ -0,0 +1,85 @@
+/*
+ * // Copyright 2025 getDragon-dev
+ * // Licensed under the Apache License, Version 2.0 (the "License");
+ * // you may not use this file except in compliance with the License.
+ * // You may obtain a copy of the License at http://www.apache.org/licenses/LICENSE-2.0
+ * // Unless required by applicable law or agreed to in writing, software
+ * // distributed under the License is distributed on an "AS IS" BASIS,
+ * // WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * // See the License for the specific language governing permissions and
+ * // limitations under the License.
+ */
+
+package cmd
+
+import (
+	"os"
+	"os/signal"
+	"slices"
+	"sync"
+	"syscall"
+)
+
+// Temporary files and directories are tracked so that they are removed on
+// every way out of the process: normal return, error, and Ctrl-C.
+var (
+	tempMu    sync.Mutex
+	tempPaths []string
+)
+
+// tempDir is os.MkdirTemp for a directory removed when dragon exits.
+func tempDir(dir, pattern string) (string, error) {
+	p, err := os.MkdirTemp(dir, pattern)
+	if err == nil {
+		trackTemp(p)
+	}
+	return p, err
+}
+
+// tempFile is os.CreateTemp for a file removed when dragon exits.
+func tempFile(dir, pattern string) (*os.File, error) {
+	f, err := os.CreateTemp(dir, pattern)
+	if err == nil {
+		trackTemp(f.Name())
+	}
+	return f, err
+}
+
+func trackTemp(p string) {
+	tempMu.Lock()
+	defer tempMu.Unlock()
+	tempPaths = append(tempPaths, p)
+}
+
+// removeTemp deletes a tracked path before exit.
+func removeTemp(p string) {
+	os.RemoveAll(p)
+	tempMu.Lock()
+	defer tempMu.Unlock()
+	tempPaths = slices.DeleteFunc(tempPaths, func(x string) bool { return x == p })
+}
+
+func cleanupTemp() {
+	tempMu.Lock()
+	defer tempMu.Unlock()
+	for _, p := range tempPaths {
+		os.RemoveAll(p)
+	}
+	tempPaths = nil
+}
+
+// cleanupOnSignal removes temporary paths and exits when the process is
+// interrupted or terminated.
+func cleanupOnSignal() {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		s := <-c
+		restoreTerminal()
+		cleanupTemp()
+		if s == os.Interrupt {
+			os.Exit(130)
+		}
+		os.Exit(143)
+	}()
+}
@@ -0,0 +1,627 @@
+/*
+ * // Copyright 2025 getDragon-dev
+ * // Licensed under the Apache License, Version 2.0 (the "License");
+ * // you may not use this file except in compliance with the License.
+ * // You may obtain a copy of the License at http://www.apache.org/licenses/LICENSE-2.0
+ * // Unless required by applicable law or agreed to in writing, software
+ * // distributed under the License is distributed on an "AS IS" BASIS,
+ * // WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * // See the License for the specific language governing permissions and
+ * // limitations under the License.
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	configResolved bool
+	configJSON     bool
+)
+
+var configCmd = &cobra.Command{Use: "config", Short: "Inspect and edit dragon configuration",
+	Long: `The user config lives in config.json, or config.yaml when that file exists,
+under the dragon config directory; DRAGON_CONFIG points at a different file.
+
+Keys:
+  default, order, cache_ttl, max_bundle_size, max_unpacked_size
+  blueprint_paths                       comma-separated search roots
+  registries.<name>                     the registry URL
+  registries.<name>.url|timeout|public_keys
+  registries.<name>.auth.type|token_env|username|password_env
+  vars.<key>, pins.<blueprint>
+  http.ca_file|client_cert|client_key|timeout|retries
+
+Environment overrides: DRAGON_DEFAULT, DRAGON_ORDER, DRAGON_CACHE_TTL,
+DRAGON_MAX_BUNDLE_SIZE, DRAGON_MAX_UNPACKED_SIZE and DRAGON_HTTP_CA_FILE,
+_CLIENT_CERT, _CLIENT_KEY, _TIMEOUT and _RETRIES override the config.
+DRAGON_VAR_<key> sets vars.<key> and DRAGON_PINS takes comma-separated
+blueprint=constraint pairs; both are merged over the configured ones.
+DRAGON_BLUEPRINT_PATH (a path list, like PATH) is searched ahead of
+blueprint_paths; every global flag can be set as DRAGON_<FLAG>, e.g.
+DRAGON_REGISTRY, DRAGON_OFFLINE or DRAGON_REGISTRY_TIMEOUT.`}
+
+var configShowCmd = &cobra.Command{Use: "show", Short: "Print the effective configuration",
+	Long: `Print the configuration dragon runs with: the user config with the nearest
+.dragon.yaml layered over it. --resolved also shows where each value came
+from. Precedence, highest first: command-line flags, DRAGON_* environment
+variables, .dragon.yaml, the user config, built-in defaults.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, src, err := effectiveConfig()
+		if err != nil {
+			return err
+		}
+		if registryPath != "" {
+			cfg.Registries = []Registry{{Name: autoName(registryPath), URL: registryPath}}
+			cfg.Default, cfg.Order = cfg.Registries[0].Name, nil
+			src["default"] = "flag --registry"
+			src["registries."+cfg.Default] = "flag --registry"
+		}
+		if cfg.CacheTTL == "" {
+			cfg.CacheTTL = defaultCacheTTL.String()
+			src["cache_ttl"] = "default"
+		}
+		if cfg.MaxBundleSize == "" {
+			cfg.MaxBundleSize = formatBytes(defaultMaxBundleSize)
+			src["max_bundle_size"] = "default"
+		}
+		if cfg.MaxUnpackedSize == "" {
+			cfg.MaxUnpackedSize = formatBytes(defaultMaxUnpackedSize)
+			src["max_unpacked_size"] = "default"
+		}
+		if configJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			if configResolved {
+				return enc.Encode(map[string]any{"config": cfg, "sources": src})
+			}
+			return enc.Encode(cfg)
+		}
+		from := func(key string) string {
+			if !configResolved {
+				return ""
+			}
+			if s, ok := src[key]; ok {
+				return "  # " + s
+			}
+			return ""
+		}
+		fmt.Printf("default: %s%s\n", cfg.Default, from("default"))
+		if len(cfg.Order) > 0 {
+			fmt.Printf("order: %s%s\n", strings.Join(cfg.Order, ", "), from("order"))
+		}
+		fmt.Printf("cache_ttl: %s%s\n", cfg.CacheTTL, from("cache_ttl"))
+		if len(cfg.BlueprintPaths) > 0 {
+			fmt.Printf("blueprint_paths: %s%s\n", strings.Join(cfg.BlueprintPaths, ", "), from("blueprint_paths"))
+		}
+		fmt.Printf("max_bundle_size: %s%s\n", cfg.MaxBundleSize, from("max_bundle_size"))
+		fmt.Printf("max_unpacked_size: %s%s\n", cfg.MaxUnpackedSize, from("max_unpacked_size"))
+		fmt.Println("registries:")
+		for _, r := range cfg.Registries {
+			fmt.Printf("  %s: %s%s\n", r.Name, r.URL, from("registries."+r.Name))
+		}
+		if h := cfg.HTTP; h != nil {
+			fmt.Println("http:")
+			for _, kv := range [][2]string{{"ca_file", h.CAFile}, {"client_cert", h.ClientCert}, {"client_key", h.ClientKey}, {"timeout", h.Timeout}} {
+				if kv[1] != "" {
+					fmt.Printf("  %s: %s%s\n", kv[0], kv[1], from("http."+kv[0]))
+				}
+			}
+			if h.Retries != nil {
+				fmt.Printf("  retries: %d%s\n", *h.Retries, from("http.retries"))
+			}
+		}
+		if len(cfg.Vars) > 0 {
+			fmt.Println("vars:")
+			for _, k := range sortedKeys(cfg.Vars) {
+				fmt.Printf("  %s: %v%s\n", k, cfg.Vars[k], from("vars."+k))
+			}
+		}
+		if len(cfg.Pins) > 0 {
+			fmt.Println("pins:")
+			for _, k := range sortedKeys(cfg.Pins) {
+				fmt.Printf("  %s: %s%s\n", k, cfg.Pins[k], from("pins."+k))
+			}
+		}
+		return nil
+	},
+}
+
+var configPathProject bool
+
+var configPathCmd = &cobra.Command{Use: "path", Short: "Print the path of the config file", Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if configPathProject {
+			p := findProjectConfig()
+			if p == "" {
+				return fmt.Errorf("no %s found in this directory or its parents", projectConfigName)
+			}
+			fmt.Println(p)
+			return nil
+		}
+		fmt.Println(configPath())
+		return nil
+	},
+}
+
+var configGetCmd = &cobra.Command{Use: "get <key>", Short: "Print one effective setting", Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, _, err := effectiveConfig()
+		if err != nil {
+			return err
+		}
+		v, err := getConfigKey(cfg, args[0])
+		if err != nil {
+			return err
+		}
+		switch v := v.(type) {
+		case string:
+			fmt.Println(v)
+		case []string:
+			fmt.Println(strings.Join(v, ","))
+		default:
+			b, err := json.MarshalIndent(v, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(b))
+		}
+		return nil
+	},
+}
+
+var configSetCmd = &cobra.Command{Use: "set <key> <value>", Short: "Change a setting in the user config", Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return updateConfig(func(cfg *Config) error { return setConfigKey(cfg, args[0], args[1]) })
+	},
+}
+
+var configUnsetCmd = &cobra.Command{Use: "unset <key>", Short: "Remove a setting from the user config", Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return updateConfig(func(cfg *Config) error { return unsetConfigKey(cfg, args[0]) })
+	},
+}
+
+var configValidateCmd = &cobra.Command{Use: "validate [file]", Short: "Check a config file for errors", Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		files := []string{configPath()}
+		if len(args) == 1 {
+			files = args
+		} else if p := findProjectConfig(); p != "" {
+			files = append(files, p)
+		}
+		failed := 0
+		for _, p := range files {
+			cfg, _, err := loadConfigFile(p)
+			if err != nil {
+				fmt.Println(err)
+				failed++
+				continue
+			}
+			if p == findProjectConfig() {
+				// A project file may refer to registries from the user
+				// config, so check what it produces once merged.
+				if cfg, _, err = effectiveConfig(); err != nil {
+					fmt.Println(err)
+					failed++
+					continue
+				}
+			}
+			if problems := validateConfig(cfg); len(problems) > 0 {
+				fmt.Printf("%s:\n  %s\n", p, strings.Join(problems, "\n  "))
+				failed++
+				continue
+			}
+			fmt.Println("OK:", p)
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d invalid config file(s)", failed)
+		}
+		return nil
+	},
+}
+
+var configEditCmd = &cobra.Command{Use: "edit", Short: "Open the user config in $VISUAL or $EDITOR", Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		p := configPath()
+		orig, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		// Edit a copy so a broken edit never replaces a working config.
+		tmp, err := os.CreateTemp("", "dragon-config-*"+filepath.Ext(p))
+		if err != nil {
+			return err
+		}
+		tmp.Close()
+		if err := os.WriteFile(tmp.Name(), orig, 0o600); err != nil {
+			return err
+		}
+		editor := os.Getenv("VISUAL")
+		if editor == "" {
+			editor = os.Getenv("EDITOR")
+		}
+		if editor == "" {
+			editor = "vi"
+			if runtime.GOOS == "windows" {
+				editor = "notepad"
+			}
+		}
+		argv := append(strings.Fields(editor), tmp.Name())
+		c := exec.Command(argv[0], argv[1:]...)
+		c.Stdin, c.Stdout, c.Stderr = os.Stdin, os.Stdout, os.Stderr
+		if err := c.Run(); err != nil {
+			return fmt.Errorf("%s: %w (your edits are in %s)", editor, err, tmp.Name())
+		}
+		edited, err := os.ReadFile(tmp.Name())
+		if err != nil {
+			return err
+		}
+		cfg, _, err := decodeConfig(p, edited)
+		if err == nil {
+			if problems := validateConfig(cfg); len(problems) > 0 {
+				err = errors.New(strings.Join(problems, "; "))
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("config not saved: %v (your edits are in %s)", err, tmp.Name())
+		}
+		os.Remove(tmp.Name())
+		if bytes.Equal(orig, edited) {
+			return nil
+		}
+		return writeFileAtomic(p, edited, 0o644)
+	},
+}
+
+// updateConfig applies change to the user config and saves it if the result
+// is valid.
+func updateConfig(change func(*Config) error) error {
+	cfg, err := readConfig()
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if err := change(&cfg); err != nil {
+		return err
+	}
+	if problems := validateConfig(cfg); len(problems) > 0 {
+		return fmt.Errorf("refusing to save an invalid config:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return writeConfig(cfg)
+}
+
+// registryFields are the settings below registries.<name>.
+var registryFields = []string{"url", "timeout", "public_keys", "auth", "auth.type", "auth.token_env", "auth.username", "auth.password_env"}
+
+// splitRegistryKey splits "<name>[.<field>]". Registry names are often host
+// names containing dots, so configured names are matched first.
+func splitRegistryKey(cfg Config, rest string) (string, string) {
+	best := ""
+	for _, r := range cfg.Registries {
+		if (rest == r.Name || strings.HasPrefix(rest, r.Name+".")) && len(r.Name) > len(best) {
+			best = r.Name
+		}
+	}
+	if best != "" {
+		return best, strings.TrimPrefix(strings.TrimPrefix(rest, best), ".")
+	}
+	for _, f := range registryFields {
+		if name, ok := strings.CutSuffix(rest, "."+f); ok {
+			return name, f
+		}
+	}
+	return rest, ""
+}
+
+func findRegistry(cfg *Config, name string) *Registry {
+	for i := range cfg.Registries {
+		if cfg.Registries[i].Name == name {
+			return &cfg.Registries[i]
+		}
+	}
+	return nil
+}
+
+func getConfigKey(cfg Config, key string) (any, error) {
+	section, rest, _ := strings.Cut(key, ".")
+	switch {
+	case key == "version":
+		return cfg.Version, nil
+	case key == "default":
+		return cfg.Default, nil
+	case key == "order":
+		return cfg.Order, nil
+	case key == "cache_ttl":
+		return cfg.CacheTTL, nil
+	case key == "blueprint_paths":
+		return cfg.BlueprintPaths, nil
+	case key == "max_bundle_size":
+		return cfg.MaxBundleSize, nil
+	case key == "max_unpacked_size":
+		return cfg.MaxUnpackedSize, nil
+	case key == "vars":
+		return cfg.Vars, nil
+	case key == "pins":
+		return cfg.Pins, nil
+	case key == "registries":
+		return cfg.Registries, nil
+	case section == "vars":
+		if v, ok := cfg.Vars[rest]; ok {
+			return v, nil
+		}
+	case section == "pins":
+		if v, ok := cfg.Pins[rest]; ok {
+			return v, nil
+		}
+	case key == "http":
+		return cfg.HTTP, nil
+	case section == "http":
+		h := HTTPConfig{}
+		if cfg.HTTP != nil {
+			h = *cfg.HTTP
+		}
+		switch rest {
+		case "ca_file":
+			return h.CAFile, nil
+		case "client_cert":
+			return h.ClientCert, nil
+		case "client_key":
+			return h.ClientKey, nil
+		case "timeout":
+			return h.Timeout, nil
+		case "retries":
+			if h.Retries == nil {
+				return defaultHTTPRetries, nil
+			}
+			return *h.Retries, nil
+		}
+		return nil, fmt.Errorf("unknown http setting %q", rest)
+	case section == "registries":
+		name, field := splitRegistryKey(cfg, rest)
+		r := findRegistry(&cfg, name)
+		if r == nil {
+			return nil, fmt.Errorf("registry %q not found", name)
+		}
+		a := RegistryAuth{}
+		if r.Auth != nil {
+			a = *r.Auth
+		}
+		switch field {
+		case "":
+			return r.URL, nil
+		case "url":
+			return r.URL, nil
+		case "timeout":
+			return r.Timeout, nil
+		case "public_keys":
+			return r.PublicKeys, nil
+		case "auth":
+			return r.Auth, nil
+		case "auth.type":
+			return a.Type, nil
+		case "auth.token_env":
+			return a.TokenEnv, nil
+		case "auth.username":
+			return a.Username, nil
+		case "auth.password_env":
+			return a.PasswordEnv, nil
+		}
+		return nil, fmt.Errorf("unknown registry setting %q", field)
+	default:
+		return nil, fmt.Errorf("unknown key %q", key)
+	}
+	return nil, fmt.Errorf("%s is not set", key)
+}
+
+func setConfigKey(cfg *Config, key, value string) error {
+	section, rest, _ := strings.Cut(key, ".")
+	switch {
+	case key == "default":
+		cfg.Default = value
+	case key == "order":
+		cfg.Order = splitList(value)
+	case key == "cache_ttl":
+		cfg.CacheTTL = value
+	case key == "blueprint_paths":
+		cfg.BlueprintPaths = splitList(value)
+	case key == "max_bundle_size":
+		cfg.MaxBundleSize = value
+	case key == "max_unpacked_size":
+		cfg.MaxUnpackedSize = value
+	case section == "vars" && rest != "":
+		// Values are YAML scalars so numbers and booleans keep their type.
+		var v any
+		if err := yaml.Unmarshal([]byte(value), &v); err != nil || v == nil {
+			v = value
+		}
+		if cfg.Vars == nil {
+			cfg.Vars = map[string]any{}
+		}
+		cfg.Vars[rest] = v
+	case section == "pins" && rest != "":
+		if cfg.Pins == nil {
+			cfg.Pins = map[string]string{}
+		}
+		cfg.Pins[rest] = value
+	case section == "http" && rest != "":
+		if cfg.HTTP == nil {
+			cfg.HTTP = &HTTPConfig{}
+		}
+		switch rest {
+		case "ca_file":
+			cfg.HTTP.CAFile = value
+		case "client_cert":
+			cfg.HTTP.ClientCert = value
+		case "client_key":
+			cfg.HTTP.ClientKey = value
+		case "timeout":
+			cfg.HTTP.Timeout = value
+		case "retries":
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("http.retries: %q is not a number", value)
+			}
+			cfg.HTTP.Retries = &n
+		default:
+			return fmt.Errorf("cannot set http setting %q", rest)
+		}
+	case section == "registries" && rest != "":
+		name, field := splitRegistryKey(*cfg, rest)
+		r := findRegistry(cfg, name)
+		if r == nil {
+			if field != "" && field != "url" {
+				return fmt.Errorf("registry %q not found; set registries.%s first", name, name)
+			}
+			cfg.Registries = append(cfg.Registries, Registry{Name: name})
+			r = &cfg.Registries[len(cfg.Registries)-1]
+		}
+		if strings.HasPrefix(field, "auth.") && r.Auth == nil {
+			r.Auth = &RegistryAuth{}
+		}
+		switch field {
+		case "", "url":
+			r.URL = value
+		case "timeout":
+			r.Timeout = value
+		case "public_keys":
+			r.PublicKeys = splitList(value)
+		case "auth.type":
+			r.Auth.Type = value
+		case "auth.token_env":
+			r.Auth.TokenEnv = value
+		case "auth.username":
+			r.Auth.Username = value
+		case "auth.password_env":
+			r.Auth.PasswordEnv = value
+		default:
+			return fmt.Errorf("cannot set registry setting %q", field)
+		}
+	default:
+		return fmt.Errorf("cannot set %q", key)
+	}
+	return nil
+}
+
+func unsetConfigKey(cfg *Config, key string) error {
+	section, rest, _ := strings.Cut(key, ".")
+	switch {
+	case key == "default":
+		cfg.Default = ""
+	case key == "order":
+		cfg.Order = nil
+	case key == "cache_ttl":
+		cfg.CacheTTL = ""
+	case key == "blueprint_paths":
+		cfg.BlueprintPaths = nil
+	case key == "max_bundle_size":
+		cfg.MaxBundleSize = ""
+	case key == "max_unpacked_size":
+		cfg.MaxUnpackedSize = ""
+	case key == "vars":
+		cfg.Vars = nil
+	case key == "pins":
+		cfg.Pins = nil
+	case section == "vars":
+		delete(cfg.Vars, rest)
+	case section == "pins":
+		delete(cfg.Pins, rest)
+	case key == "http":
+		cfg.HTTP = nil
+	case section == "http":
+		if cfg.HTTP == nil {
+			break
+		}
+		switch rest {
+		case "ca_file":
+			cfg.HTTP.CAFile = ""
+		case "client_cert":
+			cfg.HTTP.ClientCert = ""
+		case "client_key":
+			cfg.HTTP.ClientKey = ""
+		case "timeout":
+			cfg.HTTP.Timeout = ""
+		case "retries":
+			cfg.HTTP.Retries = nil
+		default:
+			return fmt.Errorf("cannot unset http setting %q", rest)
+		}
+		if *cfg.HTTP == (HTTPConfig{}) {
+			cfg.HTTP = nil
+		}
+	case section == "registries" && rest != "":
+		name, field := splitRegistryKey(*cfg, rest)
+		r := findRegistry(cfg, name)
+		if r == nil {
+			return fmt.Errorf("registry %q not found", name)
+		}
+		switch field {
+		case "":
+			cfg.Registries = slices.DeleteFunc(cfg.Registries, func(x Registry) bool { return x.Name == name })
+			cfg.Order = slices.DeleteFunc(cfg.Order, func(x string) bool { return x == name })
+			if cfg.Default == name {
+				cfg.Default = ""
+			}
+		case "timeout":
+			r.Timeout = ""
+		case "public_keys":
+			r.PublicKeys = nil
+		case "auth":
+			r.Auth = nil
+		case "auth.type", "auth.token_env", "auth.username", "auth.password_env":
+			if r.Auth == nil {
+				break
+			}
+			switch field {
+			case "auth.type":
+				r.Auth.Type = ""
+			case "auth.token_env":
+				r.Auth.TokenEnv = ""
+			case "auth.username":
+				r.Auth.Username = ""
+			case "auth.password_env":
+				r.Auth.PasswordEnv = ""
+			}
+			if *r.Auth == (RegistryAuth{}) {
+				r.Auth = nil
+			}
+		default:
+			return fmt.Errorf("cannot unset registry setting %q", field)
+		}
+	default:
+		return fmt.Errorf("cannot unset %q", key)
+	}
+	return nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func init() {
+	configShowCmd.Flags().BoolVar(&configResolved, "resolved", false, "show where each value comes from")
+	configShowCmd.Flags().BoolVar(&configJSON, "json", false, "output JSON")
+	configPathCmd.Flags().BoolVar(&configPathProject, "project", false, "print the nearest "+projectConfigName+" instead")
+	configCmd.AddCommand(configShowCmd, configPathCmd, configGetCmd, configSetCmd, configUnsetCmd, configValidateCmd, configEditCmd)
+	rootCmd.AddCommand(configCmd)
+}
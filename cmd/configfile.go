@@ -0,0 +1,382 @@
+/*
+ * // Copyright 2025 getDragon-dev
+ * // Licensed under the Apache License, Version 2.0 (the "License");
+ * // you may not use this file except in compliance with the License.
+ * // You may obtain a copy of the License at http://www.apache.org/licenses/LICENSE-2.0
+ * // Unless required by applicable law or agreed to in writing, software
+ * // distributed under the License is distributed on an "AS IS" BASIS,
+ * // WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * // See the License for the specific language governing permissions and
+ * // limitations under the License.
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// currentConfigVersion is the schema version this build writes.
+const currentConfigVersion = 1
+
+// configMigrations[n] upgrades a decoded version-n config to version n+1.
+// They work on the generic document so that renamed or reshaped keys can be
+// handled before strict decoding.
+var configMigrations = []func(raw map[string]any){
+	// 0 -> 1: files written before "order" existed relied on the default
+	// registry being searched first; make that order explicit.
+	func(raw map[string]any) {
+		if order, ok := raw["order"].([]any); ok && len(order) > 0 {
+			return
+		}
+		regs, _ := raw["registries"].([]any)
+		def, _ := raw["default"].(string)
+		order := []any{}
+		for _, r := range regs {
+			if m, ok := r.(map[string]any); ok && m["name"] == def && def != "" {
+				order = append(order, def)
+			}
+		}
+		for _, r := range regs {
+			if m, ok := r.(map[string]any); ok {
+				if name, ok := m["name"].(string); ok && name != def {
+					order = append(order, name)
+				}
+			}
+		}
+		if len(order) > 0 {
+			raw["order"] = order
+		}
+	},
+}
+
+func isYAMLPath(p string) bool {
+	ext := strings.ToLower(filepath.Ext(p))
+	return ext == ".yaml" || ext == ".yml"
+}
+
+// loadConfigFile reads and migrates the config at p. It also returns the
+// version the file was written with.
+func loadConfigFile(p string) (Config, int, error) {
+	b, err := os.ReadFile(p)
+	if err != nil {
+		return Config{}, 0, err
+	}
+	return decodeConfig(p, b)
+}
+
+func decodeConfig(p string, b []byte) (Config, int, error) {
+	raw := map[string]any{}
+	if isYAMLPath(p) {
+		if err := yaml.Unmarshal(b, &raw); err != nil {
+			return Config{}, 0, fmt.Errorf("%s: %w", p, err)
+		}
+	} else if len(bytes.TrimSpace(b)) > 0 {
+		if err := json.Unmarshal(b, &raw); err != nil {
+			return Config{}, 0, fmt.Errorf("%s: %s", p, describeJSONError(b, err))
+		}
+	}
+	if raw == nil {
+		raw = map[string]any{}
+	}
+	from := 0
+	switch v := raw["version"].(type) {
+	case nil:
+	case int:
+		from = v
+	case float64:
+		from = int(v)
+	default:
+		return Config{}, 0, fmt.Errorf("%s: version must be a number", p)
+	}
+	if from > currentConfigVersion {
+		return Config{}, 0, fmt.Errorf("%s: config version %d is newer than this dragon understands (%d); upgrade dragon", p, from, currentConfigVersion)
+	}
+	for v := from; v < currentConfigVersion; v++ {
+		configMigrations[v](raw)
+	}
+	raw["version"] = currentConfigVersion
+	norm, err := json.Marshal(raw)
+	if err != nil {
+		return Config{}, 0, fmt.Errorf("%s: %w", p, err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(norm))
+	dec.DisallowUnknownFields()
+	var cfg Config
+	if err := dec.Decode(&cfg); err != nil {
+		return Config{}, 0, fmt.Errorf("%s: %s", p, describeJSONError(norm, err))
+	}
+	return cfg, from, nil
+}
+
+// describeJSONError turns encoding/json errors into something that points at
+// the problem: a line and column for syntax errors, the key for unknown or
+// mistyped fields.
+func describeJSONError(b []byte, err error) string {
+	var syn *json.SyntaxError
+	var typ *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syn):
+		line, col := 1, 1
+		for _, c := range b[:min(int(syn.Offset), len(b))] {
+			if c == '\n' {
+				line, col = line+1, 1
+			} else {
+				col++
+			}
+		}
+		return fmt.Sprintf("line %d, column %d: %v", line, col, syn)
+	case errors.As(err, &typ):
+		return fmt.Sprintf("%s: expected %s, got %s", typ.Field, typ.Type, typ.Value)
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		return "unknown key " + strings.TrimPrefix(err.Error(), "json: unknown field ")
+	}
+	return err.Error()
+}
+
+func encodeConfig(p string, cfg Config) ([]byte, error) {
+	if isYAMLPath(p) {
+		return yaml.Marshal(cfg)
+	}
+	b, err := json.MarshalIndent(cfg, "", "  ")
+	return append(b, '\n'), err
+}
+
+// backupOldConfig copies the config at p to p.v<N>.bak when it was written
+// with an older schema version N, unless that backup already exists. It
+// returns the backup's path, or "" when p is missing or current.
+func backupOldConfig(p string) (string, int, error) {
+	b, err := os.ReadFile(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", 0, nil
+	}
+	if err != nil {
+		return "", 0, err
+	}
+	_, from, err := decodeConfig(p, b)
+	if err != nil || from >= currentConfigVersion {
+		// Commands read the config before writing it, so a file that
+		// doesn't decode never gets this far.
+		return "", 0, nil
+	}
+	backup := fmt.Sprintf("%s.v%d.bak", p, from)
+	if _, err := os.Stat(backup); err == nil {
+		return backup, from, nil
+	}
+	if err := os.WriteFile(backup, b, 0o600); err != nil {
+		return "", 0, err
+	}
+	return backup, from, nil
+}
+
+// validateConfig checks the settings that strict decoding cannot.
+func validateConfig(cfg Config) []string {
+	problems := []string{}
+	names := map[string]bool{}
+	for i, r := range cfg.Registries {
+		where := fmt.Sprintf("registries[%d]", i)
+		if r.Name == "" {
+			problems = append(problems, where+": missing name")
+		} else {
+			where = "registries." + r.Name
+			if names[r.Name] {
+				problems = append(problems, where+": duplicate name")
+			}
+			names[r.Name] = true
+		}
+		if r.URL == "" {
+			problems = append(problems, where+": missing url")
+		}
+		if r.Timeout != "" {
+			if d, err := time.ParseDuration(r.Timeout); err != nil || d <= 0 {
+				problems = append(problems, fmt.Sprintf("%s.timeout: invalid duration %q", where, r.Timeout))
+			}
+		}
+		if a := r.Auth; a != nil {
+			switch a.Type {
+			case "", "bearer", "netrc":
+			case "basic":
+				if a.Username == "" {
+					problems = append(problems, where+".auth: basic auth needs a username")
+				}
+			default:
+				problems = append(problems, fmt.Sprintf("%s.auth.type: unknown %q (want bearer, basic or netrc)", where, a.Type))
+			}
+		}
+		for _, k := range r.PublicKeys {
+			if b, err := decodeBase64([]byte(k)); err != nil || len(b) != 32 {
+				problems = append(problems, fmt.Sprintf("%s.public_keys: %q is not a base64 ed25519 public key", where, k))
+			}
+		}
+	}
+	if cfg.Default != "" && !names[cfg.Default] {
+		problems = append(problems, fmt.Sprintf("default: registry %q is not defined", cfg.Default))
+	}
+	for _, n := range cfg.Order {
+		if !names[n] {
+			problems = append(problems, fmt.Sprintf("order: registry %q is not defined", n))
+		}
+	}
+	if cfg.CacheTTL != "" {
+		if d, err := time.ParseDuration(cfg.CacheTTL); err != nil || d < 0 {
+			problems = append(problems, fmt.Sprintf("cache_ttl: invalid duration %q", cfg.CacheTTL))
+		}
+	}
+	for _, kv := range [][2]string{{"max_bundle_size", cfg.MaxBundleSize}, {"max_unpacked_size", cfg.MaxUnpackedSize}} {
+		if kv[1] != "" {
+			if _, err := parseByteSize(kv[1]); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %v", kv[0], err))
+			}
+		}
+	}
+	if h := cfg.HTTP; h != nil {
+		if h.Timeout != "" {
+			if d, err := time.ParseDuration(h.Timeout); err != nil || d <= 0 {
+				problems = append(problems, fmt.Sprintf("http.timeout: invalid duration %q", h.Timeout))
+			}
+		}
+		if h.Retries != nil && *h.Retries < 0 {
+			problems = append(problems, "http.retries: must not be negative")
+		}
+		if (h.ClientCert == "") != (h.ClientKey == "") {
+			problems = append(problems, "http: client_cert and client_key must be set together")
+		}
+		for _, f := range [][2]string{{"ca_file", h.CAFile}, {"client_cert", h.ClientCert}, {"client_key", h.ClientKey}} {
+			if f[1] == "" {
+				continue
+			}
+			if _, err := os.Stat(configRelative(f[1])); err != nil {
+				problems = append(problems, fmt.Sprintf("http.%s: %v", f[0], err))
+			}
+		}
+	}
+	for name, c := range cfg.Pins {
+		if _, err := parseRange(c); err != nil {
+			problems = append(problems, fmt.Sprintf("pins.%s: %v", name, err))
+		}
+	}
+	return problems
+}
+
+// applyEnvConfig applies the DRAGON_* overrides for config settings; global
+// flags are covered by applyEnvFlags.
+func applyEnvConfig(cfg *Config, src configSources) {
+	if v, ok := os.LookupEnv("DRAGON_DEFAULT"); ok {
+		cfg.Default = v
+		src["default"] = "env DRAGON_DEFAULT"
+	}
+	if v, ok := os.LookupEnv("DRAGON_ORDER"); ok {
+		cfg.Order = splitList(v)
+		src["order"] = "env DRAGON_ORDER"
+	}
+	if v, ok := os.LookupEnv("DRAGON_CACHE_TTL"); ok {
+		cfg.CacheTTL = v
+		src["cache_ttl"] = "env DRAGON_CACHE_TTL"
+	}
+	if v := os.Getenv("DRAGON_BLUEPRINT_PATH"); v != "" {
+		var paths []string
+		for _, p := range filepath.SplitList(v) {
+			if p != "" {
+				paths = append(paths, expandPath(p, ""))
+			}
+		}
+		from := "env DRAGON_BLUEPRINT_PATH"
+		if len(cfg.BlueprintPaths) > 0 {
+			from += " (ahead of " + src["blueprint_paths"] + ")"
+		}
+		cfg.BlueprintPaths = slices.Concat(paths, cfg.BlueprintPaths)
+		src["blueprint_paths"] = from
+	}
+	if v, ok := os.LookupEnv("DRAGON_MAX_BUNDLE_SIZE"); ok {
+		cfg.MaxBundleSize = v
+		src["max_bundle_size"] = "env DRAGON_MAX_BUNDLE_SIZE"
+	}
+	if v, ok := os.LookupEnv("DRAGON_MAX_UNPACKED_SIZE"); ok {
+		cfg.MaxUnpackedSize = v
+		src["max_unpacked_size"] = "env DRAGON_MAX_UNPACKED_SIZE"
+	}
+	applyEnvHTTP(cfg, src)
+	// DRAGON_VAR_<Name> sets the template variable Name, keeping its case.
+	for _, kv := range os.Environ() {
+		k, v, _ := strings.Cut(kv, "=")
+		if name, ok := strings.CutPrefix(k, "DRAGON_VAR_"); ok && name != "" {
+			cfg.Vars = mergeMaps(cfg.Vars, map[string]any{name: v})
+			src["vars."+name] = "env " + k
+		}
+	}
+	// DRAGON_PINS lists name=constraint pairs, comma-separated.
+	if v, ok := os.LookupEnv("DRAGON_PINS"); ok {
+		pins := map[string]string{}
+		for _, p := range splitList(v) {
+			name, c, ok := strings.Cut(p, "=")
+			if !ok || strings.TrimSpace(name) == "" {
+				fmt.Fprintf(os.Stderr, "warning: ignoring %q in DRAGON_PINS, want name=constraint\n", p)
+				continue
+			}
+			name = strings.TrimSpace(name)
+			pins[name] = strings.TrimSpace(c)
+			src["pins."+name] = "env DRAGON_PINS"
+		}
+		cfg.Pins = mergeMaps(cfg.Pins, pins)
+	}
+}
+
+// applyEnvHTTP applies DRAGON_HTTP_<KEY> for each key of the http section.
+// Relative file paths are taken from the working directory, not the config
+// directory.
+func applyEnvHTTP(cfg *Config, src configSources) {
+	h := HTTPConfig{}
+	if cfg.HTTP != nil {
+		h = *cfg.HTTP
+	}
+	set := false
+	for _, f := range []struct {
+		key string
+		val *string
+	}{{"ca_file", &h.CAFile}, {"client_cert", &h.ClientCert}, {"client_key", &h.ClientKey}, {"timeout", &h.Timeout}} {
+		key, name := f.key, envName("http."+f.key)
+		v, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+		if key != "timeout" && v != "" {
+			if abs, err := filepath.Abs(expandPath(v, "")); err == nil {
+				v = abs
+			}
+		}
+		*f.val, set = v, true
+		src["http."+key] = "env " + name
+	}
+	if v, ok := os.LookupEnv(envName("http.retries")); ok {
+		if n, err := strconv.Atoi(strings.TrimSpace(v)); err != nil || n < 0 {
+			fmt.Fprintf(os.Stderr, "warning: ignoring %s=%q: want a number of retries\n", envName("http.retries"), v)
+		} else {
+			h.Retries, set = &n, true
+			src["http.retries"] = "env " + envName("http.retries")
+		}
+	}
+	if set {
+		cfg.HTTP = &h
+	}
+}
+
+func splitList(s string) []string {
+	out := []string{}
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
@@ -0,0 +1,194 @@
+/*
+ * // Copyright 2025 getDragon-dev
+ * // Licensed under the Apache License, Version 2.0 (the "License");
+ * // you may not use this file except in compliance with the License.
+ * // You may obtain a copy of the License at http://www.apache.org/licenses/LICENSE-2.0
+ * // Unless required by applicable law or agreed to in writing, software
+ * // distributed under the License is distributed on an "AS IS" BASIS,
+ * // WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * // See the License for the specific language governing permissions and
+ * // limitations under the License.
+ */
+
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestConfigMigration(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	p := filepath.Join(dir, "config.json")
+	t.Setenv("DRAGON_CONFIG", p)
+	old := []byte(`{
+  "registries": [
+    {"name": "public", "url": "https://getdragon.dev/registry.json"},
+    {"name": "team", "url": "https://team.example/registry.json"}
+  ],
+  "default": "team"
+}
+`)
+	if err := os.WriteFile(p, old, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Reading migrates in memory and leaves the file alone.
+	cfg, err := readConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"team", "public"}; !reflect.DeepEqual(cfg.Order, want) || cfg.Version != currentConfigVersion {
+		t.Errorf("migrated order = %q, version %d; want %q, %d", cfg.Order, cfg.Version, want, currentConfigVersion)
+	}
+	if b, _ := os.ReadFile(p); string(b) != string(old) {
+		t.Errorf("readConfig rewrote the file:\n%s", b)
+	}
+	backup := p + ".v0.bak"
+	if _, err := os.Stat(backup); !os.IsNotExist(err) {
+		t.Errorf("readConfig made a backup: %v", err)
+	}
+
+	// The first write keeps the original, later ones leave that backup be.
+	cfg.CacheTTL = "1h"
+	if err := writeConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := os.ReadFile(backup); err != nil || string(b) != string(old) {
+		t.Fatalf("backup = %q, %v", b, err)
+	}
+	got, from, err := loadConfigFile(p)
+	if err != nil || from != currentConfigVersion || got.CacheTTL != "1h" || !reflect.DeepEqual(got.Order, cfg.Order) {
+		t.Fatalf("rewritten config: %+v, version %d, %v", got, from, err)
+	}
+	cfg.CacheTTL = "2h"
+	if err := writeConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := os.ReadFile(backup); string(b) != string(old) {
+		t.Errorf("backup overwritten:\n%s", b)
+	}
+
+	// An explicit order survives the migration.
+	cfg, _, err = decodeConfig("c.yaml", []byte("registries: [{name: a, url: x}, {name: b, url: y}]\ndefault: b\norder: [a, b]\n"))
+	if err != nil || !reflect.DeepEqual(cfg.Order, []string{"a", "b"}) {
+		t.Errorf("order = %q, %v", cfg.Order, err)
+	}
+}
+
+func TestDecodeConfigErrors(t *testing.T) {
+	tests := []struct {
+		name, path, body, want string
+	}{
+		{"unknown key", "c.json", `{"version": 1, "registires": []}`, `c.json: unknown key "registires"`},
+		{"unknown nested key", "c.yaml", "version: 1\nregistries:\n  - name: a\n    url: x\n    tokn: y\n", `unknown key "tokn"`},
+		{"mistyped", "c.json", `{"version": 1, "default": 3}`, "default: expected string, got number"},
+		{"syntax", "c.json", "{\n  \"version\": 1,\n}", "line 3, column 2"},
+		{"bad yaml", "c.yaml", "registries: [", "c.yaml: yaml:"},
+		{"bad version", "c.json", `{"version": "one"}`, "version must be a number"},
+		{"newer version", "c.json", `{"version": 99}`, "config version 99 is newer"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := decodeConfig(tt.path, []byte(tt.body))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("err = %v, want %q", err, tt.want)
+			}
+		})
+	}
+	if _, _, err := decodeConfig("c.json", nil); err != nil {
+		t.Errorf("empty file: %v", err)
+	}
+}
+
+func TestRegistryEditKeepsBrokenConfig(t *testing.T) {
+	dir := t.TempDir()
+	p := filepath.Join(dir, "config.json")
+	t.Setenv("DRAGON_CONFIG", p)
+	broken := []byte(`{"registires": [{"name": "team", "url": "https://team.example/registry.json"}]}`)
+	if err := os.WriteFile(p, broken, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	old := []string{regName, regURL}
+	t.Cleanup(func() { regName, regURL = old[0], old[1] })
+	regName, regURL = "other", "https://other.example/registry.json"
+	for _, c := range []struct {
+		name string
+		run  func() error
+	}{
+		{"add", func() error { return registryAddCmd.RunE(registryAddCmd, nil) }},
+		{"use", func() error { return registryUseCmd.RunE(registryUseCmd, []string{regURL}) }},
+	} {
+		if err := c.run(); err == nil || !strings.Contains(err.Error(), "registires") {
+			t.Errorf("%s: err = %v, want the decode error", c.name, err)
+		}
+		if b, _ := os.ReadFile(p); string(b) != string(broken) {
+			t.Errorf("%s rewrote the config:\n%s", c.name, b)
+		}
+	}
+}
+
+func TestEnvConfigOverrides(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	p := filepath.Join(dir, "config.yaml")
+	t.Setenv("DRAGON_CONFIG", p)
+	t.Chdir(dir)
+	if err := os.WriteFile(p, []byte("version: 1\ndefault: public\ncache_ttl: 1h\nblueprint_paths: [mine]\n"+
+		"vars: {Router: chi, DB: sqlite}\npins: {api: ^1.0}\nhttp: {timeout: 5s, retries: 1}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("DRAGON_DEFAULT", "team")
+	t.Setenv("DRAGON_ORDER", "team, public")
+	t.Setenv("DRAGON_CACHE_TTL", "")
+	t.Setenv("DRAGON_BLUEPRINT_PATH", "/env/a"+string(os.PathListSeparator)+string(os.PathListSeparator)+"/env/b")
+	t.Setenv("DRAGON_MAX_BUNDLE_SIZE", "1GB")
+	t.Setenv("DRAGON_MAX_UNPACKED_SIZE", "2GB")
+	t.Setenv("DRAGON_HTTP_CA_FILE", filepath.Join("certs", "ca.pem"))
+	t.Setenv("DRAGON_HTTP_RETRIES", "5")
+	t.Setenv("DRAGON_VAR_DB", "postgres")
+	t.Setenv("DRAGON_PINS", "web=2.x, api=^1.2")
+
+	cfg, src, err := effectiveConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Default != "team" || src["default"] != "env DRAGON_DEFAULT" {
+		t.Errorf("default = %q from %q", cfg.Default, src["default"])
+	}
+	if !reflect.DeepEqual(cfg.Order, []string{"team", "public"}) {
+		t.Errorf("order = %q", cfg.Order)
+	}
+	// Set but empty still overrides.
+	if cfg.CacheTTL != "" || src["cache_ttl"] != "env DRAGON_CACHE_TTL" {
+		t.Errorf("cache_ttl = %q from %q", cfg.CacheTTL, src["cache_ttl"])
+	}
+	want := []string{filepath.FromSlash("/env/a"), filepath.FromSlash("/env/b"), filepath.Join(dir, "mine")}
+	if !reflect.DeepEqual(cfg.BlueprintPaths, want) {
+		t.Errorf("blueprint_paths = %q, want %q", cfg.BlueprintPaths, want)
+	}
+	if !strings.HasPrefix(src["blueprint_paths"], "env DRAGON_BLUEPRINT_PATH (ahead of user config") {
+		t.Errorf("blueprint_paths source = %q", src["blueprint_paths"])
+	}
+	if cfg.MaxBundleSize != "1GB" || cfg.MaxUnpackedSize != "2GB" {
+		t.Errorf("limits = %q, %q", cfg.MaxBundleSize, cfg.MaxUnpackedSize)
+	}
+	// Relative paths in the environment are taken from the working directory.
+	ca, _ := filepath.Abs(filepath.Join("certs", "ca.pem"))
+	if h := cfg.HTTP; h == nil || h.CAFile != ca || h.Timeout != "5s" || h.Retries == nil || *h.Retries != 5 {
+		t.Errorf("http = %+v", h)
+	}
+	if src["http.retries"] != "env DRAGON_HTTP_RETRIES" || !strings.HasPrefix(src["http.timeout"], "user config") {
+		t.Errorf("http sources = %q, %q", src["http.retries"], src["http.timeout"])
+	}
+	if want := map[string]any{"Router": "chi", "DB": "postgres"}; !reflect.DeepEqual(cfg.Vars, want) || src["vars.DB"] != "env DRAGON_VAR_DB" {
+		t.Errorf("vars = %v from %q", cfg.Vars, src["vars.DB"])
+	}
+	if want := map[string]string{"api": "^1.2", "web": "2.x"}; !reflect.DeepEqual(cfg.Pins, want) || src["pins.api"] != "env DRAGON_PINS" {
+		t.Errorf("pins = %v from %q", cfg.Pins, src["pins.api"])
+	}
+}
@@ -0,0 +1,442 @@
+/*
+ * // Copyright 2025 getDragon-dev
+ * // Licensed under the Apache License, Version 2.0 (the "License");
+ * // you may not use this file except in compliance with the License.
+ * // You may obtain a copy of the License at http://www.apache.org/licenses/LICENSE-2.0
+ * // Unless required by applicable law or agreed to in writing, software
+ * // distributed under the License is distributed on an "AS IS" BASIS,
+ * // WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * // See the License for the specific language governing permissions and
+ * // limitations under the License.
+ */
+
+package cmd
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	corereg "github.com/getDragon-dev/dragon-core/registry"
+	"github.com/spf13/cobra"
+)
+
+// registrySnapshot is the last-seen state of a registry, kept so that
+// `registry diff` can report what changed since the previous sync.
+type registrySnapshot struct {
+	Registry string           `json:"registry,omitempty"`
+	URL      string           `json:"url"`
+	SavedAt  time.Time        `json:"saved_at"`
+	Database corereg.Database `json:"database"`
+	// Releases is each blueprint's version history with its yank and
+	// deprecation status. Snapshots saved by older versions lack it.
+	Releases map[string][]releaseState `json:"releases,omitempty"`
+}
+
+type releaseState struct {
+	Version    string `json:"version"`
+	Yanked     bool   `json:"yanked,omitempty"`
+	Deprecated string `json:"deprecated,omitempty"`
+}
+
+func newSnapshot(set registrySet, savedAt time.Time) registrySnapshot {
+	snap := registrySnapshot{Registry: set.Name, URL: set.URL, SavedAt: savedAt, Database: set.DB, Releases: map[string][]releaseState{}}
+	for _, bp := range set.DB.Blueprints {
+		states := []releaseState{}
+		for _, r := range set.releases(bp) {
+			st := releaseState{Version: r.Version, Yanked: r.Yanked}
+			if r.Deprecated.active() {
+				st.Deprecated = r.Deprecated.String()
+			}
+			states = append(states, st)
+		}
+		snap.Releases[bp.Name] = states
+	}
+	return snap
+}
+
+type diffEntry struct {
+	Name        string `json:"name"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+type diffChange struct {
+	Name            string   `json:"name"`
+	FromVersion     string   `json:"from_version,omitempty"`
+	ToVersion       string   `json:"to_version,omitempty"`
+	Bump            string   `json:"bump,omitempty"`
+	TagsAdded       []string `json:"tags_added,omitempty"`
+	TagsRemoved     []string `json:"tags_removed,omitempty"`
+	DescriptionFrom string   `json:"description_from,omitempty"`
+	DescriptionTo   string   `json:"description_to,omitempty"`
+	// Releases lists changes to the version history: releases added,
+	// removed, yanked, unyanked, deprecated or undeprecated.
+	Releases []releaseChange `json:"releases,omitempty"`
+}
+
+type releaseChange struct {
+	Version string `json:"version"`
+	Change  string `json:"change"`
+	Detail  string `json:"detail,omitempty"`
+}
+
+type registryDiff struct {
+	Registry string       `json:"registry"`
+	From     string       `json:"from"`
+	To       string       `json:"to"`
+	Since    *time.Time   `json:"since,omitempty"`
+	Baseline bool         `json:"baseline,omitempty"`
+	Added    []diffEntry  `json:"added"`
+	Removed  []diffEntry  `json:"removed"`
+	Changed  []diffChange `json:"changed"`
+}
+
+func (d registryDiff) empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+var (
+	diffJSON     bool
+	diffNoSave   bool
+	diffRegistry string
+)
+
+var registryDiffCmd = &cobra.Command{Use: "diff [<old> <new>]", Short: "Show what changed in registries since the last sync, or between two indexes",
+	Long: `Without arguments, compare every configured registry (or just --name) with
+the snapshot saved the last time this command ran, then save the current
+state as the new snapshot. If this command has not run yet for a registry,
+the snapshot is the index as it was before the first update any command
+fetched. With two arguments, compare two registry.json files or URLs
+directly; no snapshot is touched.`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 0 && len(args) != 2 {
+			return errors.New("expected no arguments or <old> <new>")
+		}
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var diffs []registryDiff
+		if len(args) == 2 {
+			old, err := loadForDiff(Registry{Name: args[0], URL: args[0]})
+			if err != nil {
+				return err
+			}
+			cur, err := loadForDiff(Registry{Name: args[1], URL: args[1]})
+			if err != nil {
+				return err
+			}
+			d := diffSnapshots(newSnapshot(old, time.Time{}), newSnapshot(cur, time.Time{}))
+			d.Registry, d.From, d.To = args[1], args[0], args[1]
+			diffs = append(diffs, d)
+		} else {
+			regs, err := resolveOrder()
+			if err != nil {
+				return err
+			}
+			found := false
+			for _, r := range regs {
+				if diffRegistry != "" && r.Name != diffRegistry {
+					continue
+				}
+				found = true
+				d, err := diffSinceSnapshot(r)
+				if err != nil {
+					if strict || diffRegistry != "" {
+						return fmt.Errorf("registry %s: %w", r.Name, err)
+					}
+					fmt.Fprintf(os.Stderr, "warning: skipping registry %s: %v\n", r.Name, err)
+					continue
+				}
+				diffs = append(diffs, d)
+			}
+			if !found {
+				return fmt.Errorf("registry %q not found", diffRegistry)
+			}
+		}
+		if diffJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(diffs)
+		}
+		for _, d := range diffs {
+			printDiff(d)
+		}
+		return nil
+	},
+}
+
+func loadForDiff(r Registry) (registrySet, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), fetchTimeoutFor(r))
+	defer cancel()
+	return loadLocation(ctx, r)
+}
+
+// snapshotPath is keyed by location, not registry name, so that
+// fetchRegistry can save one without knowing the name.
+func snapshotPath(loc string) string {
+	sum := sha256.Sum256([]byte(loc))
+	return filepath.Join(cacheDir(), "snapshots", hex.EncodeToString(sum[:])+".json")
+}
+
+func readSnapshot(loc string) (registrySnapshot, error) {
+	var snap registrySnapshot
+	b, err := os.ReadFile(snapshotPath(loc))
+	if err != nil {
+		return snap, err
+	}
+	if err := json.Unmarshal(b, &snap); err != nil {
+		return snap, fmt.Errorf("%s: %w", snapshotPath(loc), err)
+	}
+	return snap, nil
+}
+
+// writeSnapshot saves snap. Snapshots hold whole indexes, so they are kept
+// private like the registry cache.
+func writeSnapshot(snap registrySnapshot) error {
+	out, err := json.MarshalIndent(snap, "", "  ")
+	if err != nil {
+		return err
+	}
+	p := snapshotPath(snap.URL)
+	if err := privateDir(filepath.Dir(p)); err != nil {
+		return err
+	}
+	return writeFileAtomic(p, out, 0o600)
+}
+
+// snapshotBefore is called by fetchRegistry when an update replaces the
+// cached index at loc. Unless registry diff has saved a snapshot already,
+// the replaced copy becomes one, so the first diff still shows what the
+// update changed.
+func snapshotBefore(loc string, cached []byte, seen time.Time) {
+	if _, err := os.Stat(snapshotPath(loc)); !errors.Is(err, os.ErrNotExist) {
+		return
+	}
+	set := registrySet{URL: loc}
+	if err := decodeIndex(&set, cached); err != nil {
+		// Not an index, e.g. a signature.
+		return
+	}
+	if err := writeSnapshot(newSnapshot(set, seen.UTC())); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: snapshot registry %s: %v\n", loc, err)
+	}
+}
+
+// diffSinceSnapshot compares r with its saved snapshot and, unless
+// --no-save is given, replaces the snapshot with the current state.
+func diffSinceSnapshot(r Registry) (registryDiff, error) {
+	cur, err := loadForDiff(r)
+	if err != nil {
+		return registryDiff{}, err
+	}
+	snap, err := readSnapshot(r.URL)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return registryDiff{}, err
+	}
+	now := newSnapshot(cur, time.Now().UTC())
+	var d registryDiff
+	if snap.SavedAt.IsZero() {
+		d = registryDiff{Added: []diffEntry{}, Removed: []diffEntry{}, Changed: []diffChange{}, Baseline: true}
+	} else {
+		d = diffSnapshots(snap, now)
+		d.Since = &snap.SavedAt
+	}
+	d.Registry, d.From, d.To = r.Name, snap.URL, r.URL
+	if diffNoSave {
+		return d, nil
+	}
+	return d, writeSnapshot(now)
+}
+
+func diffSnapshots(old, cur registrySnapshot) registryDiff {
+	d := registryDiff{Added: []diffEntry{}, Removed: []diffEntry{}, Changed: []diffChange{}}
+	before := map[string]corereg.Blueprint{}
+	for _, bp := range old.Database.Blueprints {
+		before[bp.Name] = bp
+	}
+	after := map[string]corereg.Blueprint{}
+	for _, bp := range cur.Database.Blueprints {
+		after[bp.Name] = bp
+		prev, ok := before[bp.Name]
+		if !ok {
+			d.Added = append(d.Added, diffEntry{bp.Name, bp.Version, bp.Description})
+			continue
+		}
+		c := diffChange{Name: bp.Name}
+		if prev.Version != bp.Version {
+			c.FromVersion, c.ToVersion, c.Bump = prev.Version, bp.Version, bumpKind(prev.Version, bp.Version)
+		}
+		c.TagsAdded, c.TagsRemoved = tagDelta(prev.Tags, bp.Tags)
+		if prev.Description != bp.Description {
+			c.DescriptionFrom, c.DescriptionTo = prev.Description, bp.Description
+		}
+		if old.Releases != nil {
+			c.Releases = releaseDelta(old.Releases[bp.Name], cur.Releases[bp.Name])
+		}
+		if c.FromVersion != "" || len(c.TagsAdded) > 0 || len(c.TagsRemoved) > 0 || c.DescriptionFrom != c.DescriptionTo || len(c.Releases) > 0 {
+			d.Changed = append(d.Changed, c)
+		}
+	}
+	for _, bp := range old.Database.Blueprints {
+		if _, ok := after[bp.Name]; !ok {
+			d.Removed = append(d.Removed, diffEntry{bp.Name, bp.Version, bp.Description})
+		}
+	}
+	sort.Slice(d.Added, func(i, j int) bool { return d.Added[i].Name < d.Added[j].Name })
+	sort.Slice(d.Removed, func(i, j int) bool { return d.Removed[i].Name < d.Removed[j].Name })
+	sort.Slice(d.Changed, func(i, j int) bool { return d.Changed[i].Name < d.Changed[j].Name })
+	return d
+}
+
+// releaseDelta compares two version histories, newest release first.
+func releaseDelta(old, cur []releaseState) []releaseChange {
+	var out []releaseChange
+	prev := map[string]releaseState{}
+	for _, r := range old {
+		prev[r.Version] = r
+	}
+	seen := map[string]bool{}
+	for _, r := range cur {
+		seen[r.Version] = true
+		p, ok := prev[r.Version]
+		switch {
+		case !ok:
+			out = append(out, releaseChange{Version: r.Version, Change: "added"})
+			continue
+		case r.Yanked && !p.Yanked:
+			out = append(out, releaseChange{Version: r.Version, Change: "yanked"})
+		case !r.Yanked && p.Yanked:
+			out = append(out, releaseChange{Version: r.Version, Change: "unyanked"})
+		}
+		switch {
+		case r.Deprecated != "" && r.Deprecated != p.Deprecated:
+			out = append(out, releaseChange{Version: r.Version, Change: "deprecated", Detail: r.Deprecated})
+		case r.Deprecated == "" && p.Deprecated != "":
+			out = append(out, releaseChange{Version: r.Version, Change: "undeprecated"})
+		}
+	}
+	for _, r := range old {
+		if !seen[r.Version] {
+			out = append(out, releaseChange{Version: r.Version, Change: "removed"})
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return compareVersions(out[i].Version, out[j].Version) > 0 })
+	return out
+}
+
+// bumpKind names the most significant component that changed between two
+// versions, or "downgrade" when the new one is lower.
+func bumpKind(from, to string) string {
+	a, errA := parseVersion(from)
+	b, errB := parseVersion(to)
+	switch {
+	case errA != nil || errB != nil:
+		return ""
+	case b.compare(a) < 0:
+		return "downgrade"
+	case a.Major != b.Major:
+		return "major"
+	case a.Minor != b.Minor:
+		return "minor"
+	case a.Patch != b.Patch:
+		return "patch"
+	case b.compare(a) != 0:
+		return "prerelease"
+	}
+	return ""
+}
+
+func tagDelta(old, cur []string) (added, removed []string) {
+	has := func(list []string, t string) bool {
+		for _, x := range list {
+			if strings.EqualFold(x, t) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, t := range cur {
+		if !has(old, t) {
+			added = append(added, t)
+		}
+	}
+	for _, t := range old {
+		if !has(cur, t) {
+			removed = append(removed, t)
+		}
+	}
+	return added, removed
+}
+
+func printDiff(d registryDiff) {
+	switch {
+	case d.Baseline:
+		fmt.Printf("%s: no previous snapshot", d.Registry)
+		if !diffNoSave {
+			fmt.Print("; saved the current state as the baseline")
+		}
+		fmt.Println()
+		return
+	case d.Since != nil:
+		fmt.Printf("%s (since %s):\n", d.Registry, d.Since.Local().Format("2006-01-02 15:04"))
+	default:
+		fmt.Printf("%s -> %s:\n", d.From, d.To)
+	}
+	if d.empty() {
+		fmt.Println("  no changes")
+		return
+	}
+	for _, e := range d.Added {
+		fmt.Printf("  + %s %s — %s\n", e.Name, e.Version, e.Description)
+	}
+	for _, e := range d.Removed {
+		fmt.Printf("  - %s %s\n", e.Name, e.Version)
+	}
+	for _, c := range d.Changed {
+		fmt.Printf("  ~ %s", c.Name)
+		if c.FromVersion != "" {
+			fmt.Printf(" %s -> %s", c.FromVersion, c.ToVersion)
+			if c.Bump != "" {
+				fmt.Printf(" (%s)", c.Bump)
+			}
+		}
+		fmt.Println()
+		if len(c.TagsAdded) > 0 || len(c.TagsRemoved) > 0 {
+			var parts []string
+			for _, t := range c.TagsAdded {
+				parts = append(parts, "+"+t)
+			}
+			for _, t := range c.TagsRemoved {
+				parts = append(parts, "-"+t)
+			}
+			fmt.Printf("      tags: %s\n", strings.Join(parts, " "))
+		}
+		if c.DescriptionFrom != c.DescriptionTo {
+			fmt.Printf("      description: %q -> %q\n", c.DescriptionFrom, c.DescriptionTo)
+		}
+		for _, r := range c.Releases {
+			if r.Detail != "" {
+				// Detail is the full status, e.g. "deprecated: <message>".
+				fmt.Printf("      %s: %s\n", r.Version, r.Detail)
+			} else {
+				fmt.Printf("      %s: %s\n", r.Version, r.Change)
+			}
+		}
+	}
+}
+
+func init() {
+	registryDiffCmd.Flags().BoolVar(&diffJSON, "json", false, "output JSON")
+	registryDiffCmd.Flags().BoolVar(&diffNoSave, "no-save", false, "do not update the saved snapshot")
+	registryDiffCmd.Flags().StringVar(&diffRegistry, "name", "", "only diff this registry")
+	registryCmd.AddCommand(registryDiffCmd)
+}
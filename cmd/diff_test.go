@@ -0,0 +1,162 @@
+/*
+ * // Copyright 2025 getDragon-dev
+ * // Licensed under the Apache License, Version 2.0 (the "License");
+ * // you may not use this file except in compliance with the License.
+ * // You may obtain a copy of the License at http://www.apache.org/licenses/LICENSE-2.0
+ * // Unless required by applicable law or agreed to in writing, software
+ * // distributed under the License is distributed on an "AS IS" BASIS,
+ * // WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * // See the License for the specific language governing permissions and
+ * // limitations under the License.
+ */
+
+package cmd
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+func snapshotOf(t *testing.T, index string) registrySnapshot {
+	t.Helper()
+	set := registrySet{Name: "r", URL: "https://example.com/registry.json"}
+	if err := decodeIndex(&set, []byte(index)); err != nil {
+		t.Fatal(err)
+	}
+	return newSnapshot(set, time.Now())
+}
+
+const diffOld = `{"blueprints": [
+  {"name": "api", "version": "1.1.0", "description": "API", "tags": ["go"],
+   "versions": [{"version": "1.0.0"}, {"version": "0.9.0"}]},
+  {"name": "cli", "version": "2.0.0", "versions": [{"version": "1.0.0", "yanked": true}]},
+  {"name": "old", "version": "0.1.0"}
+]}`
+
+const diffNew = `{"blueprints": [
+  {"name": "api", "version": "1.2.0", "description": "API", "tags": ["go", "http"],
+   "versions": [{"version": "1.1.0", "deprecated": "security fix in 1.2.0"}, {"version": "1.0.0", "yanked": true}]},
+  {"name": "cli", "version": "2.0.0", "deprecated": {"replacement": "cli2"}, "versions": [{"version": "1.0.0"}]},
+  {"name": "web", "version": "0.1.0", "description": "Web"}
+]}`
+
+func TestDiffSnapshots(t *testing.T) {
+	d := diffSnapshots(snapshotOf(t, diffOld), snapshotOf(t, diffNew))
+	if want := []diffEntry{{"web", "0.1.0", "Web"}}; !reflect.DeepEqual(d.Added, want) {
+		t.Errorf("added = %+v", d.Added)
+	}
+	if want := []diffEntry{{"old", "0.1.0", ""}}; !reflect.DeepEqual(d.Removed, want) {
+		t.Errorf("removed = %+v", d.Removed)
+	}
+	want := []diffChange{
+		{
+			Name: "api", FromVersion: "1.1.0", ToVersion: "1.2.0", Bump: "minor", TagsAdded: []string{"http"},
+			Releases: []releaseChange{
+				{Version: "1.2.0", Change: "added"},
+				{Version: "1.1.0", Change: "deprecated", Detail: "deprecated: security fix in 1.2.0"},
+				{Version: "1.0.0", Change: "yanked"},
+				{Version: "0.9.0", Change: "removed"},
+			},
+		},
+		{
+			Name: "cli",
+			Releases: []releaseChange{
+				{Version: "2.0.0", Change: "deprecated", Detail: "deprecated (use cli2 instead)"},
+				{Version: "1.0.0", Change: "unyanked"},
+				{Version: "1.0.0", Change: "deprecated", Detail: "deprecated (use cli2 instead)"},
+			},
+		},
+	}
+	if !reflect.DeepEqual(d.Changed, want) {
+		t.Errorf("changed:\n got %+v\nwant %+v", d.Changed, want)
+	}
+
+	// Snapshots saved before release states were recorded only compare
+	// the blueprint entries.
+	old := snapshotOf(t, diffOld)
+	old.Releases = nil
+	d = diffSnapshots(old, snapshotOf(t, diffNew))
+	if len(d.Changed) != 1 || d.Changed[0].Name != "api" || d.Changed[0].Releases != nil {
+		t.Errorf("legacy snapshot: changed = %+v", d.Changed)
+	}
+}
+
+func TestSnapshotOnFetch(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Chdir(t.TempDir())
+	savedNoSave := diffNoSave
+	t.Cleanup(func() { diffNoSave = savedNoSave })
+	diffNoSave = false
+
+	var mu sync.Mutex
+	index := diffOld
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.URL.Path == "/registry.json.sig" {
+			_, _ = w.Write([]byte("c2ln\n"))
+			return
+		}
+		_, _ = w.Write([]byte(index))
+	}))
+	defer srv.Close()
+	reg := Registry{Name: "r", URL: srv.URL + "/registry.json"}
+	ctx := context.Background()
+
+	// An unchanged index takes no snapshot; neither do signatures.
+	for range 2 {
+		for _, loc := range []string{reg.URL, reg.URL + signatureSuffix} {
+			if _, err := fetchRegistry(ctx, loc, 0); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	for _, loc := range []string{reg.URL, reg.URL + signatureSuffix} {
+		if _, err := os.Stat(snapshotPath(loc)); !os.IsNotExist(err) {
+			t.Fatalf("%s: snapshot taken without a change: %v", loc, err)
+		}
+	}
+
+	// An update seen by any command keeps the replaced index, so the first
+	// diff shows what changed instead of only saving a baseline.
+	mu.Lock()
+	index = diffNew
+	mu.Unlock()
+	if _, err := fetchRegistry(ctx, reg.URL, 0); err != nil {
+		t.Fatal(err)
+	}
+	d, err := diffSinceSnapshot(reg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Baseline || len(d.Added) != 1 || len(d.Removed) != 1 || len(d.Changed) != 2 {
+		t.Fatalf("first diff = %+v", d)
+	}
+
+	// The diff saved the current state; later fetches leave it alone.
+	mu.Lock()
+	index = `{"blueprints": []}`
+	mu.Unlock()
+	if _, err := fetchRegistry(ctx, reg.URL, 0); err != nil {
+		t.Fatal(err)
+	}
+	snap, err := readSnapshot(reg.URL)
+	if err != nil || len(snap.Database.Blueprints) != 3 || snap.Registry != "r" {
+		t.Fatalf("snapshot = %+v, %v", snap, err)
+	}
+	fi, err := os.Stat(snapshotPath(reg.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runtime.GOOS != "windows" && fi.Mode().Perm()&0o077 != 0 {
+		t.Errorf("snapshot mode %v is readable by others", fi.Mode().Perm())
+	}
+}
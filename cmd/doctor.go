@@ -0,0 +1,386 @@
+/*
+ * // Copyright 2025 getDragon-dev
+ * // Licensed under the Apache License, Version 2.0 (the "License");
+ * // you may not use this file except in compliance with the License.
+ * // You may obtain a copy of the License at http://www.apache.org/licenses/LICENSE-2.0
+ * // Unless required by applicable law or agreed to in writing, software
+ * // distributed under the License is distributed on an "AS IS" BASIS,
+ * // WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * // See the License for the specific language governing permissions and
+ * // limitations under the License.
+ */
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// registryReport is the outcome of probing one registry.
+type registryReport struct {
+	Name      string        `json:"name"`
+	URL       string        `json:"url"`
+	Reachable bool          `json:"reachable"`
+	Status    int           `json:"status,omitempty"`
+	Latency   time.Duration `json:"-"`
+	LatencyMS int64         `json:"latency_ms"`
+	Count     int           `json:"blueprints"`
+	Error     string        `json:"error,omitempty"`
+	Problems  []string      `json:"problems,omitempty"`
+	set       registrySet
+}
+
+type collision struct {
+	Name       string   `json:"name"`
+	Registries []string `json:"registries"`
+	Winner     string   `json:"winner"`
+	Version    string   `json:"version"`
+}
+
+type brokenBundle struct {
+	Registry string `json:"registry"`
+	Name     string `json:"name"`
+	Version  string `json:"version"`
+	URL      string `json:"url"`
+	Status   string `json:"status"`
+}
+
+var (
+	doctorJSON      bool
+	doctorNoBundles bool
+)
+
+var registryDoctorCmd = &cobra.Command{Use: "doctor", Short: "Check configured registries for reachability, schema problems and conflicts", RunE: func(cmd *cobra.Command, args []string) error {
+	regs, err := resolveOrder()
+	if err != nil {
+		return err
+	}
+	reports := make([]registryReport, len(regs))
+	var wg sync.WaitGroup
+	for i, r := range regs {
+		wg.Go(func() { reports[i] = probeRegistry(r) })
+	}
+	wg.Wait()
+
+	sets := []registrySet{}
+	for _, rep := range reports {
+		if rep.Reachable {
+			sets = append(sets, rep.set)
+		}
+	}
+	collisions := findCollisions(sets)
+	broken := []brokenBundle{}
+	if !doctorNoBundles && !offline {
+		broken = checkBundles(sets)
+	}
+
+	issues := len(broken)
+	for _, rep := range reports {
+		if !rep.Reachable {
+			issues++
+		}
+		issues += len(rep.Problems)
+	}
+	if doctorJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(map[string]any{"registries": reports, "collisions": collisions, "broken_bundles": broken}); err != nil {
+			return err
+		}
+	} else {
+		printDoctorReport(reports, collisions, broken)
+	}
+	if issues > 0 {
+		return fmt.Errorf("%d problem(s) found", issues)
+	}
+	return nil
+}}
+
+// probeRegistry fetches r's index with r's own auth, bypassing the cache, and
+// lints it. A remote index is requested exactly once; the status and latency
+// come from that request and a signature is checked against its body.
+// Other locations go through the normal loading path.
+func probeRegistry(r Registry) registryReport {
+	rep := registryReport{Name: r.Name, URL: r.URL}
+	ctx, cancel := context.WithTimeout(withRegistryAuth(context.Background(), r), fetchTimeoutFor(r))
+	defer cancel()
+	start := time.Now()
+	timed := func() {
+		rep.Latency = time.Since(start)
+		rep.LatencyMS = rep.Latency.Milliseconds()
+	}
+	fail := func(msg string) registryReport {
+		if rep.Latency == 0 {
+			timed()
+		}
+		rep.Error = msg
+		return rep
+	}
+	var set registrySet
+	if isRemote(r.URL) && !offline {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.URL, nil)
+		if err != nil {
+			return fail(err.Error())
+		}
+		applyAuth(req)
+		resp, err := doHTTP(req)
+		if err != nil {
+			return fail(err.Error())
+		}
+		data, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		timed()
+		rep.Status = resp.StatusCode
+		if resp.StatusCode/100 != 2 {
+			return fail(http.StatusText(resp.StatusCode))
+		}
+		if err != nil {
+			return fail(err.Error())
+		}
+		if len(r.PublicKeys) > 0 {
+			if err := verifyRemoteIndex(ctx, r, &data, 0); err != nil {
+				return fail(err.Error())
+			}
+		}
+		set = registrySet{Name: r.Name, URL: r.URL}
+		if err := decodeIndex(&set, data); err != nil {
+			return fail(err.Error())
+		}
+	} else {
+		var err error
+		if set, err = loadLocation(ctx, r); err != nil {
+			return fail(err.Error())
+		}
+		timed()
+	}
+	rep.Reachable = true
+	rep.Count = len(set.DB.Blueprints)
+	rep.Problems = lintRegistry(set)
+	rep.set = set
+	return rep
+}
+
+// lintRegistry reports schema problems in a loaded index.
+func lintRegistry(s registrySet) []string {
+	problems := []string{}
+	seen := map[string]bool{}
+	for i, bp := range s.DB.Blueprints {
+		where := fmt.Sprintf("blueprints[%d]", i)
+		if bp.Name == "" {
+			problems = append(problems, where+": missing name")
+			continue
+		}
+		where = bp.Name
+		if seen[bp.Name] {
+			problems = append(problems, where+": duplicate entry")
+		}
+		seen[bp.Name] = true
+		if bp.Version == "" {
+			problems = append(problems, where+": missing version")
+		} else if _, err := parseVersion(bp.Version); err != nil {
+			problems = append(problems, where+": "+err.Error())
+		}
+		if bp.DownloadURL == "" && bp.Path == "" {
+			problems = append(problems, where+": neither download URL nor path set")
+		}
+		e := s.Extras[bp.Name]
+		if e.Digest != "" {
+			if _, _, err := parseDigest(e.Digest); err != nil {
+				problems = append(problems, where+": "+err.Error())
+			}
+		}
+		for _, v := range e.Versions {
+			vw := where + "@" + v.Version
+			if _, err := parseVersion(v.Version); err != nil {
+				problems = append(problems, vw+": "+err.Error())
+			}
+			if v.DownloadURL == "" {
+				problems = append(problems, vw+": missing download_url")
+			}
+			if v.Digest != "" {
+				if _, _, err := parseDigest(v.Digest); err != nil {
+					problems = append(problems, vw+": "+err.Error())
+				}
+			}
+		}
+	}
+	return problems
+}
+
+// findCollisions lists names published by more than one registry along with
+// the release a plain reference resolves to.
+func findCollisions(sets []registrySet) []collision {
+	owners := map[string][]string{}
+	for _, s := range sets {
+		for _, bp := range s.DB.Blueprints {
+			owners[bp.Name] = append(owners[bp.Name], s.Name)
+		}
+	}
+	out := []collision{}
+	for name, regs := range owners {
+		if len(regs) < 2 {
+			continue
+		}
+		c := collision{Name: name, Registries: regs}
+		if win, err := resolveIn(sets, name, ""); err == nil {
+			c.Winner, c.Version = win.Registry, win.Version
+		}
+		out = append(out, c)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// checkBundles checks that every bundle can be fetched, a few at a time.
+// Download URLs are resolved against the index they came from; remote ones
+// are HEADed and local ones must exist. Git and OCI sources are skipped.
+func checkBundles(sets []registrySet) []brokenBundle {
+	var targets []resolvedBlueprint
+	for _, s := range sets {
+		for _, bp := range s.DB.Blueprints {
+			for _, r := range s.releases(bp) {
+				r.DownloadURL = bundleLocation(r.DownloadURL, r.Source)
+				if r.DownloadURL != "" && !isGit(r.DownloadURL) && !isOCI(r.DownloadURL) {
+					targets = append(targets, r)
+				}
+			}
+		}
+	}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	broken := []brokenBundle{}
+	sem := make(chan struct{}, 8)
+	for _, t := range targets {
+		wg.Go(func() {
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			var status string
+			if isRemote(t.DownloadURL) {
+				status = headBundle(t.DownloadURL)
+			} else {
+				status = statBundle(t.DownloadURL)
+			}
+			if status != "" {
+				mu.Lock()
+				broken = append(broken, brokenBundle{t.Registry, t.Name, t.Version, t.DownloadURL, status})
+				mu.Unlock()
+			}
+		})
+	}
+	wg.Wait()
+	sort.Slice(broken, func(i, j int) bool { return broken[i].URL < broken[j].URL })
+	return broken
+}
+
+// statBundle returns "" when the local bundle at loc exists and a short
+// reason otherwise.
+func statBundle(loc string) string {
+	p, ok := localBundlePath(loc)
+	if !ok {
+		return "not a local path"
+	}
+	if _, err := os.Stat(p); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "not found"
+		}
+		return err.Error()
+	}
+	return ""
+}
+
+// headBundle returns "" when url is fetchable and a short reason otherwise.
+// Servers that refuse HEAD are retried with a one-byte ranged GET.
+func headBundle(url string) string {
+	ctx, cancel := context.WithTimeout(context.Background(), fetchTimeout)
+	defer cancel()
+	do := func(method string) (int, error) {
+		req, err := http.NewRequestWithContext(ctx, method, url, nil)
+		if err != nil {
+			return 0, err
+		}
+		if method == http.MethodGet {
+			req.Header.Set("Range", "bytes=0-0")
+		}
+		applyAuth(req)
+		resp, err := doHTTP(req)
+		if err != nil {
+			return 0, err
+		}
+		resp.Body.Close()
+		return resp.StatusCode, nil
+	}
+	code, err := do(http.MethodHead)
+	if err == nil && (code == http.StatusMethodNotAllowed || code == http.StatusNotImplemented) {
+		code, err = do(http.MethodGet)
+	}
+	if err != nil {
+		return err.Error()
+	}
+	if code/100 != 2 {
+		return fmt.Sprintf("%d %s", code, http.StatusText(code))
+	}
+	return ""
+}
+
+func printDoctorReport(reports []registryReport, collisions []collision, broken []brokenBundle) {
+	fmt.Println("Registries:")
+	for _, r := range reports {
+		state := "ok"
+		if !r.Reachable {
+			state = "UNREACHABLE"
+		}
+		status := ""
+		if r.Status != 0 {
+			status = fmt.Sprintf(" HTTP %d", r.Status)
+		}
+		fmt.Printf("  %s -> %s\n    %s%s in %s, %d blueprints\n", r.Name, r.URL, state, status, r.Latency.Round(time.Millisecond), r.Count)
+		if r.Error != "" {
+			fmt.Printf("    error: %s\n", r.Error)
+		}
+		for _, p := range r.Problems {
+			fmt.Printf("    problem: %s\n", p)
+		}
+	}
+	if len(collisions) > 0 {
+		fmt.Println("Name collisions:")
+		for _, c := range collisions {
+			fmt.Printf("  %s in %s; resolves to %s/%s@%s\n", c.Name, strings.Join(c.Registries, ", "), c.Winner, c.Name, c.Version)
+		}
+	}
+	if len(broken) > 0 {
+		fmt.Println("Broken bundles:")
+		for _, b := range broken {
+			fmt.Printf("  %s/%s@%s: %s (%s)\n", b.Registry, b.Name, b.Version, b.URL, b.Status)
+		}
+	}
+}
+
+// checkRegistry is the pre-flight used by `registry add/use --check`.
+func checkRegistry(r Registry) error {
+	rep := probeRegistry(r)
+	if !rep.Reachable {
+		return fmt.Errorf("registry %s (%s) is not usable: %s", r.Name, r.URL, rep.Error)
+	}
+	if len(rep.Problems) > 0 {
+		return fmt.Errorf("registry %s has problems:\n  %s", r.Name, strings.Join(rep.Problems, "\n  "))
+	}
+	return nil
+}
+
+func init() {
+	registryDoctorCmd.Flags().BoolVar(&doctorJSON, "json", false, "output JSON")
+	registryDoctorCmd.Flags().BoolVar(&doctorNoBundles, "no-bundles", false, "skip checking bundle download URLs")
+	registryCmd.AddCommand(registryDoctorCmd)
+}
@@ -0,0 +1,65 @@
+/*
+ * // Copyright 2025 getDragon-dev
+ * // Licensed under the Apache License, Version 2.0 (the "License");
+ * // you may not use this file except in compliance with the License.
+ * // You may obtain a copy of the License at http://www.apache.org/licenses/LICENSE-2.0
+ * // Unless required by applicable law or agreed to in writing, software
+ * // distributed under the License is distributed on an "AS IS" BASIS,
+ * // WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * // See the License for the specific language governing permissions and
+ * // limitations under the License.
+ */
+
+package cmd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckBundles(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/bundles/ok.zip" {
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "here.zip"), []byte("zip"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	index := []byte(`{"blueprints": [
+  {"name": "a", "version": "1.0.0", "download_url": "bundles/ok.zip",
+   "versions": [{"version": "0.9.0", "download_url": "bundles/gone.zip"}]},
+  {"name": "b", "version": "1.0.0", "download_url": "git+https://example.com/b.git"}
+]}`)
+	remote := registrySet{Name: "remote", URL: srv.URL + "/registry.json"}
+	if err := decodeIndex(&remote, index); err != nil {
+		t.Fatal(err)
+	}
+	local := registrySet{Name: "local", URL: filepath.Join(dir, "registry.json")}
+	if err := decodeIndex(&local, []byte(`{"blueprints": [
+  {"name": "c", "version": "1.0.0", "download_url": "here.zip",
+   "versions": [{"version": "0.1.0", "download_url": "missing.zip"}]}
+]}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	broken := checkBundles([]registrySet{remote, local})
+	want := map[string]string{
+		srv.URL + "/bundles/gone.zip":     "404 Not Found",
+		filepath.Join(dir, "missing.zip"): "not found",
+	}
+	if len(broken) != len(want) {
+		t.Fatalf("broken = %+v", broken)
+	}
+	for _, b := range broken {
+		if want[b.URL] != b.Status {
+			t.Errorf("%s %s: %s %q", b.Name, b.Version, b.URL, b.Status)
+		}
+	}
+}
@@ -0,0 +1,316 @@
+/*
+ * // Copyright 2025 getDragon-dev
+ * // Licensed under the Apache License, Version 2.0 (the "License");
+ * // you may not use this file except in compliance with the License.
+ * // You may obtain a copy of the License at http://www.apache.org/licenses/LICENSE-2.0
+ * // Unless required by applicable law or agreed to in writing, software
+ * // distributed under the License is distributed on an "AS IS" BASIS,
+ * // WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * // See the License for the specific language governing permissions and
+ * // limitations under the License.
+ */
+
+package cmd
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultMaxBundleSize   = 256 << 20
+	defaultMaxUnpackedSize = 1 << 30
+)
+
+// bundleLimits returns the largest bundle dragon downloads and the most it
+// unpacks from one, guarding against runaway downloads and zip bombs.
+func bundleLimits() (bundle, unpacked int64, err error) {
+	cfg, _, err := effectiveConfig()
+	if err != nil {
+		return 0, 0, err
+	}
+	bundle, unpacked = defaultMaxBundleSize, defaultMaxUnpackedSize
+	if cfg.MaxBundleSize != "" {
+		if bundle, err = parseByteSize(cfg.MaxBundleSize); err != nil {
+			return 0, 0, fmt.Errorf("max_bundle_size: %w", err)
+		}
+	}
+	if cfg.MaxUnpackedSize != "" {
+		if unpacked, err = parseByteSize(cfg.MaxUnpackedSize); err != nil {
+			return 0, 0, fmt.Errorf("max_unpacked_size: %w", err)
+		}
+	}
+	return bundle, unpacked, nil
+}
+
+// parseByteSize reads sizes such as "512", "64KB", "200MB" or "1.5GiB".
+// Decimal and binary suffixes both count in powers of 1024.
+func parseByteSize(s string) (int64, error) {
+	t := strings.ToUpper(strings.TrimSpace(s))
+	mult := int64(1)
+	for _, u := range []struct {
+		suffix string
+		mult   int64
+	}{{"TIB", 1 << 40}, {"GIB", 1 << 30}, {"MIB", 1 << 20}, {"KIB", 1 << 10}, {"TB", 1 << 40}, {"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"T", 1 << 40}, {"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10}, {"B", 1}} {
+		if strings.HasSuffix(t, u.suffix) {
+			t, mult = strings.TrimSpace(strings.TrimSuffix(t, u.suffix)), u.mult
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(t, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return int64(n * float64(mult)), nil
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// partialMeta identifies what a .part file holds so that a later run only
+// resumes it against the same, unchanged resource.
+type partialMeta struct {
+	URL          string `json:"url"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+	ContentType  string `json:"content_type,omitempty"`
+}
+
+func downloadPath(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return filepath.Join(cacheDir(), "downloads", hex.EncodeToString(sum[:])+".part")
+}
+
+// discardDownload removes a finished download and its bookkeeping.
+func discardDownload(p string) {
+	os.Remove(p)
+	os.Remove(p + ".json")
+}
+
+// downloadBundle streams url into the download cache and returns the path of
+// the complete file and its Content-Type. An interrupted download is kept
+// and resumed with a Range request the next time, provided the server still
+// reports the same ETag or Last-Modified. size is the length the registry
+// advertises, or 0.
+func downloadBundle(ctx context.Context, url string, size, limit int64) (string, string, error) {
+	if offline {
+		return "", "", fmt.Errorf("cannot download %s in --offline mode", url)
+	}
+	if size > limit {
+		return "", "", fmt.Errorf("bundle %s is %s, over the %s limit (max_bundle_size)", url, formatBytes(size), formatBytes(limit))
+	}
+	p := downloadPath(url)
+	if err := privateDir(filepath.Dir(p)); err != nil {
+		return "", "", err
+	}
+	// Another dragon downloading the same URL would write the same .part.
+	release, err := acquireLock(ctx, p+".lock", "downloading "+url)
+	if err != nil {
+		return "", "", err
+	}
+	defer release()
+	contentType, err := downloadPart(ctx, p, url, size, limit)
+	if err != nil {
+		return "", "", err
+	}
+	// Hand the finished file over under a name of its own, so that the
+	// next download of url does not write into it while it is being read.
+	f, err := tempFile(filepath.Dir(p), "bundle-")
+	if err != nil {
+		return "", "", err
+	}
+	f.Close()
+	if err := os.Rename(p, f.Name()); err != nil {
+		removeTemp(f.Name())
+		return "", "", err
+	}
+	os.Remove(p + ".json")
+	return f.Name(), contentType, nil
+}
+
+// downloadPart fetches url into p, resuming what p already holds, and
+// returns the Content-Type. The caller holds p's lock.
+func downloadPart(ctx context.Context, p, url string, size, limit int64) (string, error) {
+	var meta partialMeta
+	var offset int64
+	if b, err := os.ReadFile(p + ".json"); err == nil && json.Unmarshal(b, &meta) == nil && meta.URL == url && (meta.ETag != "" || meta.LastModified != "") {
+		if fi, err := os.Stat(p); err == nil && (size == 0 || fi.Size() < size) {
+			offset = fi.Size()
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	applyAuth(req)
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		if meta.ETag != "" {
+			req.Header.Set("If-Range", meta.ETag)
+		} else {
+			req.Header.Set("If-Range", meta.LastModified)
+		}
+	}
+	resp, err := doHTTP(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var total int64
+	flags := os.O_CREATE | os.O_WRONLY
+	switch {
+	case resp.StatusCode == http.StatusPartialContent && offset > 0 && contentRangeStart(resp.Header.Get("Content-Range")) == offset:
+		flags |= os.O_APPEND
+		total = contentRangeTotal(resp.Header.Get("Content-Range"))
+	case (resp.StatusCode == http.StatusRequestedRangeNotSatisfiable || resp.StatusCode == http.StatusPartialContent) && offset > 0:
+		// Whatever we had no longer lines up with the resource, or the
+		// server answered with a range other than the one asked for.
+		resp.Body.Close()
+		discardDownload(p)
+		return downloadPart(ctx, p, url, size, limit)
+	case resp.StatusCode/100 == 2 && resp.StatusCode != http.StatusPartialContent:
+		flags |= os.O_TRUNC
+		offset = 0
+		if resp.ContentLength > 0 {
+			total = resp.ContentLength
+		}
+	default:
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+		return "", fmt.Errorf("GET %s: %d: %s", url, resp.StatusCode, string(b))
+	}
+	if size > 0 {
+		total = size
+	}
+	if total > limit {
+		discardDownload(p)
+		return "", fmt.Errorf("bundle %s is %s, over the %s limit (max_bundle_size)", url, formatBytes(total), formatBytes(limit))
+	}
+
+	if resp.StatusCode == http.StatusPartialContent {
+		// A 206 describes the range, not the file; keep the content type
+		// the first response reported.
+		if v := resp.Header.Get("ETag"); v != "" {
+			meta.ETag = v
+		}
+		if v := resp.Header.Get("Last-Modified"); v != "" {
+			meta.LastModified = v
+		}
+	} else {
+		meta = partialMeta{URL: url, ETag: resp.Header.Get("ETag"), LastModified: resp.Header.Get("Last-Modified"), ContentType: resp.Header.Get("Content-Type")}
+	}
+	if b, err := json.Marshal(meta); err == nil {
+		_ = writeFileAtomic(p+".json", b, 0o600)
+	}
+	f, err := os.OpenFile(p, flags, 0o600)
+	if err != nil {
+		return "", err
+	}
+	if err := f.Chmod(0o600); err != nil {
+		f.Close()
+		return "", err
+	}
+	bar := newProgress(path.Base(req.URL.Path), total, offset)
+	n, err := io.Copy(io.MultiWriter(f, bar), io.LimitReader(resp.Body, limit-offset+1))
+	bar.finish()
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	got := offset + n
+	switch {
+	case got > limit:
+		discardDownload(p)
+		return "", fmt.Errorf("bundle %s exceeds the %s limit (max_bundle_size)", url, formatBytes(limit))
+	case err != nil && (meta.ETag != "" || meta.LastModified != ""):
+		return "", fmt.Errorf("download of %s interrupted after %s: %w (run again to resume)", url, formatBytes(got), err)
+	case err != nil:
+		discardDownload(p)
+		return "", fmt.Errorf("download of %s failed: %w", url, err)
+	}
+	return meta.ContentType, nil
+}
+
+// contentRangeStart and contentRangeTotal read "bytes <start>-<end>/<total>".
+func contentRangeStart(v string) int64 {
+	v, _ = strings.CutPrefix(v, "bytes ")
+	start, _, _ := strings.Cut(v, "-")
+	n, err := strconv.ParseInt(start, 10, 64)
+	if err != nil {
+		return -1
+	}
+	return n
+}
+
+func contentRangeTotal(v string) int64 {
+	_, total, _ := strings.Cut(v, "/")
+	n, _ := strconv.ParseInt(total, 10, 64)
+	return n
+}
+
+// progress draws a single-line progress bar on stderr. It stays silent when
+// stderr is not a terminal or --quiet is set.
+type progress struct {
+	label string
+	total int64
+	done  int64
+	shown time.Time
+	on    bool
+}
+
+func newProgress(label string, total, done int64) *progress {
+	return &progress{label: label, total: total, done: done, on: !quiet && isTerminal(os.Stderr)}
+}
+
+func (p *progress) Write(b []byte) (int, error) {
+	p.done += int64(len(b))
+	if p.on && time.Since(p.shown) >= 100*time.Millisecond {
+		p.draw()
+	}
+	return len(b), nil
+}
+
+func (p *progress) draw() {
+	p.shown = time.Now()
+	if p.total <= 0 {
+		fmt.Fprintf(os.Stderr, "\r%s  %s", p.label, formatBytes(p.done))
+		return
+	}
+	const width = 30
+	frac := min(float64(p.done)/float64(p.total), 1)
+	fill := int(frac * width)
+	fmt.Fprintf(os.Stderr, "\r%s  [%s%s] %3.0f%%  %s / %s", p.label, strings.Repeat("=", fill), strings.Repeat(" ", width-fill), frac*100, formatBytes(p.done), formatBytes(p.total))
+}
+
+func (p *progress) finish() {
+	if p.on {
+		p.draw()
+		fmt.Fprintln(os.Stderr)
+	}
+}
+
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,235 @@
+/*
+ * // Copyright 2025 getDragon-dev
+ * // Licensed under the Apache License, Version 2.0 (the "License");
+ * // you may not use this file except in compliance with the License.
+ * // You may obtain a copy of the License at http://www.apache.org/licenses/LICENSE-2.0
+ * // Unless required by applicable law or agreed to in writing, software
+ * // distributed under the License is distributed on an "AS IS" BASIS,
+ * // WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * // See the License for the specific language governing permissions and
+ * // limitations under the License.
+ */
+
+package cmd
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDownloadBundleResume(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	const body = "0123456789abcdefghij"
+
+	tests := []struct {
+		name   string
+		ranged func(w http.ResponseWriter, start int)
+		full   int // requests without a Range header
+	}{
+		{"resumed", func(w http.ResponseWriter, start int) {
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, len(body)-1, len(body)))
+			w.WriteHeader(http.StatusPartialContent)
+			_, _ = w.Write([]byte(body[start:]))
+		}, 0},
+		{"wrong range restarts", func(w http.ResponseWriter, start int) {
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes 0-%d/%d", len(body)-1, len(body)))
+			w.WriteHeader(http.StatusPartialContent)
+			_, _ = w.Write([]byte(body))
+		}, 1},
+		{"unsatisfiable restarts", func(w http.ResponseWriter, start int) {
+			w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+		}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ranges, full int
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("ETag", `"v1"`)
+				var start int
+				if _, err := fmt.Sscanf(r.Header.Get("Range"), "bytes=%d-", &start); err == nil {
+					ranges++
+					tt.ranged(w, start)
+					return
+				}
+				full++
+				_, _ = w.Write([]byte(body))
+			}))
+			defer srv.Close()
+			url := srv.URL + "/b.zip"
+
+			// A previous run stopped after 8 bytes.
+			p := downloadPath(url)
+			if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(p, []byte(body[:8]), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			meta, _ := json.Marshal(partialMeta{URL: url, ETag: `"v1"`})
+			if err := os.WriteFile(p+".json", meta, 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			got, _, err := downloadBundle(context.Background(), url, 0, 1<<20)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if b, _ := os.ReadFile(got); string(b) != body {
+				t.Errorf("downloaded %q, want %q", b, body)
+			}
+			if ranges != 1 || full != tt.full {
+				t.Errorf("%d ranged and %d full requests; want 1 and %d", ranges, full, tt.full)
+			}
+
+			// The finished file is handed over; nothing is left to resume.
+			for _, left := range []string{p, p + ".json", p + ".lock"} {
+				if _, err := os.Stat(left); err == nil {
+					t.Errorf("%s left behind", filepath.Base(left))
+				}
+			}
+
+			if runtime.GOOS == "windows" {
+				return
+			}
+			for path, want := range map[string]os.FileMode{filepath.Dir(p): 0o700, got: 0o600} {
+				fi, err := os.Stat(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if fi.Mode().Perm() != want {
+					t.Errorf("%s: mode %v, want %v", path, fi.Mode().Perm(), want)
+				}
+			}
+		})
+	}
+}
+
+func TestDownloadBundleConcurrent(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	defer func(q bool) { quiet = q }(quiet)
+	quiet = true
+	body := strings.Repeat("0123456789", 1000)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		for i := 0; i < len(body); i += 1000 {
+			_, _ = w.Write([]byte(body[i : i+1000]))
+			w.(http.Flusher).Flush()
+			time.Sleep(time.Millisecond)
+		}
+	}))
+	defer srv.Close()
+
+	// Two downloads of one URL, as from two dragons, must not share a
+	// .part file.
+	var wg sync.WaitGroup
+	got := make([]string, 2)
+	for i := range got {
+		wg.Go(func() {
+			p, _, err := downloadBundle(context.Background(), srv.URL+"/b.zip", int64(len(body)), 1<<20)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			b, _ := os.ReadFile(p)
+			got[i] = string(b)
+		})
+	}
+	wg.Wait()
+	for i, g := range got {
+		if g != body {
+			t.Errorf("download %d: %d bytes, want %d", i, len(g), len(body))
+		}
+	}
+}
+
+func TestCachedBundleIntegrity(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.Create("template/main.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = w.Write([]byte("package main\n"))
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	bundle := buf.Bytes()
+	sum := sha256.Sum256(bundle)
+	bad := sha256.Sum256([]byte("something else"))
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(bundle)
+	}))
+	defer srv.Close()
+	ctx := context.Background()
+
+	refused := []struct {
+		name   string
+		digest string
+		size   int64
+	}{
+		{"digest mismatch", "sha256:" + hex.EncodeToString(bad[:]), 0},
+		{"SRI digest mismatch", "sha256-" + base64.StdEncoding.EncodeToString(bad[:]), 0},
+		{"size mismatch", "sha256:" + hex.EncodeToString(sum[:]), int64(len(bundle)) + 1},
+	}
+	for _, tt := range refused {
+		bp := resolvedBlueprint{Digest: tt.digest, Size: tt.size}
+		bp.Name, bp.Version = "api", "1.0.0"
+		if _, err := cachedBundle(ctx, bp, srv.URL+"/api.zip", ""); err == nil {
+			t.Errorf("%s: bundle accepted", tt.name)
+		}
+		var left []string
+		_ = filepath.WalkDir(cacheDir(), func(p string, d fs.DirEntry, err error) error {
+			if err == nil && !d.IsDir() {
+				left = append(left, p)
+			}
+			return nil
+		})
+		if len(left) > 0 {
+			t.Errorf("%s: left in the cache: %q", tt.name, left)
+		}
+	}
+
+	for _, digest := range []string{"sha256:" + hex.EncodeToString(sum[:]), "sha256-" + base64.StdEncoding.EncodeToString(sum[:])} {
+		bp := resolvedBlueprint{Digest: digest, Size: int64(len(bundle))}
+		bp.Name, bp.Version = "api", "1.0.0"
+		dir, err := cachedBundle(ctx, bp, srv.URL+"/api.zip", "")
+		if err != nil {
+			t.Fatalf("%s: %v", digest, err)
+		}
+		if _, err := os.Stat(filepath.Join(dir, "template", "main.go")); err != nil {
+			t.Errorf("%s: %v", digest, err)
+		}
+		if runtime.GOOS == "windows" {
+			continue
+		}
+		for path, want := range map[string]os.FileMode{blueprintCacheDir(): 0o700, filepath.Join(filepath.Dir(dir), "meta.json"): 0o600} {
+			fi, err := os.Stat(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fi.Mode().Perm() != want {
+				t.Errorf("%s: mode %v, want %v", path, fi.Mode().Perm(), want)
+			}
+		}
+	}
+}
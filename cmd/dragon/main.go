@@ -14,6 +14,10 @@ package main
 
 import "github.com/getDragon-dev/dragon-cli/cmd"
 
+// version is set at release time with -ldflags "-X main.version=...".
+var version = "dev"
+
 func main() {
+	cmd.Version = version
 	cmd.Execute()
 }
@@ -0,0 +1,360 @@
+/*
+ * // Copyright 2025 getDragon-dev
+ * // Licensed under the Apache License, Version 2.0 (the "License");
+ * // you may not use this file except in compliance with the License.
+ * // You may obtain a copy of the License at http://www.apache.org/licenses/LICENSE-2.0
+ * // Unless required by applicable law or agreed to in writing, software
+ * // distributed under the License is distributed on an "AS IS" BASIS,
+ * // WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * // See the License for the specific language governing permissions and
+ * // limitations under the License.
+ */
+
+package cmd
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// zstdMaxWindow caps the memory a .tar.zst bundle can make the decoder
+// allocate, whatever its frames declare.
+const zstdMaxWindow = 128 << 20
+
+var errBundleTooLarge = errors.New("bundle unpacks to more than the max_unpacked_size limit")
+
+// extractor writes archive members below root. Every name is checked so
+// nothing lands outside root, file modes are kept but masked to at most
+// 0755, and symlinks are only created when they resolve inside root.
+// Symlinks are created last, in finish, so no member is ever written
+// through one.
+// Members outside prefix are skipped.
+type extractor struct {
+	root      string
+	prefix    string
+	remaining int64
+	links     map[string]string
+	order     []string
+}
+
+func newExtractor(root, prefix string, limit int64) *extractor {
+	return &extractor{root: root, prefix: prefix, remaining: limit, links: map[string]string{}}
+}
+
+// member validates name and reports whether it is under the prefix.
+func (x *extractor) member(name string) (string, bool, error) {
+	n, err := cleanName(name)
+	if err != nil {
+		return "", false, err
+	}
+	return n, inPrefix(n, x.prefix), nil
+}
+
+// inPrefix reports whether the clean name n is prefix or lies below it; the
+// empty prefix matches everything.
+func inPrefix(n, prefix string) bool {
+	return prefix == "" || n == prefix || strings.HasPrefix(n, prefix+"/")
+}
+
+// cleanName validates an archive member name and returns it in clean,
+// slash-separated form.
+func cleanName(name string) (string, error) {
+	n := strings.ReplaceAll(name, `\`, "/")
+	if n == "" || path.IsAbs(n) || filepath.VolumeName(filepath.FromSlash(n)) != "" {
+		return "", fmt.Errorf("archive entry %q has an absolute path", name)
+	}
+	n = path.Clean(n)
+	if n == ".." || strings.HasPrefix(n, "../") {
+		return "", fmt.Errorf("archive entry %q escapes the destination", name)
+	}
+	return n, nil
+}
+
+func (x *extractor) dir(name string) error {
+	n, ok, err := x.member(name)
+	if err != nil || !ok {
+		return err
+	}
+	return os.MkdirAll(filepath.Join(x.root, filepath.FromSlash(n)), 0o755)
+}
+
+func (x *extractor) file(name string, mode fs.FileMode, r io.Reader) error {
+	n, ok, err := x.member(name)
+	if err != nil || !ok {
+		return err
+	}
+	out := filepath.Join(x.root, filepath.FromSlash(n))
+	if err := os.MkdirAll(filepath.Dir(out), 0o755); err != nil {
+		return err
+	}
+	// O_EXCL: a duplicate entry must not overwrite, or be redirected by,
+	// something already extracted.
+	f, err := os.OpenFile(out, os.O_CREATE|os.O_EXCL|os.O_WRONLY, mode.Perm()&0o755|0o600)
+	if err != nil {
+		return err
+	}
+	w, err := io.Copy(f, io.LimitReader(r, x.remaining+1))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	if w > x.remaining {
+		return errBundleTooLarge
+	}
+	x.remaining -= w
+	return nil
+}
+
+func (x *extractor) symlink(name, target string) error {
+	n, ok, err := x.member(name)
+	if err != nil || !ok {
+		return err
+	}
+	if _, dup := x.links[n]; dup {
+		return fmt.Errorf("archive entry %q appears twice", name)
+	}
+	x.links[n] = target
+	x.order = append(x.order, n)
+	return nil
+}
+
+// finish creates the collected symlinks. A target must be relative, stay
+// inside root, and not pass through another symlink on the way, since such
+// chains can climb out of root even when each link looks contained.
+func (x *extractor) finish() error {
+	for _, n := range x.order {
+		target := x.links[n]
+		if err := x.checkLink(n, target); err != nil {
+			return err
+		}
+		out := filepath.Join(x.root, filepath.FromSlash(n))
+		if err := os.MkdirAll(filepath.Dir(out), 0o755); err != nil {
+			return err
+		}
+		if err := os.Symlink(filepath.FromSlash(target), out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (x *extractor) checkLink(name, target string) error {
+	bad := func(why string) error {
+		return fmt.Errorf("archive symlink %s -> %s %s", name, target, why)
+	}
+	if target == "" || path.IsAbs(target) || strings.Contains(target, `\`) || filepath.VolumeName(target) != "" {
+		return bad("must be a relative path")
+	}
+	var cur []string
+	if d := path.Dir(name); d != "." {
+		cur = strings.Split(d, "/")
+	}
+	for i := range cur {
+		if _, ok := x.links[strings.Join(cur[:i+1], "/")]; ok {
+			return bad("is inside another symlink")
+		}
+	}
+	parts := strings.Split(target, "/")
+	for i, p := range parts {
+		switch p {
+		case "", ".":
+		case "..":
+			if len(cur) == 0 {
+				return bad("points outside the bundle")
+			}
+			cur = cur[:len(cur)-1]
+		default:
+			cur = append(cur, p)
+			if _, ok := x.links[strings.Join(cur, "/")]; ok && i < len(parts)-1 {
+				return bad("passes through another symlink")
+			}
+		}
+	}
+	return nil
+}
+
+// extractZip unpacks the members of zr under prefix into dst, stopping once
+// more than limit bytes have been written; the sizes a zip declares cannot
+// be trusted.
+func extractZip(zr *zip.Reader, dst, prefix string, limit int64) error {
+	var declared uint64
+	for _, zf := range zr.File {
+		if n, err := cleanName(zf.Name); err == nil && inPrefix(n, prefix) {
+			declared += zf.UncompressedSize64
+		}
+	}
+	if declared > uint64(limit) {
+		return fmt.Errorf("%w (%s declared, limit %s)", errBundleTooLarge, formatBytes(int64(declared)), formatBytes(limit))
+	}
+	x := newExtractor(dst, prefix, limit)
+	for _, zf := range zr.File {
+		mode := zf.Mode()
+		switch {
+		case mode.IsDir():
+			if err := x.dir(zf.Name); err != nil {
+				return err
+			}
+		case mode&fs.ModeSymlink != 0:
+			target, err := readZipLink(zf)
+			if err != nil {
+				return err
+			}
+			if err := x.symlink(zf.Name, target); err != nil {
+				return err
+			}
+		case mode.IsRegular():
+			rc, err := zf.Open()
+			if err != nil {
+				return err
+			}
+			err = x.file(zf.Name, mode, rc)
+			rc.Close()
+			if err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("archive entry %q has unsupported type %s", zf.Name, mode.Type())
+		}
+	}
+	return x.finish()
+}
+
+// readZipLink returns the target of a symlink member, which zip stores as
+// the member's content.
+func readZipLink(zf *zip.File) (string, error) {
+	rc, err := zf.Open()
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+	b, err := io.ReadAll(io.LimitReader(rc, 4096))
+	return string(b), err
+}
+
+// extractTar unpacks the members of the tar stream r under prefix into dst
+// with the same checks as extractZip.
+func extractTar(r io.Reader, dst, prefix string, limit int64) error {
+	x := newExtractor(dst, prefix, limit)
+	tr := tar.NewReader(r)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return x.finish()
+		}
+		if err != nil {
+			return err
+		}
+		switch h.Typeflag {
+		case tar.TypeDir:
+			err = x.dir(h.Name)
+		case tar.TypeReg:
+			err = x.file(h.Name, fs.FileMode(h.Mode), tr)
+		case tar.TypeSymlink:
+			err = x.symlink(h.Name, h.Linkname)
+		case tar.TypeXGlobalHeader, tar.TypeXHeader:
+			// git archive records the commit in a global pax header.
+		default:
+			err = fmt.Errorf("archive entry %q has unsupported type %q", h.Name, h.Typeflag)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// bundleFormat is the archive format of a bundle file.
+type bundleFormat string
+
+const (
+	formatZip    bundleFormat = "zip"
+	formatTar    bundleFormat = "tar"
+	formatTarGz  bundleFormat = "tar.gz"
+	formatTarZst bundleFormat = "tar.zst"
+)
+
+// detectFormat identifies a bundle by its leading bytes, then by the media
+// type it was served with, then by its file name.
+func detectFormat(f io.ReaderAt, mediaType, name string) (bundleFormat, error) {
+	head := make([]byte, 512)
+	n, _ := f.ReadAt(head, 0)
+	head = head[:n]
+	switch {
+	case bytes.HasPrefix(head, []byte("PK\x03\x04")), bytes.HasPrefix(head, []byte("PK\x05\x06")):
+		return formatZip, nil
+	case bytes.HasPrefix(head, []byte{0x1f, 0x8b}):
+		return formatTarGz, nil
+	case bytes.HasPrefix(head, []byte{0x28, 0xb5, 0x2f, 0xfd}):
+		return formatTarZst, nil
+	case len(head) >= 262 && string(head[257:262]) == "ustar":
+		return formatTar, nil
+	}
+	mt, _, _ := strings.Cut(strings.ToLower(mediaType), ";")
+	switch mt = strings.TrimSpace(mt); {
+	case mt == "application/zip" || strings.HasSuffix(mt, "+zip"):
+		return formatZip, nil
+	case mt == "application/gzip" || mt == "application/x-gzip" || strings.HasSuffix(mt, "+gzip"):
+		return formatTarGz, nil
+	case mt == "application/zstd" || strings.HasSuffix(mt, "+zstd"):
+		return formatTarZst, nil
+	case mt == "application/x-tar" || strings.HasSuffix(mt, ".tar"):
+		return formatTar, nil
+	}
+	lower := strings.ToLower(name)
+	for _, c := range []struct {
+		ext    string
+		format bundleFormat
+	}{{".zip", formatZip}, {".tar.gz", formatTarGz}, {".tgz", formatTarGz}, {".tar.zst", formatTarZst}, {".tzst", formatTarZst}, {".tar", formatTar}} {
+		if strings.HasSuffix(lower, c.ext) {
+			return c.format, nil
+		}
+	}
+	return "", fmt.Errorf("%s: unrecognized bundle format (want .zip, .tar.gz, .tgz, .tar.zst or .tar)", name)
+}
+
+// extractBundle unpacks the bundle file f of the given format.
+func extractBundle(f *os.File, size int64, format bundleFormat, dst, prefix string, limit int64) error {
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	switch format {
+	case formatZip:
+		zr, err := zip.NewReader(f, size)
+		if err != nil {
+			return err
+		}
+		return extractZip(zr, dst, prefix, limit)
+	case formatTar:
+		return extractTar(f, dst, prefix, limit)
+	case formatTarGz:
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+		return extractTar(zr, dst, prefix, limit)
+	case formatTarZst:
+		// extractTar enforces the unpacked size; the decoder only needs its
+		// window bounded.
+		zr, err := zstd.NewReader(f, zstd.WithDecoderConcurrency(1), zstd.WithDecoderMaxWindow(zstdMaxWindow))
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+		return extractTar(zr, dst, prefix, limit)
+	}
+	return fmt.Errorf("unsupported bundle format %q", format)
+}
@@ -0,0 +1,237 @@
+/*
+ * // Copyright 2025 getDragon-dev
+ * // Licensed under the Apache License, Version 2.0 (the "License");
+ * // you may not use this file except in compliance with the License.
+ * // You may obtain a copy of the License at http://www.apache.org/licenses/LICENSE-2.0
+ * // Unless required by applicable law or agreed to in writing, software
+ * // distributed under the License is distributed on an "AS IS" BASIS,
+ * // WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * // See the License for the specific language governing permissions and
+ * // limitations under the License.
+ */
+
+package cmd
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// member is one archive entry for the test archives; link marks a symlink.
+type member struct {
+	name, body, link string
+	mode             int64
+}
+
+func tarOf(t *testing.T, ms ...member) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, m := range ms {
+		h := &tar.Header{Name: m.name, Mode: m.mode, Size: int64(len(m.body)), Typeflag: tar.TypeReg}
+		if h.Mode == 0 {
+			h.Mode = 0o644
+		}
+		if m.link != "" {
+			h.Typeflag, h.Linkname, h.Size = tar.TypeSymlink, m.link, 0
+		}
+		if err := tw.WriteHeader(h); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(m.body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func zipOf(t *testing.T, ms ...member) *zip.Reader {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, m := range ms {
+		h := &zip.FileHeader{Name: m.name}
+		mode := fs.FileMode(m.mode)
+		if mode == 0 {
+			mode = 0o644
+		}
+		body := m.body
+		if m.link != "" {
+			mode, body = fs.ModeSymlink|0o777, m.link
+		}
+		h.SetMode(mode)
+		w, err := zw.CreateHeader(h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return zr
+}
+
+// extractBoth unpacks ms as a tar and as a zip into fresh directories below
+// a common parent, so escapes would be visible as siblings of dst.
+func extractBoth(t *testing.T, prefix string, limit int64, ms ...member) (dirs []string, errs []error) {
+	t.Helper()
+	for _, kind := range []string{"tar", "zip"} {
+		dst := filepath.Join(t.TempDir(), "dst")
+		if err := os.Mkdir(dst, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		var err error
+		if kind == "tar" {
+			err = extractTar(bytes.NewReader(tarOf(t, ms...)), dst, prefix, limit)
+		} else {
+			err = extractZip(zipOf(t, ms...), dst, prefix, limit)
+		}
+		dirs, errs = append(dirs, dst), append(errs, err)
+	}
+	return dirs, errs
+}
+
+func TestExtractRejectsEscapes(t *testing.T) {
+	tests := []struct {
+		what string
+		ms   []member
+		want string
+	}{
+		{"zip slip", []member{{name: "../evil", body: "x"}}, "escapes"},
+		{"nested zip slip", []member{{name: "a/../../evil", body: "x"}}, "escapes"},
+		{"backslash zip slip", []member{{name: `..\evil`, body: "x"}}, "escapes"},
+		{"absolute path", []member{{name: "/tmp/evil", body: "x"}}, "absolute"},
+		{"absolute symlink", []member{{name: "l", link: "/etc"}}, "relative"},
+		{"escaping symlink", []member{{name: "a/l", link: "../../etc"}}, "outside"},
+		{"symlink inside a symlink", []member{{name: "l", link: "sub"}, {name: "l/x", link: "../.."}}, "inside another symlink"},
+		{"symlink chain", []member{{name: "sub/l1", link: ".."}, {name: "l2", link: "sub/l1/../x"}}, "another symlink"},
+		{"duplicate entry", []member{{name: "a", body: "1"}, {name: "a", body: "2"}}, "exists"},
+	}
+	for _, tt := range tests {
+		dirs, errs := extractBoth(t, "", 1<<20, tt.ms...)
+		for i, err := range errs {
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("%s (%s): got %v, want an error containing %q", tt.what, []string{"tar", "zip"}[i], err, tt.want)
+			}
+			if _, err := os.Lstat(filepath.Join(filepath.Dir(dirs[i]), "evil")); err == nil {
+				t.Errorf("%s: file written outside the destination", tt.what)
+			}
+		}
+	}
+}
+
+func TestExtractSymlinksAndPrefix(t *testing.T) {
+	dirs, errs := extractBoth(t, "template", 1<<20,
+		member{name: "manifest.yaml", body: "name: x"},
+		member{name: "template/a/file.txt", body: "hello"},
+		member{name: "template/a/link", link: "file.txt"},
+		member{name: "template/up", link: "a/../a/file.txt"},
+	)
+	for i, dst := range dirs {
+		if errs[i] != nil {
+			t.Fatal(errs[i])
+		}
+		if _, err := os.Stat(filepath.Join(dst, "manifest.yaml")); err == nil {
+			t.Error("member outside the prefix was extracted")
+		}
+		for _, p := range []string{"template/a/link", "template/up"} {
+			b, err := os.ReadFile(filepath.Join(dst, p))
+			if err != nil || string(b) != "hello" {
+				t.Errorf("%s: got %q, %v", p, b, err)
+			}
+		}
+	}
+}
+
+func TestExtractMasksModes(t *testing.T) {
+	dirs, errs := extractBoth(t, "", 1<<20,
+		member{name: "setuid", body: "x", mode: 0o4777},
+		member{name: "exec", body: "x", mode: 0o755},
+		member{name: "private", body: "x", mode: 0o400},
+	)
+	for i, dst := range dirs {
+		if errs[i] != nil {
+			t.Fatal(errs[i])
+		}
+		for name, check := range map[string]func(fs.FileMode) bool{
+			"setuid":  func(m fs.FileMode) bool { return m&(fs.ModeSetuid|0o022) == 0 },
+			"exec":    func(m fs.FileMode) bool { return m.Perm()&0o100 != 0 && m.Perm()&0o022 == 0 },
+			"private": func(m fs.FileMode) bool { return m.Perm()&0o600 == 0o600 && m.Perm()&0o077 == 0 },
+		} {
+			fi, err := os.Stat(filepath.Join(dst, name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !check(fi.Mode()) {
+				t.Errorf("%s: mode %v", name, fi.Mode())
+			}
+		}
+	}
+}
+
+func TestExtractLimit(t *testing.T) {
+	_, errs := extractBoth(t, "", 10, member{name: "big", body: strings.Repeat("x", 11)})
+	for _, err := range errs {
+		if !errors.Is(err, errBundleTooLarge) {
+			t.Errorf("got %v, want errBundleTooLarge", err)
+		}
+	}
+}
+
+func TestExtractBundleZstd(t *testing.T) {
+	var buf bytes.Buffer
+	zw, err := zstd.NewWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := zw.Write(tarOf(t, member{name: "t/a.txt", body: strings.Repeat("a", 100)})); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	p := filepath.Join(t.TempDir(), "b.tar.zst")
+	if err := os.WriteFile(p, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	for _, tt := range []struct {
+		limit int64
+		err   error
+	}{{1 << 20, nil}, {50, errBundleTooLarge}} {
+		dst := t.TempDir()
+		err := extractBundle(f, int64(buf.Len()), formatTarZst, dst, "", tt.limit)
+		if !errors.Is(err, tt.err) {
+			t.Fatalf("limit %d: got %v, want %v", tt.limit, err, tt.err)
+		}
+		if tt.err == nil {
+			if b, err := os.ReadFile(filepath.Join(dst, "t", "a.txt")); err != nil || len(b) != 100 {
+				t.Errorf("a.txt: %d bytes, %v", len(b), err)
+			}
+		}
+	}
+}
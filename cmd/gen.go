@@ -13,15 +13,13 @@
 package cmd
 
 import (
-	"archive/zip"
-	"bufio"
-	"bytes"
+	"context"
+	"errors"
 	"fmt"
-	"io"
-	"log"
-	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	coretempl "github.com/getDragon-dev/dragon-core/templates"
@@ -30,81 +28,170 @@ import (
 )
 
 var (
-	genName        string
-	genOut         string
-	genRouter      string
-	genDB          string
-	genRemote      bool
-	genVersion     string
-	genVarsFile    string
-	genSets        []string
-	genInteractive bool
+	genName          string
+	genOut           string
+	genRouter        string
+	genDB            string
+	genRemote        bool
+	genVersion       string
+	genVarsFile      string
+	genSets          []string
+	genInteractive   bool
+	genRequireDigest bool
+	genTemplateRoot  string
+	allowYanked      bool
+	// showYanked lets resolution fall back to yanked releases when nothing
+	// else is published; only informational commands set it.
+	showYanked bool
 )
 
 var genCmd = &cobra.Command{Use: "gen", Short: "Generate a project from a blueprint", ValidArgsFunction: completeBlueprints,
-	Run: func(cmd *cobra.Command, args []string) {
+	Long: `Generate a project from a blueprint.
+
+Without --remote the blueprint's source is looked up as <root>/<path>/template
+in each search root: DRAGON_BLUEPRINT_PATH, blueprint_paths from .dragon.yaml
+and the user config, then the directory of a local registry file. When none
+has it and the registry lists a bundle, the bundle is downloaded instead.
+
+Template variables come from the user config and .dragon.yaml vars, then
+--vars, then --set. When the blueprint's manifest.yaml declares variables,
+values are converted to the declared types, checked, and defaults filled in;
+see "dragon validate --help" for the schema.
+
+On a terminal, gen asks for required variables that have no value and no
+default, and --interactive asks for every variable. With --no-input or
+--yes, or when stdin is not a terminal, nothing is asked and gen fails
+listing every missing required variable.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
 		if genName == "" {
-			log.Fatal("missing --blueprint/-b name")
+			return errors.New("missing --blueprint/-b name")
 		}
-		bp, sourceURL, err := findBlueprint(genName)
+		bp, err := resolveBlueprint(genName, genVersion)
 		if err != nil {
-			log.Fatal(err)
+			return err
 		}
-		if genVersion != "" && !satisfies(bp.Version, genVersion) {
-			log.Fatalf("blueprint version %s does not satisfy constraint %s", bp.Version, genVersion)
+		if bp.Deprecated.active() {
+			fmt.Fprintf(os.Stderr, "warning: %s %s is %s\n", bp.Name, bp.Version, bp.Deprecated)
+		}
+		if bp.Yanked {
+			fmt.Fprintf(os.Stderr, "warning: %s %s has been yanked\n", bp.Name, bp.Version)
 		}
 
+		download := func() (string, error) {
+			if bp.Digest == "" && genRequireDigest {
+				return "", fmt.Errorf("blueprint %s has no digest in %s and --require-digest is set", bp.Name, bp.Source)
+			}
+			return downloadAndExtractTemplate(bp)
+		}
+		root, err := templateRoot(bp)
+		if err != nil {
+			return err
+		}
 		var src string
 		if genRemote {
-			tmp, err := downloadAndExtractTemplate(bp.DownloadURL)
-			if err != nil {
-				log.Fatal(err)
+			if src, err = download(); err != nil {
+				return err
 			}
-			src = tmp
 		} else {
-			src = filepath.Join("../dragon-blueprints", bp.Path, "template")
-			if _, err := os.Stat(src); err != nil {
-				log.Fatalf("template not found locally: %s (use --remote to download from %s)", src, sourceURL)
+			var tried []string
+			src, tried, err = findLocalTemplate(bp, root)
+			if err != nil {
+				return err
+			}
+			if src == "" {
+				notFound := fmt.Sprintf("template for %s not found locally; tried:\n  %s", bp.Name, strings.Join(tried, "\n  "))
+				if bp.DownloadURL == "" {
+					return errors.New(notFound)
+				}
+				fmt.Fprintf(os.Stderr, "%s not found locally; downloading %s\n", bp.Name, bundleLocation(bp.DownloadURL, bp.Source))
+				if src, err = download(); err != nil {
+					return fmt.Errorf("%s\nand the download failed: %w", notFound, err)
+				}
 			}
 		}
 
-		ctx := coretempl.Context{"Name": genName}
-		if genName == "api-service" {
-			ctx["Router"], ctx["DB"] = genRouter, genDB
+		declared, _, err := blueprintVariables(src, root)
+		if err != nil {
+			return err
 		}
-		vars, err := loadUserVars(genVarsFile, genSets)
+		// --router and --db predate manifest variables; they are plain
+		// --set aliases now, and an explicit --set still wins.
+		sets := genSets
+		if genDB != "" {
+			sets = append([]string{"DB=" + genDB}, sets...)
+		}
+		if genRouter != "" {
+			sets = append([]string{"Router=" + genRouter}, sets...)
+		}
+		vars, given, err := loadUserVars(genVarsFile, sets)
 		if err != nil {
-			log.Fatal(err)
+			return err
+		}
+		legacyDefaults(declared, vars)
+		if len(declared) > 0 {
+			for _, k := range given {
+				if !slices.ContainsFunc(declared, func(v variable) bool { return v.Name == k }) {
+					fmt.Fprintf(os.Stderr, "warning: %s does not declare a variable %s\n", bp.Name, k)
+				}
+			}
+		}
+		// On a terminal, missing required values are asked for; with
+		// --interactive every declared variable is. --no-input and --yes
+		// never ask, so a missing value fails listing all of them.
+		var ask askFunc
+		switch {
+		case canPrompt():
+			ask = newPrompter().askVariables(genInteractive)
+		case genInteractive && !noInput && !assumeYes:
+			fmt.Fprintln(os.Stderr, "warning: not prompting because stdin is not a terminal")
 		}
+		if err := resolveVariables(declared, vars, ask); err != nil {
+			if errors.Is(err, errInterrupted) {
+				// Ctrl-C at a prompt; exit quietly as on SIGINT.
+				cmd.SilenceErrors, cmd.SilenceUsage = true, true
+				return err
+			}
+			return fmt.Errorf("%s %s: %w", bp.Name, bp.Version, err)
+		}
+		ctx := coretempl.Context{"Name": bp.Name}
 		for k, v := range vars {
 			ctx[k] = v
 		}
-		if genInteractive {
-			promptAPI(ctx)
-		}
 
 		if err := coretempl.RenderDir(src, genOut, ctx); err != nil {
-			log.Fatal(err)
+			return err
 		}
-		fmt.Println("Generated", genName, "into", genOut)
+		fmt.Println("Generated", bp.Name, bp.Version, "into", genOut)
+		return nil
 	},
 }
 
 func init() {
-	genCmd.Flags().StringVarP(&genName, "blueprint", "b", "", "Blueprint name (required)")
+	genCmd.Flags().StringVarP(&genName, "blueprint", "b", "", "Blueprint as [registry/]name[@constraint] (required)")
 	genCmd.Flags().StringVarP(&genOut, "out", "o", ".", "Output directory")
-	genCmd.Flags().StringVar(&genRouter, "router", "servemux", "Router: chi|gorilla|httprouter|servemux (api-service only)")
-	genCmd.Flags().StringVar(&genDB, "db", "sqlite-native", "DB: sqlite-native|sqlite-gorm|postgres-native|postgres-gorm|mysql-native|mysql-gorm (api-service only)")
-	genCmd.Flags().BoolVar(&genRemote, "remote", false, "Download blueprint from release asset instead of local repo")
-	genCmd.Flags().StringVar(&genVersion, "version", "", "Version constraint (e.g. ^1.0, >=1.2.3)")
+	genCmd.Flags().StringVar(&genRouter, "router", "", "Same as --set Router=<value> (servemux for blueprints that declare no variables)")
+	genCmd.Flags().StringVar(&genDB, "db", "", "Same as --set DB=<value> (sqlite-native for blueprints that declare no variables)")
+	_ = genCmd.Flags().MarkDeprecated("router", "use --set Router=<value>")
+	_ = genCmd.Flags().MarkDeprecated("db", "use --set DB=<value>")
+	genCmd.Flags().BoolVar(&genRemote, "remote", false, "Always download the bundle instead of looking for a local blueprint first")
+	genCmd.Flags().StringVar(&genVersion, "version", "", "Version range (e.g. ^1.0, >=1.2 <2.0, 1.x, ^1.0 || ^2.0)")
 	genCmd.Flags().StringVar(&genVarsFile, "vars", "", "YAML/JSON file with template variables")
-	genCmd.Flags().StringSliceVar(&genSets, "set", nil, "Set template var (key=value), repeatable")
-	genCmd.Flags().BoolVar(&genInteractive, "interactive", false, "Prompt for common variables when missing")
+	genCmd.Flags().StringArrayVar(&genSets, "set", nil, "Set template var (key=value), repeatable")
+	genCmd.Flags().BoolVar(&genInteractive, "interactive", false, "Prompt for every variable the blueprint declares that was not given")
+	genCmd.Flags().BoolVar(&genRequireDigest, "require-digest", false, "Refuse remote bundles whose registry entry has no digest")
+	genCmd.Flags().StringVar(&genTemplateRoot, "template-root", "", "Directory inside the bundle that holds the template (default \"template\", or the registry's template_root)")
+	genCmd.Flags().BoolVar(&allowYanked, "allow-yanked", false, "Allow a yanked release when its exact version is pinned")
 	_ = genCmd.MarkFlagRequired("blueprint")
+	_ = genCmd.RegisterFlagCompletionFunc("blueprint", completeBlueprints)
 	rootCmd.AddCommand(genCmd)
 }
 
+// completeBlueprints offers bare blueprint names as well as their
+// registry-qualified forms.
 func completeBlueprints(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
 	sets, err := loadAllRegistries()
 	if err != nil {
 		return nil, cobra.ShellCompDirectiveError
@@ -113,100 +200,238 @@ func completeBlueprints(cmd *cobra.Command, args []string, toComplete string) ([
 	out := []string{}
 	for _, s := range sets {
 		for _, bp := range s.DB.Blueprints {
-			if seen[bp.Name] {
-				continue
+			for _, c := range []string{bp.Name, s.Name + "/" + bp.Name} {
+				if !seen[c] && strings.HasPrefix(c, toComplete) {
+					out = append(out, c)
+				}
+				seen[c] = true
 			}
-			if toComplete == "" || strings.HasPrefix(bp.Name, toComplete) {
-				out = append(out, bp.Name)
-			}
-			seen[bp.Name] = true
 		}
 	}
 	return out, cobra.ShellCompDirectiveNoFileComp
 }
 
-func downloadAndExtractTemplate(url string) (string, error) {
-	resp, err := http.Get(url)
+// defaultTemplateRoot is the directory inside a bundle that holds the
+// template, unless the index entry or --template-root says otherwise.
+const defaultTemplateRoot = "template"
+
+// legacyVarDefaults are the values the --router and --db flags defaulted to
+// before blueprints declared their variables in manifest.yaml.
+var legacyVarDefaults = map[string]string{"Router": "servemux", "DB": "sqlite-native"}
+
+// legacyDefaults fills in legacyVarDefaults for a blueprint whose manifest
+// declares no variables, so blueprints written against the old flags keep
+// rendering the same. A blueprint with a schema gets its defaults from it.
+func legacyDefaults(declared []variable, vars map[string]any) {
+	if len(declared) > 0 {
+		return
+	}
+	for k, def := range legacyVarDefaults {
+		if _, ok := vars[k]; !ok {
+			vars[k] = def
+		}
+	}
+}
+
+// templateRoot picks the template root for bp and returns it as a clean,
+// slash-separated prefix; "" means the whole bundle.
+func templateRoot(bp resolvedBlueprint) (string, error) {
+	root := defaultTemplateRoot
+	switch {
+	case genTemplateRoot != "":
+		root = genTemplateRoot
+	case bp.TemplateRoot != "":
+		root = bp.TemplateRoot
+	}
+	n, err := cleanName(root)
+	if err != nil {
+		return "", fmt.Errorf("template root %q must be a relative path inside the bundle", root)
+	}
+	if n == "." {
+		return "", nil
+	}
+	return n, nil
+}
+
+// bundleLocation resolves a relative download_url against the registry it
+// was listed in.
+func bundleLocation(loc, source string) string {
+	if loc == "" || isRemote(loc) || isGit(loc) || isOCI(loc) || strings.HasPrefix(loc, "file://") || filepath.IsAbs(loc) {
+		return loc
+	}
+	switch {
+	case isRemote(source):
+		base, err := url.Parse(source)
+		if err != nil {
+			return loc
+		}
+		ref, err := url.Parse(loc)
+		if err != nil {
+			return loc
+		}
+		return base.ResolveReference(ref).String()
+	case source != "" && !isGit(source) && !isOCI(source):
+		return filepath.Join(filepath.Dir(source), filepath.FromSlash(loc))
+	}
+	return loc
+}
+
+// localBundlePath returns the filesystem path of a file:// or plain-path
+// bundle location.
+func localBundlePath(loc string) (string, bool) {
+	if strings.HasPrefix(loc, "file://") {
+		u, err := url.Parse(loc)
+		if err != nil {
+			return "", false
+		}
+		return filepath.FromSlash(u.Path), true
+	}
+	if isRemote(loc) || isGit(loc) || isOCI(loc) {
+		return "", false
+	}
+	return loc, true
+}
+
+// downloadAndExtractTemplate makes bp's template available on disk and
+// returns its directory. Bundles may be zip, tar, tar.gz or tar.zst archives
+// served over HTTP or OCI, git sources, local archives, or plain directories,
+// which are used in place. Archives are unpacked once into the blueprint
+// cache and reused from there.
+func downloadAndExtractTemplate(bp resolvedBlueprint) (string, error) {
+	loc, digest := bundleLocation(bp.DownloadURL, bp.Source), bp.Digest
+	root, err := templateRoot(bp)
+	if err != nil {
+		return "", err
+	}
+	if isGit(loc) {
+		return exportGitTemplate(loc, digest, root)
+	}
+	var local string
+	if lp, ok := localBundlePath(loc); ok {
+		fi, err := os.Stat(lp)
+		if err != nil {
+			return "", fmt.Errorf("bundle %s: %w", loc, err)
+		}
+		if fi.IsDir() {
+			if digest != "" {
+				return "", fmt.Errorf("bundle %s: digests are not supported for directory bundles", loc)
+			}
+			return templateDir(loc, lp, root)
+		}
+		local = lp
+	}
+	dir, err := cachedBundle(context.Background(), bp, loc, local)
 	if err != nil {
 		return "", err
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode/100 != 2 {
-		b, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("GET %s: %d: %s", url, resp.StatusCode, string(b))
+	return templateDir(loc, dir, root)
+}
+
+// templateDir returns the template root below dir, checking that it exists.
+func templateDir(loc, dir, root string) (string, error) {
+	tpl := filepath.Join(dir, filepath.FromSlash(root))
+	if fi, err := os.Stat(tpl); err != nil || !fi.IsDir() {
+		if root == "" {
+			root = "."
+		}
+		return "", fmt.Errorf("bundle %s: no %s/ directory (set template_root in the index or pass --template-root)", loc, root)
+	}
+	return tpl, nil
+}
+
+// fetchBundle downloads the bundle at loc to a local file and returns it with
+// the media type it was served as. oci:// and oci-layout:// references go
+// through the distribution API. The caller removes the file with
+// discardDownload.
+func fetchBundle(ctx context.Context, loc string, size, limit int64) (string, string, error) {
+	if !isOCI(loc) {
+		return downloadBundle(ctx, loc, size, limit)
+	}
+	if size > limit {
+		return "", "", fmt.Errorf("bundle %s is %s, over the %s limit (max_bundle_size)", loc, formatBytes(size), formatBytes(limit))
+	}
+	f, err := tempFile("", "dragon-bundle-")
+	if err != nil {
+		return "", "", err
+	}
+	layer, err := pullOCI(ctx, loc, f, limit)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		removeTemp(f.Name())
+		return "", "", err
+	}
+	return f.Name(), layer.MediaType, nil
+}
+
+// exportGitTemplate checks out a git+ bundle location, which names the
+// blueprint directory holding the template root. Git locations are pinned by
+// ref, so a registry digest cannot be checked against them.
+func exportGitTemplate(loc, digest, root string) (string, error) {
+	if digest != "" {
+		return "", fmt.Errorf("bundle %s: digests are not supported for git sources; pin a commit with ?ref= instead", loc)
 	}
-	b, err := io.ReadAll(resp.Body)
+	g, err := parseGitSource(loc)
 	if err != nil {
 		return "", err
 	}
-	zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	_, maxUnpacked, err := bundleLimits()
 	if err != nil {
 		return "", err
 	}
-	dst, err := os.MkdirTemp("", "dragon-tpl-")
+	dst, err := tempDir("", "dragon-tpl-")
 	if err != nil {
 		return "", err
 	}
-	for _, f := range zr.File {
-		if f.FileInfo().IsDir() {
-			continue
-		}
-		if !strings.HasPrefix(f.Name, "template/") {
-			continue
-		}
-		out := filepath.Join(dst, f.Name)
-		if err := os.MkdirAll(filepath.Dir(out), 0o755); err != nil {
-			return "", err
-		}
-		rc, err := f.Open()
-		if err != nil {
-			return "", err
-		}
-		data, _ := io.ReadAll(rc)
-		rc.Close()
-		if err := os.WriteFile(out, data, 0o644); err != nil {
-			return "", err
-		}
+	// Export the whole blueprint directory so its manifest.yaml comes along.
+	if err := g.export(context.Background(), dst, "", maxUnpacked); err != nil {
+		removeTemp(dst)
+		return "", err
+	}
+	tpl, err := templateDir(loc, dst, root)
+	if err != nil {
+		removeTemp(dst)
 	}
-	return filepath.Join(dst, "template"), nil
+	return tpl, err
 }
 
-func loadUserVars(file string, sets []string) (map[string]any, error) {
+// loadUserVars merges configured vars, the --vars file and --set values,
+// later ones winning, and also returns the names given on the command line.
+func loadUserVars(file string, sets []string) (map[string]any, []string, error) {
 	vars := map[string]any{}
+	// Configured vars (user config, then .dragon.yaml) are the defaults.
+	if cfg, _, err := effectiveConfig(); err == nil {
+		for k, v := range cfg.Vars {
+			vars[k] = v
+		}
+	}
+	var given []string
 	if file != "" {
 		b, err := os.ReadFile(file)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
-		if err := yaml.Unmarshal(b, &vars); err != nil {
-			return nil, err
+		fileVars := map[string]any{}
+		if err := yaml.Unmarshal(b, &fileVars); err != nil {
+			return nil, nil, err
+		}
+		for k, v := range fileVars {
+			vars[k] = v
+			given = append(given, k)
 		}
 	}
 	for _, kv := range sets {
 		parts := strings.SplitN(kv, "=", 2)
 		if len(parts) != 2 {
-			return nil, fmt.Errorf("bad --set %q, want key=value", kv)
-		}
-		vars[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
-	}
-	return vars, nil
-}
-
-func promptAPI(ctx map[string]any) {
-	in := bufio.NewReader(os.Stdin)
-	ask := func(k, def string) string {
-		if v, ok := ctx[k]; ok && fmt.Sprint(v) != "" {
-			return fmt.Sprint(v)
+			return nil, nil, fmt.Errorf("bad --set %q, want key=value", kv)
 		}
-		fmt.Printf("%s [%s]: ", k, def)
-		s, _ := in.ReadString('\n')
-		s = strings.TrimSpace(s)
-		if s == "" {
-			return def
+		k := strings.TrimSpace(parts[0])
+		vars[k] = strings.TrimSpace(parts[1])
+		if !slices.Contains(given, k) {
+			given = append(given, k)
 		}
-		return s
 	}
-	ctx["Module"] = ask("Module", fmt.Sprint(ctx["Module"]))
-	ctx["Router"] = ask("Router", fmt.Sprint(ctx["Router"]))
-	ctx["DB"] = ask("DB", fmt.Sprint(ctx["DB"]))
+	slices.Sort(given)
+	return vars, given, nil
 }
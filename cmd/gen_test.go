@@ -0,0 +1,37 @@
+/*
+ * // Copyright 2025 getDragon-dev
+ * // Licensed under the Apache License, Version 2.0 (the "License");
+ * // you may not use this file except in compliance with the License.
+ * // You may obtain a copy of the License at http://www.apache.org/licenses/LICENSE-2.0
+ * // Unless required by applicable law or agreed to in writing, software
+ * // distributed under the License is distributed on an "AS IS" BASIS,
+ * // WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * // See the License for the specific language governing permissions and
+ * // limitations under the License.
+ */
+
+package cmd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLegacyDefaults(t *testing.T) {
+	tests := []struct {
+		name     string
+		declared []variable
+		vars     map[string]any
+		want     map[string]any
+	}{
+		{"no schema", nil, map[string]any{}, map[string]any{"Router": "servemux", "DB": "sqlite-native"}},
+		{"no schema, --router given", nil, map[string]any{"Router": "chi"}, map[string]any{"Router": "chi", "DB": "sqlite-native"}},
+		{"schema", []variable{{Name: "Port"}}, map[string]any{}, map[string]any{}},
+	}
+	for _, tt := range tests {
+		legacyDefaults(tt.declared, tt.vars)
+		if !reflect.DeepEqual(tt.vars, tt.want) {
+			t.Errorf("%s: vars = %v, want %v", tt.name, tt.vars, tt.want)
+		}
+	}
+}
@@ -19,16 +19,20 @@ import (
 	"github.com/spf13/cobra"
 )
 
-var getCmd = &cobra.Command{Use: "get <blueprint>", Args: cobra.ExactArgs(1), Short: "Fetch and render a blueprint (remote)",
+var getCmd = &cobra.Command{Use: "get [registry/]<blueprint>[@version]", Args: cobra.ExactArgs(1), Short: "Fetch and render a blueprint (remote)", ValidArgsFunction: completeBlueprints,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		name := args[0]
+		name := parseRef(args[0]).Name
 		out := filepath.Join(".", name)
-		genName = name
+		genName = args[0]
 		genOut = out
 		genRemote = true
-		fmt.Printf("Generating %s into %s using remote asset...\n", name, out)
+		fmt.Printf("Generating %s into %s using remote asset...\n", args[0], out)
 		return genCmd.RunE(cmd, []string{})
 	},
 }
 
-func init() { rootCmd.AddCommand(getCmd) }
+func init() {
+	getCmd.Flags().StringVar(&genTemplateRoot, "template-root", "", "Directory inside the bundle that holds the template")
+	getCmd.Flags().BoolVar(&allowYanked, "allow-yanked", false, "Allow a yanked release when its exact version is pinned")
+	rootCmd.AddCommand(getCmd)
+}
@@ -0,0 +1,252 @@
+/*
+ * // Copyright 2025 getDragon-dev
+ * // Licensed under the Apache License, Version 2.0 (the "License");
+ * // you may not use this file except in compliance with the License.
+ * // You may obtain a copy of the License at http://www.apache.org/licenses/LICENSE-2.0
+ * // Unless required by applicable law or agreed to in writing, software
+ * // distributed under the License is distributed on an "AS IS" BASIS,
+ * // WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * // See the License for the specific language governing permissions and
+ * // limitations under the License.
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// gitSource is a location of the form
+//
+//	git+https://host/org/repo.git//sub/path?ref=v1.2.0
+//	git+file:///srv/repos/blueprints.git//registry.json?ref=main
+//
+// Path is relative to the repository root and Ref may be a branch, tag or
+// commit; it defaults to the remote HEAD.
+type gitSource struct {
+	Repo string
+	Path string
+	Ref  string
+}
+
+func isGit(loc string) bool { return strings.HasPrefix(loc, "git+") }
+
+func parseGitSource(loc string) (gitSource, error) {
+	u, err := url.Parse(strings.TrimPrefix(loc, "git+"))
+	if err != nil {
+		return gitSource{}, err
+	}
+	if u.Scheme == "" {
+		return gitSource{}, fmt.Errorf("git location %q needs a scheme, e.g. git+https:// or git+file://", loc)
+	}
+	g := gitSource{Ref: u.Query().Get("ref")}
+	if i := strings.Index(u.Path, "//"); i >= 0 {
+		u.Path, g.Path = u.Path[:i], path.Clean(u.Path[i+2:])
+		if g.Path == "." || strings.HasPrefix(g.Path, "../") || g.Path == ".." {
+			return gitSource{}, fmt.Errorf("git location %q: bad subdirectory", loc)
+		}
+	}
+	u.RawQuery, u.Fragment, u.RawPath = "", "", ""
+	g.Repo = u.String()
+	return g, nil
+}
+
+// gitTimeout bounds the git commands behind one lookup. It takes the place
+// of the caller's deadline, which for registries is --registry-timeout: that
+// is sized for a single HTTP request, not for cloning a repository.
+const gitTimeout = 10 * time.Minute
+
+// gitContext keeps the cancellation of ctx but swaps its deadline for
+// gitTimeout.
+func gitContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	gctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), gitTimeout)
+	stop := context.AfterFunc(ctx, func() {
+		if errors.Is(ctx.Err(), context.Canceled) {
+			cancel()
+		}
+	})
+	return gctx, func() {
+		stop()
+		cancel()
+	}
+}
+
+func gitCommand(ctx context.Context, args ...string) (*exec.Cmd, *bytes.Buffer, error) {
+	if _, err := exec.LookPath("git"); err != nil {
+		return nil, nil, errors.New("git not found in PATH; it is required for git+ locations")
+	}
+	var stderr bytes.Buffer
+	c := exec.CommandContext(ctx, "git", args...)
+	c.Stderr = &stderr
+	c.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	return c, &stderr, nil
+}
+
+func gitError(args []string, err error, stderr *bytes.Buffer) error {
+	return fmt.Errorf("git %s: %v: %s", args[0], err, strings.TrimSpace(stderr.String()))
+}
+
+func runGit(ctx context.Context, args ...string) ([]byte, error) {
+	c, stderr, err := gitCommand(ctx, args...)
+	if err != nil {
+		return nil, err
+	}
+	var stdout bytes.Buffer
+	c.Stdout = &stdout
+	if err := c.Run(); err != nil {
+		return nil, gitError(args, err, stderr)
+	}
+	return stdout.Bytes(), nil
+}
+
+func gitMirrorDir(repo string) string {
+	sum := sha256.Sum256([]byte(repo))
+	return filepath.Join(cacheDir(), "git", hex.EncodeToString(sum[:])+".git")
+}
+
+// resolve makes sure a bare mirror of the repository exists in the cache and
+// returns it together with the commit g.Ref points at. Mirrors are fetched
+// incrementally once they are older than the cache TTL, or when the ref is
+// unknown locally; --offline never touches the network. The mirror stays
+// locked until release is called, so that another dragon neither fetches
+// into it nor removes it with cache clean while git reads from it.
+func (g gitSource) resolve(ctx context.Context) (dir, commit string, release func(), err error) {
+	dir = gitMirrorDir(g.Repo)
+	// A mirror of a private repository is as private as its credentials.
+	if err := privateDir(filepath.Dir(dir)); err != nil {
+		return "", "", nil, err
+	}
+	release, err = acquireLock(ctx, dir+".lock", "using the mirror of "+g.Repo)
+	if err != nil {
+		return "", "", nil, err
+	}
+	if commit, err = g.update(ctx, dir); err != nil {
+		release()
+		return "", "", nil, err
+	}
+	return dir, commit, release, nil
+}
+
+// update clones or fetches the mirror in dir as needed and returns the
+// commit g.Ref points at. The caller holds the mirror's lock.
+func (g gitSource) update(ctx context.Context, dir string) (string, error) {
+	ctx, cancel := gitContext(ctx)
+	defer cancel()
+	stamp := filepath.Join(dir, "dragon-fetched")
+	if _, err := os.Stat(dir); err != nil {
+		if offline {
+			return "", fmt.Errorf("git repository %s is not cached (run once without --offline)", g.Repo)
+		}
+		tmp, err := tempDir(filepath.Dir(dir), "clone-")
+		if err != nil {
+			return "", err
+		}
+		defer removeTemp(tmp)
+		if _, err := runGit(ctx, "clone", "--mirror", "--quiet", g.Repo, tmp); err != nil {
+			return "", err
+		}
+		if err := os.Rename(tmp, dir); err != nil && !errors.Is(err, os.ErrExist) {
+			// Another invocation may have won the race; use its clone.
+			if _, statErr := os.Stat(dir); statErr != nil {
+				return "", err
+			}
+		}
+		_ = os.WriteFile(stamp, nil, 0o600)
+	} else if fi, err := os.Stat(stamp); !offline && (err != nil || time.Since(fi.ModTime()) >= cacheTTL()) {
+		if err := g.fetch(ctx, dir, stamp); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: %v; using cached copy of %s\n", err, g.Repo)
+		}
+	}
+	ref := g.Ref
+	if ref == "" {
+		ref = "HEAD"
+	}
+	commit, err := runGit(ctx, "--git-dir", dir, "rev-parse", "--verify", "--quiet", ref+"^{commit}")
+	if err != nil && !offline {
+		// The ref may be newer than our last fetch.
+		if ferr := g.fetch(ctx, dir, stamp); ferr == nil {
+			commit, err = runGit(ctx, "--git-dir", dir, "rev-parse", "--verify", "--quiet", ref+"^{commit}")
+		}
+	}
+	if err != nil {
+		return "", fmt.Errorf("ref %q not found in %s", ref, g.Repo)
+	}
+	return strings.TrimSpace(string(commit)), nil
+}
+
+func (g gitSource) fetch(ctx context.Context, dir, stamp string) error {
+	if _, err := runGit(ctx, "--git-dir", dir, "fetch", "--prune", "--quiet", "origin"); err != nil {
+		return err
+	}
+	now := time.Now()
+	if err := os.Chtimes(stamp, now, now); err != nil {
+		return os.WriteFile(stamp, nil, 0o600)
+	}
+	return nil
+}
+
+// readFile returns the contents of name, relative to the repository root,
+// at g.Ref.
+func (g gitSource) readFile(ctx context.Context, name string) ([]byte, error) {
+	ctx, cancel := gitContext(ctx)
+	defer cancel()
+	dir, commit, release, err := g.resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return runGit(ctx, "--git-dir", dir, "show", commit+":"+name)
+}
+
+// export writes the tree at g.Path into dst. The archive is streamed into
+// the extractor so that limit is enforced before the tree is in memory.
+func (g gitSource) export(ctx context.Context, dst, prefix string, limit int64) error {
+	ctx, cancel := gitContext(ctx)
+	defer cancel()
+	dir, commit, release, err := g.resolve(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+	treeish := commit
+	if g.Path != "" {
+		treeish += ":" + g.Path
+	}
+	args := []string{"--git-dir", dir, "archive", "--format=tar", treeish}
+	c, stderr, err := gitCommand(ctx, args...)
+	if err != nil {
+		return err
+	}
+	out, err := c.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := c.Start(); err != nil {
+		return gitError(args, err, stderr)
+	}
+	if err := extractTar(out, dst, prefix, limit); err != nil {
+		// Stop git instead of leaving it blocked on a full pipe.
+		_ = c.Process.Kill()
+		_ = c.Wait()
+		return err
+	}
+	// Let git write the padding after the end-of-archive marker.
+	_, _ = io.Copy(io.Discard, out)
+	if err := c.Wait(); err != nil {
+		return gitError(args, err, stderr)
+	}
+	return nil
+}
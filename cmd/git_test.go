@@ -0,0 +1,161 @@
+/*
+ * // Copyright 2025 getDragon-dev
+ * // Licensed under the Apache License, Version 2.0 (the "License");
+ * // you may not use this file except in compliance with the License.
+ * // You may obtain a copy of the License at http://www.apache.org/licenses/LICENSE-2.0
+ * // Unless required by applicable law or agreed to in writing, software
+ * // distributed under the License is distributed on an "AS IS" BASIS,
+ * // WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * // See the License for the specific language governing permissions and
+ * // limitations under the License.
+ */
+
+package cmd
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// gitRepo builds a bare repository with a "v1" tag and a later commit on
+// main, and returns its path.
+func gitRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	tmp := t.TempDir()
+	t.Setenv("HOME", tmp)
+	t.Setenv("XDG_CACHE_HOME", filepath.Join(tmp, "cache"))
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(tmp, "config"))
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	t.Setenv("GIT_AUTHOR_NAME", "test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+
+	work := filepath.Join(tmp, "work")
+	git := func(args ...string) {
+		t.Helper()
+		if _, err := runGit(context.Background(), append([]string{"-C", work}, args...)...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write := func(name, data string) {
+		t.Helper()
+		p := filepath.Join(work, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(work, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	git("init", "--quiet", "--initial-branch=main")
+	write("registry.json", `{"blueprints":[]}`)
+	write("blueprints/api/manifest.yaml", "name: api\nversion: 1.0.0\n")
+	write("blueprints/api/template/main.go", "v1")
+	write("blueprints/web/template/index.html", "web")
+	git("add", "-A")
+	git("commit", "--quiet", "-m", "v1")
+	git("tag", "v1")
+	write("blueprints/api/template/main.go", "v2")
+	git("commit", "--quiet", "-am", "v2")
+
+	bare := filepath.Join(tmp, "blueprints.git")
+	if _, err := runGit(context.Background(), "clone", "--bare", "--quiet", work, bare); err != nil {
+		t.Fatal(err)
+	}
+	return bare
+}
+
+func TestGitSourceExport(t *testing.T) {
+	bare := gitRepo(t)
+	ctx := context.Background()
+	g, err := parseGitSource("git+file://" + filepath.ToSlash(bare) + "//blueprints/api?ref=v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.Path != "blueprints/api" || g.Ref != "v1" {
+		t.Fatalf("parsed %+v", g)
+	}
+
+	dst := t.TempDir()
+	if err := g.export(ctx, dst, "", 1<<20); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := os.ReadFile(filepath.Join(dst, "template", "main.go")); err != nil || string(b) != "v1" {
+		t.Errorf("template/main.go at v1: %q, %v", b, err)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "manifest.yaml")); err != nil {
+		t.Errorf("manifest.yaml not exported: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "template", "index.html")); err == nil {
+		t.Error("a sibling blueprint was exported")
+	}
+	if runtime.GOOS != "windows" {
+		mirror := gitMirrorDir(g.Repo)
+		for path, want := range map[string]os.FileMode{filepath.Dir(mirror): 0o700, filepath.Join(mirror, "dragon-fetched"): 0o600} {
+			fi, err := os.Stat(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fi.Mode().Perm() != want {
+				t.Errorf("%s: mode %v, want %v", path, fi.Mode().Perm(), want)
+			}
+		}
+	}
+
+	// Without a ref the remote HEAD is used; the mirror is reused.
+	g.Ref = ""
+	dst = t.TempDir()
+	if err := g.export(ctx, dst, "template", 1<<20); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := os.ReadFile(filepath.Join(dst, "template", "main.go")); err != nil || string(b) != "v2" {
+		t.Errorf("template/main.go at HEAD: %q, %v", b, err)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "manifest.yaml")); err == nil {
+		t.Error("member outside the prefix was exported")
+	}
+
+	if b, err := g.readFile(ctx, "registry.json"); err != nil || string(b) != `{"blueprints":[]}` {
+		t.Errorf("readFile: %q, %v", b, err)
+	}
+	if err := g.export(ctx, t.TempDir(), "", 1); err == nil {
+		t.Error("export over max_unpacked_size: want an error")
+	}
+
+	// The caller's deadline doesn't cut git short; cancelling does.
+	expired, cancel := context.WithTimeout(ctx, -time.Second)
+	defer cancel()
+	if _, err := g.readFile(expired, "registry.json"); err != nil {
+		t.Errorf("readFile past the caller's deadline: %v", err)
+	}
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := g.readFile(cancelled, "registry.json"); err == nil {
+		t.Error("readFile with a cancelled context: want an error")
+	}
+
+	g.Ref = "no-such-ref"
+	if err := g.export(ctx, t.TempDir(), "", 1<<20); err == nil {
+		t.Error("unknown ref: want an error")
+	}
+}
+
+func TestParseGitSource(t *testing.T) {
+	for _, loc := range []string{"git+/srv/repo.git", "git+file:///srv/repo.git//../x", "git+file:///srv/repo.git//."} {
+		if _, err := parseGitSource(loc); err == nil {
+			t.Errorf("parseGitSource(%q): want error", loc)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+/*
+ * // Copyright 2025 getDragon-dev
+ * // Licensed under the Apache License, Version 2.0 (the "License");
+ * // you may not use this file except in compliance with the License.
+ * // You may obtain a copy of the License at http://www.apache.org/licenses/LICENSE-2.0
+ * // Unless required by applicable law or agreed to in writing, software
+ * // distributed under the License is distributed on an "AS IS" BASIS,
+ * // WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * // See the License for the specific language governing permissions and
+ * // limitations under the License.
+ */
+
+package cmd
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	defaultHTTPTimeout = 30 * time.Second
+	defaultHTTPRetries = 3
+	retryBaseDelay     = 500 * time.Millisecond
+	retryMaxDelay      = 30 * time.Second
+)
+
+var (
+	httpOnce   sync.Once
+	httpShared *http.Client
+	httpErr    error
+)
+
+// httpClient returns the client every network request goes through. It is
+// built once from the "http" section of the user config.
+func httpClient() (*http.Client, error) {
+	httpOnce.Do(func() {
+		var hc HTTPConfig
+		if cfg, _, err := effectiveConfig(); err == nil && cfg.HTTP != nil {
+			hc = *cfg.HTTP
+		}
+		httpShared, httpErr = newHTTPClient(hc)
+	})
+	return httpShared, httpErr
+}
+
+// doHTTP sends req with the shared client.
+func doHTTP(req *http.Request) (*http.Response, error) {
+	c, err := httpClient()
+	if err != nil {
+		return nil, err
+	}
+	return c.Do(req)
+}
+
+func userAgent() string {
+	return fmt.Sprintf("dragon/%s (%s/%s)", Version, runtime.GOOS, runtime.GOARCH)
+}
+
+func newHTTPClient(hc HTTPConfig) (*http.Client, error) {
+	timeout := defaultHTTPTimeout
+	if hc.Timeout != "" {
+		d, err := time.ParseDuration(hc.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("http.timeout: %w", err)
+		}
+		timeout = d
+	}
+	retries := defaultHTTPRetries
+	if hc.Retries != nil {
+		retries = *hc.Retries
+	}
+	tlsConf := &tls.Config{MinVersion: tls.VersionTLS12}
+	if hc.CAFile != "" {
+		pem, err := os.ReadFile(configRelative(hc.CAFile))
+		if err != nil {
+			return nil, fmt.Errorf("http.ca_file: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("http.ca_file: no certificates found in %s", hc.CAFile)
+		}
+		tlsConf.RootCAs = pool
+	}
+	if hc.ClientCert != "" || hc.ClientKey != "" {
+		cert, err := tls.LoadX509KeyPair(configRelative(hc.ClientCert), configRelative(hc.ClientKey))
+		if err != nil {
+			return nil, fmt.Errorf("http.client_cert: %w", err)
+		}
+		tlsConf.Certificates = []tls.Certificate{cert}
+	}
+	base := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           (&net.Dialer{Timeout: timeout, KeepAlive: 30 * time.Second}).DialContext,
+		TLSClientConfig:       tlsConf,
+		TLSHandshakeTimeout:   timeout,
+		ResponseHeaderTimeout: timeout,
+		ExpectContinueTimeout: time.Second,
+		IdleConnTimeout:       90 * time.Second,
+		MaxIdleConns:          100,
+		ForceAttemptHTTP2:     true,
+	}
+	return &http.Client{Transport: &retryTransport{base: base, retries: retries}}, nil
+}
+
+// configRelative resolves p against the directory holding the user config.
+func configRelative(p string) string {
+	if p == "" || filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(filepath.Dir(configPath()), p)
+}
+
+// retryTransport retries idempotent requests that fail with a network error,
+// 429 or a transient 5xx, backing off exponentially with jitter and honoring
+// Retry-After. It also sets the User-Agent.
+type retryTransport struct {
+	base    http.RoundTripper
+	retries int
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("User-Agent") == "" {
+		req = req.Clone(req.Context())
+		req.Header.Set("User-Agent", userAgent())
+	}
+	for attempt := 0; ; attempt++ {
+		resp, err := t.base.RoundTrip(req)
+		if attempt >= t.retries || !idempotent(req) || !retryable(resp, err) {
+			return resp, err
+		}
+		wait := backoff(attempt)
+		if resp != nil {
+			if d, ok := retryAfter(resp.Header.Get("Retry-After")); ok {
+				wait = d
+			}
+		}
+		if deadline, ok := req.Context().Deadline(); ok && time.Until(deadline) < wait {
+			return resp, err
+		}
+		if resp != nil {
+			_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+			resp.Body.Close()
+		}
+		if req.GetBody != nil {
+			body, gerr := req.GetBody()
+			if gerr != nil {
+				return nil, gerr
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func idempotent(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	case http.MethodPut, http.MethodDelete:
+		return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+	}
+	return false
+}
+
+func retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// backoff is the delay before retry n+1: exponential, capped, with the
+// upper half randomized so that clients don't retry in lockstep.
+func backoff(n int) time.Duration {
+	d := retryBaseDelay << n
+	if d <= 0 || d > retryMaxDelay {
+		d = retryMaxDelay
+	}
+	return d/2 + rand.N(d/2+1)
+}
+
+// retryAfter parses a Retry-After header given in seconds or as an HTTP date.
+func retryAfter(v string) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if s, err := strconv.Atoi(v); err == nil && s >= 0 {
+		return min(time.Duration(s)*time.Second, retryMaxDelay), true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return min(max(time.Until(t), 0), retryMaxDelay), true
+	}
+	return 0, false
+}
@@ -0,0 +1,173 @@
+/*
+ * // Copyright 2025 getDragon-dev
+ * // Licensed under the Apache License, Version 2.0 (the "License");
+ * // you may not use this file except in compliance with the License.
+ * // You may obtain a copy of the License at http://www.apache.org/licenses/LICENSE-2.0
+ * // Unless required by applicable law or agreed to in writing, software
+ * // distributed under the License is distributed on an "AS IS" BASIS,
+ * // WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * // See the License for the specific language governing permissions and
+ * // limitations under the License.
+ */
+
+package cmd
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// flakyServer fails the first n requests with status, asking for an
+// immediate retry, and counts every request it sees.
+func flakyServer(t *testing.T, n, status int) (*httptest.Server, *int) {
+	t.Helper()
+	hits := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		_, _ = io.Copy(io.Discard, r.Body)
+		if hits <= n {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(status)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &hits
+}
+
+func testClient(t *testing.T, retries int) *http.Client {
+	t.Helper()
+	c, err := newHTTPClient(HTTPConfig{Retries: &retries})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestRetryTransport(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		body     func() io.Reader
+		fail     int
+		status   int
+		retries  int
+		wantHits int
+		wantCode int
+	}{
+		{"429 is retried", http.MethodGet, nil, 2, http.StatusTooManyRequests, 3, 3, http.StatusOK},
+		{"503 is retried", http.MethodGet, nil, 1, http.StatusServiceUnavailable, 3, 2, http.StatusOK},
+		{"gives up after http.retries", http.MethodGet, nil, 10, http.StatusServiceUnavailable, 2, 3, http.StatusServiceUnavailable},
+		{"no retries configured", http.MethodHead, nil, 1, http.StatusServiceUnavailable, 0, 1, http.StatusServiceUnavailable},
+		{"404 is final", http.MethodGet, nil, 1, http.StatusNotFound, 3, 1, http.StatusNotFound},
+		{"POST is never retried", http.MethodPost, func() io.Reader { return strings.NewReader("x") }, 1, http.StatusServiceUnavailable, 3, 1, http.StatusServiceUnavailable},
+		{"PUT with a one-shot body is not retried", http.MethodPut, func() io.Reader { return io.NopCloser(strings.NewReader("x")) }, 1, http.StatusServiceUnavailable, 3, 1, http.StatusServiceUnavailable},
+		{"PUT with a replayable body is retried", http.MethodPut, func() io.Reader { return strings.NewReader("x") }, 1, http.StatusServiceUnavailable, 3, 2, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, hits := flakyServer(t, tt.fail, tt.status)
+			var body io.Reader
+			if tt.body != nil {
+				body = tt.body()
+			}
+			req, err := http.NewRequest(tt.method, srv.URL, body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := testClient(t, tt.retries).Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if *hits != tt.wantHits || resp.StatusCode != tt.wantCode {
+				t.Errorf("%d requests ending in %d, want %d ending in %d", *hits, resp.StatusCode, tt.wantHits, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestRetryAfterHonored(t *testing.T) {
+	hits := 0
+	var gap time.Duration
+	var last time.Time
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		if hits > 1 {
+			gap = time.Since(last)
+		}
+		last = time.Now()
+		if hits == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+	resp, err := testClient(t, 1).Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if hits != 2 || gap < time.Second {
+		t.Errorf("%d requests, %v apart; want a retry after Retry-After: 1", hits, gap)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		header   string
+		min, max time.Duration
+		ok       bool
+	}{
+		{"0", 0, 0, true},
+		{"7", 7 * time.Second, 7 * time.Second, true},
+		{"3600", retryMaxDelay, retryMaxDelay, true},
+		{now.Add(10 * time.Second).UTC().Format(http.TimeFormat), 8 * time.Second, 10 * time.Second, true},
+		{now.Add(-time.Hour).UTC().Format(http.TimeFormat), 0, 0, true},
+		{now.Add(time.Hour).UTC().Format(http.TimeFormat), retryMaxDelay, retryMaxDelay, true},
+		{"", 0, 0, false},
+		{"-1", 0, 0, false},
+		{"soon", 0, 0, false},
+	}
+	for _, tt := range tests {
+		d, ok := retryAfter(tt.header)
+		if ok != tt.ok || d < tt.min || d > tt.max {
+			t.Errorf("retryAfter(%q) = %v, %v; want %v..%v, %v", tt.header, d, ok, tt.min, tt.max, tt.ok)
+		}
+	}
+}
+
+func TestUserAgent(t *testing.T) {
+	var got []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.Header.Get("User-Agent"))
+	}))
+	defer srv.Close()
+	c := testClient(t, 0)
+	for _, ua := range []string{"", "custom/1.0"} {
+		req, err := http.NewRequest(http.MethodGet, srv.URL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ua != "" {
+			req.Header.Set("User-Agent", ua)
+		}
+		resp, err := c.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	if len(got) != 2 || got[0] != userAgent() || got[1] != "custom/1.0" {
+		t.Errorf("User-Agent = %q, want %q then the caller's own", got, userAgent())
+	}
+	if !strings.HasPrefix(userAgent(), "dragon/") {
+		t.Errorf("userAgent() = %q", userAgent())
+	}
+}
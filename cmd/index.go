@@ -0,0 +1,194 @@
+/*
+ * // Copyright 2025 getDragon-dev
+ * // Licensed under the Apache License, Version 2.0 (the "License");
+ * // you may not use this file except in compliance with the License.
+ * // You may obtain a copy of the License at http://www.apache.org/licenses/LICENSE-2.0
+ * // Unless required by applicable law or agreed to in writing, software
+ * // distributed under the License is distributed on an "AS IS" BASIS,
+ * // WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * // See the License for the specific language governing permissions and
+ * // limitations under the License.
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+
+	corereg "github.com/getDragon-dev/dragon-core/registry"
+	"gopkg.in/yaml.v3"
+)
+
+// indexEntry carries the fields of a registry.json blueprint entry that
+// dragon-core's Blueprint does not model. It is decoded from the same bytes
+// and matched to the core entry by name.
+type indexEntry struct {
+	Name   string `json:"name"`
+	Digest string `json:"digest,omitempty"`
+	Size   int64  `json:"size,omitempty"`
+	// TemplateRoot is the bundle directory holding the template; it
+	// defaults to "template".
+	TemplateRoot string         `json:"template_root,omitempty"`
+	Deprecated   *deprecation   `json:"deprecated,omitempty"`
+	Yanked       bool           `json:"yanked,omitempty"`
+	Versions     []indexVersion `json:"versions,omitempty"`
+}
+
+// indexVersion is one release listed in an entry's version history. The
+// top-level version of the entry counts as a release too.
+type indexVersion struct {
+	Version      string       `json:"version"`
+	DownloadURL  string       `json:"download_url"`
+	Digest       string       `json:"digest,omitempty"`
+	Size         int64        `json:"size,omitempty"`
+	TemplateRoot string       `json:"template_root,omitempty"`
+	Deprecated   *deprecation `json:"deprecated,omitempty"`
+	Yanked       bool         `json:"yanked,omitempty"`
+}
+
+// deprecation marks a blueprint, or one of its releases, as no longer
+// recommended. Besides the object form it may be written as `true` or as a
+// bare message string.
+type deprecation struct {
+	Message     string `json:"message,omitempty" yaml:"message"`
+	Replacement string `json:"replacement,omitempty" yaml:"replacement"`
+	off         bool   // written as `false`
+}
+
+func (d *deprecation) active() bool { return d != nil && !d.off }
+
+func (d *deprecation) UnmarshalJSON(b []byte) error {
+	var flag bool
+	if err := json.Unmarshal(b, &flag); err == nil {
+		*d = deprecation{off: !flag}
+		return nil
+	}
+	var msg string
+	if err := json.Unmarshal(b, &msg); err == nil {
+		*d = deprecation{Message: msg}
+		return nil
+	}
+	type plain deprecation
+	return json.Unmarshal(b, (*plain)(d))
+}
+
+// UnmarshalYAML accepts the same forms as UnmarshalJSON, for manifest.yaml.
+func (d *deprecation) UnmarshalYAML(n *yaml.Node) error {
+	if n.Kind == yaml.ScalarNode {
+		if n.Tag == "!!bool" {
+			var flag bool
+			if err := n.Decode(&flag); err != nil {
+				return err
+			}
+			*d = deprecation{off: !flag}
+			return nil
+		}
+		*d = deprecation{Message: n.Value}
+		return nil
+	}
+	type plain deprecation
+	return n.Decode((*plain)(d))
+}
+
+func (d *deprecation) String() string {
+	s := "deprecated"
+	if d.Message != "" {
+		s += ": " + d.Message
+	}
+	if d.Replacement != "" {
+		s += " (use " + d.Replacement + " instead)"
+	}
+	return s
+}
+
+func decodeIndexExtras(data []byte) (map[string]indexEntry, error) {
+	var idx struct {
+		Blueprints []indexEntry `json:"blueprints"`
+	}
+	if err := json.Unmarshal(data, &idx); err != nil {
+		return nil, err
+	}
+	out := make(map[string]indexEntry, len(idx.Blueprints))
+	for _, e := range idx.Blueprints {
+		if _, dup := out[e.Name]; !dup {
+			out[e.Name] = e
+		}
+	}
+	return out, nil
+}
+
+// resolvedBlueprint is a registry entry together with where it came from.
+type resolvedBlueprint struct {
+	corereg.Blueprint
+	Digest       string
+	Size         int64
+	TemplateRoot string
+	Deprecated   *deprecation
+	Yanked       bool
+	Registry     string
+	Source       string
+}
+
+// parseDigest accepts "sha256:<hex>" as well as SRI-style "sha256-<base64>"
+// (sha384 and sha512 too) and returns a fresh hash plus the expected sum.
+func parseDigest(d string) (hash.Hash, []byte, error) {
+	i := strings.IndexAny(d, ":-")
+	if i <= 0 {
+		return nil, nil, fmt.Errorf("malformed digest %q", d)
+	}
+	algo, enc := strings.ToLower(d[:i]), d[i+1:]
+	var sum []byte
+	var err error
+	if d[i] == ':' {
+		sum, err = hex.DecodeString(enc)
+	} else {
+		sum, err = base64.StdEncoding.DecodeString(enc)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("malformed digest %q: %w", d, err)
+	}
+	var h hash.Hash
+	switch algo {
+	case "sha256":
+		h = sha256.New()
+	case "sha384":
+		h = sha512.New384()
+	case "sha512":
+		h = sha512.New()
+	default:
+		return nil, nil, fmt.Errorf("unsupported digest algorithm %q", algo)
+	}
+	if len(sum) != h.Size() {
+		return nil, nil, fmt.Errorf("malformed digest %q: wrong length", d)
+	}
+	return h, sum, nil
+}
+
+func verifyDigest(digest string, data []byte) error {
+	return verifyDigestReader(digest, bytes.NewReader(data))
+}
+
+// verifyDigestReader is verifyDigest for content too large to hold in memory.
+func verifyDigestReader(digest string, r io.Reader) error {
+	h, want, err := parseDigest(digest)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(h, r); err != nil {
+		return err
+	}
+	if subtle.ConstantTimeCompare(h.Sum(nil), want) != 1 {
+		return fmt.Errorf("digest mismatch: want %s", digest)
+	}
+	return nil
+}
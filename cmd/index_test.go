@@ -0,0 +1,97 @@
+/*
+ * // Copyright 2025 getDragon-dev
+ * // Licensed under the Apache License, Version 2.0 (the "License");
+ * // you may not use this file except in compliance with the License.
+ * // You may obtain a copy of the License at http://www.apache.org/licenses/LICENSE-2.0
+ * // Unless required by applicable law or agreed to in writing, software
+ * // distributed under the License is distributed on an "AS IS" BASIS,
+ * // WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * // See the License for the specific language governing permissions and
+ * // limitations under the License.
+ */
+
+package cmd
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestDeprecationForms(t *testing.T) {
+	tests := []struct {
+		json, yaml string
+		want       deprecation
+		active     bool
+	}{
+		{`true`, `true`, deprecation{}, true},
+		{`false`, `false`, deprecation{off: true}, false},
+		{`"use foo"`, `"use foo"`, deprecation{Message: "use foo"}, true},
+		{`"use foo"`, `use foo`, deprecation{Message: "use foo"}, true},
+		{`{"message":"old","replacement":"foo"}`, `{message: old, replacement: foo}`, deprecation{Message: "old", Replacement: "foo"}, true},
+	}
+	for _, tt := range tests {
+		var j struct {
+			Deprecated *deprecation `json:"deprecated"`
+		}
+		if err := json.Unmarshal([]byte(`{"deprecated":`+tt.json+`}`), &j); err != nil {
+			t.Errorf("json %s: %v", tt.json, err)
+		} else if j.Deprecated.active() != tt.active {
+			t.Errorf("json %s: active = %v, want %v", tt.json, j.Deprecated.active(), tt.active)
+		}
+		var y vmanifest
+		if err := yaml.Unmarshal([]byte("deprecated: "+tt.yaml+"\n"), &y); err != nil {
+			t.Errorf("yaml %s: %v", tt.yaml, err)
+			continue
+		}
+		if y.Deprecated == nil || *y.Deprecated != tt.want {
+			t.Errorf("yaml %s: got %+v, want %+v", tt.yaml, y.Deprecated, tt.want)
+		}
+	}
+}
+
+func TestParseDigest(t *testing.T) {
+	data := []byte("bundle")
+	s256 := sha256.Sum256(data)
+	s512 := sha512.Sum512(data)
+	hex256 := hex.EncodeToString(s256[:])
+	sri256 := base64.StdEncoding.EncodeToString(s256[:])
+	tests := []struct {
+		digest string
+		err    string
+	}{
+		{"sha256:" + hex256, ""},
+		{"SHA256:" + strings.ToUpper(hex256), ""},
+		{"sha256-" + sri256, ""},
+		{"sha512-" + base64.StdEncoding.EncodeToString(s512[:]), ""},
+		{"sha512:" + hex.EncodeToString(s512[:]), ""},
+		{"sha256:" + hex256[:62], "wrong length"},
+		{"sha256-" + sri256[:20], "malformed digest"},
+		{"sha256:xyz", "malformed digest"},
+		{"md5:" + hex256, "unsupported digest algorithm"},
+		{hex256, "malformed digest"},
+		{":" + hex256, "malformed digest"},
+	}
+	for _, tt := range tests {
+		err := verifyDigest(tt.digest, data)
+		if tt.err == "" && err != nil {
+			t.Errorf("verifyDigest(%q): %v", tt.digest, err)
+		}
+		if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+			t.Errorf("verifyDigest(%q) = %v, want an error mentioning %q", tt.digest, err, tt.err)
+		}
+	}
+
+	other := sha256.Sum256([]byte("tampered"))
+	for _, d := range []string{"sha256:" + hex.EncodeToString(other[:]), "sha256-" + base64.StdEncoding.EncodeToString(other[:])} {
+		if err := verifyDigest(d, data); err == nil || !strings.Contains(err.Error(), "digest mismatch") {
+			t.Errorf("verifyDigest(%q) = %v, want a mismatch", d, err)
+		}
+	}
+}
@@ -18,16 +18,52 @@ import (
 	"github.com/spf13/cobra"
 )
 
-var infoCmd = &cobra.Command{Use: "info <blueprint>", Args: cobra.ExactArgs(1), Short: "Show detailed info about a blueprint",
+var infoCmd = &cobra.Command{Use: "info [registry/]<blueprint>[@version]", Args: cobra.ExactArgs(1), Short: "Show detailed info about a blueprint", ValidArgsFunction: completeBlueprints,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		bp, src, err := findBlueprint(args[0])
+		sets, err := loadAllRegistries()
+		if err != nil {
+			return err
+		}
+		// info describes yanked releases too, whether pinned or not.
+		allowYanked, showYanked = true, true
+		bp, err := resolveIn(sets, args[0], "")
 		if err != nil {
 			return err
 		}
 		fmt.Printf("Name: %s\nVersion: %s\nDescription: %s\nTags: %v\nDownload: %s\nRepo: %s\nPath: %s\nSource Registry: %s\n",
-			bp.Name, bp.Version, bp.Description, bp.Tags, bp.DownloadURL, bp.Repo, bp.Path, src)
+			bp.Name, bp.Version, bp.Description, bp.Tags, bp.DownloadURL, bp.Repo, bp.Path, bp.Source)
+		if bp.Digest != "" {
+			fmt.Printf("Digest: %s\n", bp.Digest)
+		}
+		if bp.Size > 0 {
+			fmt.Printf("Size: %d bytes\n", bp.Size)
+		}
+		switch {
+		case bp.Yanked:
+			fmt.Println("Status: yanked")
+		case bp.Deprecated.active():
+			fmt.Printf("Status: %s\n", bp.Deprecated)
+		}
+		if vs := blueprintVersions(sets, bp.Name); len(vs) > 1 {
+			fmt.Println("Versions:")
+			for _, v := range vs {
+				fmt.Printf("  %s (%s)%s\n", v.Version, v.Registry, releaseStatus(v))
+			}
+		}
 		return nil
 	},
 }
 
+// releaseStatus is the suffix listings append to deprecated or yanked
+// releases.
+func releaseStatus(r resolvedBlueprint) string {
+	switch {
+	case r.Yanked:
+		return " [yanked]"
+	case r.Deprecated.active():
+		return " [deprecated]"
+	}
+	return ""
+}
+
 func init() { rootCmd.AddCommand(infoCmd) }
@@ -25,11 +25,16 @@ import (
 var listAll bool
 var listTag string
 var listJSON bool
+var listIncludeYanked bool
 
 var listCmd = &cobra.Command{Use: "list", Short: "List available blueprints", RunE: func(cmd *cobra.Command, args []string) error {
 	type row struct {
 		Name, Version, Description, Source string
 		Tags                               []string
+		Registry                           string
+		ShadowedBy                         string       `json:",omitempty"`
+		Deprecated                         *deprecation `json:",omitempty"`
+		Yanked                             bool         `json:",omitempty"`
 	}
 	rows := []row{}
 	if listAll {
@@ -37,12 +42,9 @@ var listCmd = &cobra.Command{Use: "list", Short: "List available blueprints", Ru
 		if err != nil {
 			return err
 		}
-		seen := map[string]bool{}
+		winners := resolvedRegistries(sets)
 		for _, s := range sets {
 			for _, bp := range s.DB.Blueprints {
-				if seen[bp.Name] {
-					continue
-				}
 				if listTag != "" {
 					ok := false
 					for _, t := range bp.Tags {
@@ -55,17 +57,23 @@ var listCmd = &cobra.Command{Use: "list", Short: "List available blueprints", Ru
 						continue
 					}
 				}
-				rows = append(rows, row{bp.Name, bp.Version, bp.Description, s.URL, bp.Tags})
-				seen[bp.Name] = true
+				rel, ok := s.listed(bp, listIncludeYanked)
+				if !ok {
+					continue
+				}
+				shadowed := ""
+				if w := winners[bp.Name]; w != "" && w != s.Name {
+					shadowed = w
+				}
+				rows = append(rows, row{bp.Name, rel.Version, bp.Description, s.URL, bp.Tags, s.Name, shadowed, rel.Deprecated, rel.Yanked})
 			}
 		}
 	} else {
-		db, err := loadRegistry()
+		set, err := loadDefaultSet()
 		if err != nil {
 			return err
 		}
-		src, _ := resolveRegistry()
-		for _, bp := range db.Blueprints {
+		for _, bp := range set.DB.Blueprints {
 			if listTag != "" {
 				ok := false
 				for _, t := range bp.Tags {
@@ -78,24 +86,54 @@ var listCmd = &cobra.Command{Use: "list", Short: "List available blueprints", Ru
 					continue
 				}
 			}
-			rows = append(rows, row{bp.Name, bp.Version, bp.Description, src, bp.Tags})
+			rel, ok := set.listed(bp, listIncludeYanked)
+			if !ok {
+				continue
+			}
+			rows = append(rows, row{bp.Name, rel.Version, bp.Description, set.URL, bp.Tags, set.Name, "", rel.Deprecated, rel.Yanked})
 		}
 	}
-	sort.Slice(rows, func(i, j int) bool { return rows[i].Name < rows[j].Name })
+	sort.SliceStable(rows, func(i, j int) bool { return rows[i].Name < rows[j].Name })
 	if listJSON {
 		enc := json.NewEncoder(os.Stdout)
 		enc.SetIndent("", "  ")
 		return enc.Encode(rows)
 	}
 	for _, r := range rows {
-		fmt.Printf("- %s (%s) — %s\n", r.Name, r.Version, r.Description)
+		status := releaseStatus(resolvedBlueprint{Deprecated: r.Deprecated, Yanked: r.Yanked})
+		if r.ShadowedBy != "" {
+			fmt.Printf("- %s (%s) — %s%s [shadowed by %s; use %s/%s]\n", r.Name, r.Version, r.Description, status, r.ShadowedBy, r.Registry, r.Name)
+			continue
+		}
+		fmt.Printf("- %s (%s) — %s%s\n", r.Name, r.Version, r.Description, status)
 	}
 	return nil
 }}
 
+// resolvedRegistries maps each blueprint name in sets to the registry a
+// plain reference to it resolves to, honouring pins as gen and get do. A
+// name that does not resolve, say because no release matches its pin, maps
+// to "".
+func resolvedRegistries(sets []registrySet) map[string]string {
+	winners := map[string]string{}
+	for _, s := range sets {
+		for _, bp := range s.DB.Blueprints {
+			if _, seen := winners[bp.Name]; seen {
+				continue
+			}
+			winners[bp.Name] = ""
+			if win, err := resolveIn(sets, bp.Name, pinnedConstraint(blueprintRef{Name: bp.Name})); err == nil {
+				winners[bp.Name] = win.Registry
+			}
+		}
+	}
+	return winners
+}
+
 func init() {
 	listCmd.Flags().BoolVar(&listAll, "all", false, "aggregate across all registries")
 	listCmd.Flags().StringVar(&listTag, "tag", "", "filter by tag")
 	listCmd.Flags().BoolVar(&listJSON, "json", false, "output JSON")
+	listCmd.Flags().BoolVar(&listIncludeYanked, "include-yanked", false, "show blueprints whose releases have all been yanked")
 	rootCmd.AddCommand(listCmd)
 }
@@ -0,0 +1,98 @@
+/*
+ * // Copyright 2025 getDragon-dev
+ * // Licensed under the Apache License, Version 2.0 (the "License");
+ * // you may not use this file except in compliance with the License.
+ * // You may obtain a copy of the License at http://www.apache.org/licenses/LICENSE-2.0
+ * // Unless required by applicable law or agreed to in writing, software
+ * // distributed under the License is distributed on an "AS IS" BASIS,
+ * // WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * // See the License for the specific language governing permissions and
+ * // limitations under the License.
+ */
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	lockPoll      = 100 * time.Millisecond
+	lockHeartbeat = 15 * time.Second
+	// lockStale is how long a lock may go untouched before it is taken to
+	// belong to a process that died without removing it.
+	lockStale = time.Minute
+)
+
+// acquireLock takes the lock file p, waiting while another process holds
+// it. The holder touches the file periodically, so a lock that has not been
+// touched for lockStale is broken. A plain lock file keeps this working the
+// same way on every platform, including network file systems.
+func acquireLock(ctx context.Context, p, what string) (release func(), err error) {
+	if err := os.MkdirAll(filepath.Dir(p), 0o700); err != nil {
+		return nil, err
+	}
+	waiting := false
+	for {
+		f, err := os.OpenFile(p, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+		if err == nil {
+			fmt.Fprintf(f, "%d\n", os.Getpid())
+			f.Close()
+			trackTemp(p)
+			stop := make(chan struct{})
+			go func() {
+				t := time.NewTicker(lockHeartbeat)
+				defer t.Stop()
+				for {
+					select {
+					case <-stop:
+						return
+					case now := <-t.C:
+						_ = os.Chtimes(p, now, now)
+					}
+				}
+			}()
+			return func() {
+				close(stop)
+				removeTemp(p)
+			}, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return nil, err
+		}
+		if fi, err := os.Stat(p); err == nil && time.Since(fi.ModTime()) > lockStale {
+			breakLock(p, fi)
+			continue
+		}
+		if !waiting && !quiet {
+			fmt.Fprintf(os.Stderr, "waiting for another dragon process to finish %s\n", what)
+			waiting = true
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("waiting for lock %s: %w", p, ctx.Err())
+		case <-time.After(lockPoll):
+		}
+	}
+}
+
+// breakLock removes the stale lock p, last seen as fi. Removing it by name
+// could delete a lock another process has just taken after breaking the same
+// stale one, so it is first renamed to a name of our own: only one process
+// wins the rename, and a live lock caught by it is linked back.
+func breakLock(p string, fi fs.FileInfo) {
+	stale := fmt.Sprintf("%s.stale-%d-%d", p, os.Getpid(), time.Now().UnixNano())
+	if err := os.Rename(p, stale); err != nil {
+		return
+	}
+	if cur, err := os.Stat(stale); err == nil && !os.SameFile(fi, cur) {
+		_ = os.Link(stale, p)
+	}
+	os.Remove(stale)
+}
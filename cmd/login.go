@@ -0,0 +1,132 @@
+/*
+ * // Copyright 2025 getDragon-dev
+ * // Licensed under the Apache License, Version 2.0 (the "License");
+ * // you may not use this file except in compliance with the License.
+ * // You may obtain a copy of the License at http://www.apache.org/licenses/LICENSE-2.0
+ * // Unless required by applicable law or agreed to in writing, software
+ * // distributed under the License is distributed on an "AS IS" BASIS,
+ * // WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * // See the License for the specific language governing permissions and
+ * // limitations under the License.
+ */
+
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	loginToken         string
+	loginUsername      string
+	loginPasswordStdin bool
+)
+
+var loginCmd = &cobra.Command{Use: "login <registry|host>", Args: cobra.ExactArgs(1), Short: "Store credentials for a registry or host",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+		var c Credential
+		if r, err := configuredRegistry(name); err == nil {
+			c.Host = hostOf(r.URL)
+			if c.Host == "" {
+				return fmt.Errorf("registry %q is not served over http(s)", name)
+			}
+		} else if strings.ContainsAny(name, ".:") && !strings.Contains(name, "/") {
+			// A bare host, e.g. an OCI registry used by blueprint push/pull.
+			c.Host = name
+		} else {
+			return err
+		}
+		var err error
+		// On a terminal the secret is typed without echo; otherwise it is
+		// read from piped stdin.
+		secret := func(label string) (string, error) {
+			if !isTerminal(os.Stdin) || loginPasswordStdin {
+				return readSecretLine(bufio.NewReader(os.Stdin))
+			}
+			if noInput {
+				return "", fmt.Errorf("%s required; pass it on stdin (--no-input is set)", strings.ToLower(label))
+			}
+			p := newPrompter()
+			fmt.Fprintf(p.out, "%s: ", label)
+			return p.readSecret()
+		}
+		switch {
+		case loginUsername != "":
+			c.Username = loginUsername
+			c.Password, err = secret("Password")
+		case loginToken != "":
+			c.Token = loginToken
+		default:
+			c.Token, err = secret("Token")
+		}
+		if err != nil {
+			return err
+		}
+		if c.Token == "" && c.Password == "" {
+			return errors.New("empty credential")
+		}
+		creds, err := readCredentials()
+		if err != nil {
+			return err
+		}
+		creds[name] = c
+		if err := writeCredentials(creds); err != nil {
+			return err
+		}
+		fmt.Println("Logged in to", name)
+		return nil
+	},
+}
+
+var logoutCmd = &cobra.Command{Use: "logout <registry|host>", Args: cobra.ExactArgs(1), Short: "Remove stored credentials for a registry",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		creds, err := readCredentials()
+		if err != nil {
+			return err
+		}
+		if _, ok := creds[args[0]]; !ok {
+			return fmt.Errorf("not logged in to %q", args[0])
+		}
+		delete(creds, args[0])
+		if err := writeCredentials(creds); err != nil {
+			return err
+		}
+		fmt.Println("Logged out of", args[0])
+		return nil
+	},
+}
+
+func configuredRegistry(name string) (Registry, error) {
+	cfg, _, err := effectiveConfig()
+	if err != nil {
+		return Registry{}, err
+	}
+	for _, r := range cfg.Registries {
+		if r.Name == name {
+			return r, nil
+		}
+	}
+	return Registry{}, fmt.Errorf("registry %q not found", name)
+}
+
+func readSecretLine(in *bufio.Reader) (string, error) {
+	s, err := in.ReadString('\n')
+	if err != nil && s == "" {
+		return "", err
+	}
+	return strings.TrimSpace(s), nil
+}
+
+func init() {
+	loginCmd.Flags().StringVar(&loginToken, "token", "", "Bearer token (prompted on stdin when omitted)")
+	loginCmd.Flags().StringVar(&loginUsername, "username", "", "Username for basic auth; the password is read from stdin")
+	loginCmd.Flags().BoolVar(&loginPasswordStdin, "password-stdin", false, "Read the password from stdin without prompting")
+	rootCmd.AddCommand(loginCmd, logoutCmd)
+}
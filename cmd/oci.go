@@ -0,0 +1,630 @@
+/*
+ * // Copyright 2025 getDragon-dev
+ * // Licensed under the Apache License, Version 2.0 (the "License");
+ * // you may not use this file except in compliance with the License.
+ * // You may obtain a copy of the License at http://www.apache.org/licenses/LICENSE-2.0
+ * // Unless required by applicable law or agreed to in writing, software
+ * // distributed under the License is distributed on an "AS IS" BASIS,
+ * // WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * // See the License for the specific language governing permissions and
+ * // limitations under the License.
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	ociManifestType    = "application/vnd.oci.image.manifest.v1+json"
+	ociIndexType       = "application/vnd.oci.image.index.v1+json"
+	ociEmptyType       = "application/vnd.oci.empty.v1+json"
+	ociBlueprintType   = "application/vnd.getdragon.blueprint.v1"
+	ociBundleLayerType = "application/vnd.getdragon.blueprint.bundle.v1+zip"
+	ociBundleTarType   = "application/vnd.getdragon.blueprint.bundle.v1.tar"
+	ociRegistryType    = "application/vnd.getdragon.registry.v1"
+	ociIndexLayerType  = "application/vnd.getdragon.registry.index.v1+json"
+	ociRefNameKey      = "org.opencontainers.image.ref.name"
+	ociTitleKey        = "org.opencontainers.image.title"
+)
+
+// ociMaxManifestSize caps manifests, which are read into memory; registries
+// commonly refuse larger ones too.
+const ociMaxManifestSize = 4 << 20
+
+// ociEmptyConfig is the "{}" blob OCI artifacts use when they need no config.
+var ociEmptyConfig = []byte("{}")
+
+type ociDescriptor struct {
+	MediaType    string            `json:"mediaType"`
+	Digest       string            `json:"digest"`
+	Size         int64             `json:"size"`
+	ArtifactType string            `json:"artifactType,omitempty"`
+	Annotations  map[string]string `json:"annotations,omitempty"`
+}
+
+type ociManifest struct {
+	SchemaVersion int               `json:"schemaVersion"`
+	MediaType     string            `json:"mediaType,omitempty"`
+	ArtifactType  string            `json:"artifactType,omitempty"`
+	Config        ociDescriptor     `json:"config"`
+	Layers        []ociDescriptor   `json:"layers"`
+	Manifests     []ociDescriptor   `json:"manifests,omitempty"`
+	Annotations   map[string]string `json:"annotations,omitempty"`
+}
+
+type ociIndex struct {
+	SchemaVersion int             `json:"schemaVersion"`
+	MediaType     string          `json:"mediaType,omitempty"`
+	Manifests     []ociDescriptor `json:"manifests"`
+}
+
+// ociRef is a parsed artifact reference:
+//
+//	oci://registry.example.com/team/blueprints/api:1.2.0
+//	oci://registry.example.com/team/blueprints/api@sha256:...
+//	oci-layout:///srv/layouts/blueprints:1.2.0
+//
+// For image layouts Repo is the layout directory. Tag defaults to "latest".
+type ociRef struct {
+	Layout bool
+	Host   string
+	Repo   string
+	Tag    string
+	Digest string
+}
+
+func isOCI(loc string) bool {
+	return strings.HasPrefix(loc, "oci://") || strings.HasPrefix(loc, "oci-layout://")
+}
+
+func parseOCIRef(loc string) (ociRef, error) {
+	var ref ociRef
+	rest, ok := strings.CutPrefix(loc, "oci-layout://")
+	if ok {
+		ref.Layout = true
+	} else if rest, ok = strings.CutPrefix(loc, "oci://"); !ok {
+		return ref, fmt.Errorf("%q is not an oci:// or oci-layout:// reference", loc)
+	}
+	if i := strings.LastIndex(rest, "@"); i >= 0 {
+		ref.Digest = rest[i+1:]
+		rest = rest[:i]
+		if _, _, err := parseDigest(ref.Digest); err != nil {
+			return ref, fmt.Errorf("%s: %w", loc, err)
+		}
+	}
+	if i := strings.LastIndex(rest, ":"); i > strings.LastIndex(rest, "/") {
+		ref.Tag = rest[i+1:]
+		rest = rest[:i]
+	}
+	if ref.Tag == "" && ref.Digest == "" {
+		ref.Tag = "latest"
+	}
+	if ref.Layout {
+		ref.Repo = rest
+	} else {
+		host, repo, ok := strings.Cut(rest, "/")
+		if !ok || host == "" || repo == "" {
+			return ref, fmt.Errorf("%s: expected oci://host/repository[:tag|@digest]", loc)
+		}
+		ref.Host, ref.Repo = host, repo
+	}
+	if ref.Repo == "" {
+		return ref, fmt.Errorf("%s: missing repository", loc)
+	}
+	return ref, nil
+}
+
+// reference is what manifests are looked up by: the digest when pinned.
+func (r ociRef) reference() string {
+	if r.Digest != "" {
+		return r.Digest
+	}
+	return r.Tag
+}
+
+func (r ociRef) String() string {
+	s := "oci://" + r.Host + "/" + r.Repo
+	if r.Layout {
+		s = "oci-layout://" + r.Repo
+	}
+	if r.Tag != "" && r.Digest == "" {
+		s += ":" + r.Tag
+	}
+	if r.Digest != "" {
+		s += "@" + r.Digest
+	}
+	return s
+}
+
+// ociStore is the part of the distribution API dragon needs, implemented for
+// remote registries and for on-disk image layouts.
+type ociStore interface {
+	manifest(ctx context.Context, reference string) ([]byte, error)
+	blob(ctx context.Context, digest string) (io.ReadCloser, error)
+	hasBlob(ctx context.Context, digest string) (bool, error)
+	putBlob(ctx context.Context, d ociDescriptor, data []byte) error
+	putManifest(ctx context.Context, tag string, d ociDescriptor, data []byte) error
+}
+
+func (r ociRef) store(push bool) (ociStore, error) {
+	if r.Layout {
+		return &ociLayout{dir: r.Repo}, nil
+	}
+	if offline {
+		return nil, fmt.Errorf("cannot reach %s in --offline mode", r)
+	}
+	scheme := "https"
+	if h, _, err := net.SplitHostPort(r.Host); err == nil && isLoopback(h) || isLoopback(r.Host) {
+		scheme = "http"
+	}
+	actions := "pull"
+	if push {
+		actions = "pull,push"
+	}
+	return &ociRemote{base: scheme + "://" + r.Host, repo: r.Repo, actions: actions}, nil
+}
+
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(strings.Trim(host, "[]"))
+	return ip != nil && ip.IsLoopback()
+}
+
+// pullOCI copies the single payload layer of the artifact at loc to w,
+// checking every digest along the way. A layer over limit bytes is refused
+// before it is fetched, and the registry is never read past the size the
+// manifest declares.
+func pullOCI(ctx context.Context, loc string, w io.Writer, limit int64) (ociDescriptor, error) {
+	ref, err := parseOCIRef(loc)
+	if err != nil {
+		return ociDescriptor{}, err
+	}
+	st, err := ref.store(false)
+	if err != nil {
+		return ociDescriptor{}, err
+	}
+	data, err := st.manifest(ctx, ref.reference())
+	if err != nil {
+		return ociDescriptor{}, err
+	}
+	if ref.Digest != "" {
+		if err := verifyDigest(ref.Digest, data); err != nil {
+			return ociDescriptor{}, fmt.Errorf("%s: manifest %w", loc, err)
+		}
+	}
+	var m ociManifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return ociDescriptor{}, fmt.Errorf("%s: %w", loc, err)
+	}
+	if m.MediaType == ociIndexType || len(m.Manifests) > 0 {
+		return ociDescriptor{}, fmt.Errorf("%s is an image index; reference a single artifact manifest", loc)
+	}
+	layer, err := payloadLayer(m)
+	if err != nil {
+		return ociDescriptor{}, fmt.Errorf("%s: %w", loc, err)
+	}
+	if layer.Size < 0 || layer.Size > limit {
+		return ociDescriptor{}, fmt.Errorf("%s: layer is %s, over the %s limit", loc, formatBytes(layer.Size), formatBytes(limit))
+	}
+	h, want, err := parseDigest(layer.Digest)
+	if err != nil {
+		return ociDescriptor{}, fmt.Errorf("%s: layer %w", loc, err)
+	}
+	rc, err := st.blob(ctx, layer.Digest)
+	if err != nil {
+		return ociDescriptor{}, err
+	}
+	defer rc.Close()
+	n, err := io.Copy(io.MultiWriter(w, h), io.LimitReader(rc, layer.Size+1))
+	if err != nil {
+		return ociDescriptor{}, err
+	}
+	if n != layer.Size {
+		return ociDescriptor{}, fmt.Errorf("%s: layer size does not match manifest (%d)", loc, layer.Size)
+	}
+	if subtle.ConstantTimeCompare(h.Sum(nil), want) != 1 {
+		return ociDescriptor{}, fmt.Errorf("%s: layer digest mismatch: want %s", loc, layer.Digest)
+	}
+	return layer, nil
+}
+
+func payloadLayer(m ociManifest) (ociDescriptor, error) {
+	for _, l := range m.Layers {
+		if l.MediaType == ociBundleLayerType || strings.HasPrefix(l.MediaType, ociBundleTarType) || l.MediaType == ociIndexLayerType {
+			return l, nil
+		}
+	}
+	if len(m.Layers) == 1 {
+		return m.Layers[0], nil
+	}
+	return ociDescriptor{}, fmt.Errorf("expected one payload layer, found %d", len(m.Layers))
+}
+
+// pushOCI uploads data as a single-layer artifact tagged ref.Tag and returns
+// the manifest descriptor.
+func pushOCI(ctx context.Context, ref ociRef, artifactType, layerType, title string, data []byte) (ociDescriptor, error) {
+	if ref.Digest != "" {
+		return ociDescriptor{}, errors.New("push needs a tag, not a digest")
+	}
+	st, err := ref.store(true)
+	if err != nil {
+		return ociDescriptor{}, err
+	}
+	config := ociDescriptor{MediaType: ociEmptyType, Digest: sha256Digest(ociEmptyConfig), Size: int64(len(ociEmptyConfig))}
+	layer := ociDescriptor{MediaType: layerType, Digest: sha256Digest(data), Size: int64(len(data)), Annotations: map[string]string{ociTitleKey: title}}
+	for _, b := range []struct {
+		d    ociDescriptor
+		data []byte
+	}{{config, ociEmptyConfig}, {layer, data}} {
+		ok, err := st.hasBlob(ctx, b.d.Digest)
+		if err != nil {
+			return ociDescriptor{}, err
+		}
+		if !ok {
+			if err := st.putBlob(ctx, b.d, b.data); err != nil {
+				return ociDescriptor{}, err
+			}
+		}
+	}
+	m := ociManifest{SchemaVersion: 2, MediaType: ociManifestType, ArtifactType: artifactType, Config: config, Layers: []ociDescriptor{layer}}
+	raw, err := json.Marshal(m)
+	if err != nil {
+		return ociDescriptor{}, err
+	}
+	d := ociDescriptor{MediaType: ociManifestType, Digest: sha256Digest(raw), Size: int64(len(raw)), ArtifactType: artifactType}
+	return d, st.putManifest(ctx, ref.Tag, d, raw)
+}
+
+func sha256Digest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// ociLayout is an OCI image layout directory: an oci-layout marker, an
+// index.json naming tagged manifests and content-addressed blobs.
+type ociLayout struct{ dir string }
+
+func (l *ociLayout) blobPath(digest string) (string, error) {
+	algo, hexsum, ok := strings.Cut(digest, ":")
+	if !ok || algo == "" || hexsum == "" || strings.ContainsAny(digest, `/\`) {
+		return "", fmt.Errorf("invalid digest %q", digest)
+	}
+	return filepath.Join(l.dir, "blobs", algo, hexsum), nil
+}
+
+func (l *ociLayout) readIndex() (ociIndex, error) {
+	idx := ociIndex{SchemaVersion: 2, MediaType: ociIndexType}
+	b, err := os.ReadFile(filepath.Join(l.dir, "index.json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return idx, nil
+	}
+	if err != nil {
+		return idx, err
+	}
+	return idx, json.Unmarshal(b, &idx)
+}
+
+func (l *ociLayout) manifest(ctx context.Context, reference string) ([]byte, error) {
+	digest := reference
+	if !strings.Contains(reference, ":") {
+		idx, err := l.readIndex()
+		if err != nil {
+			return nil, err
+		}
+		digest = ""
+		for _, d := range idx.Manifests {
+			if d.Annotations[ociRefNameKey] == reference {
+				digest = d.Digest
+			}
+		}
+		if digest == "" {
+			return nil, fmt.Errorf("tag %q not found in %s", reference, l.dir)
+		}
+	}
+	rc, err := l.blob(ctx, digest)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return readManifestBody(rc, l.dir+"@"+digest)
+}
+
+func (l *ociLayout) blob(_ context.Context, digest string) (io.ReadCloser, error) {
+	p, err := l.blobPath(digest)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(p)
+}
+
+func (l *ociLayout) hasBlob(_ context.Context, digest string) (bool, error) {
+	p, err := l.blobPath(digest)
+	if err != nil {
+		return false, err
+	}
+	_, err = os.Stat(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (l *ociLayout) putBlob(_ context.Context, d ociDescriptor, data []byte) error {
+	p, err := l.blobPath(d.Digest)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+	return writeFileAtomic(p, data, 0o644)
+}
+
+func (l *ociLayout) putManifest(ctx context.Context, tag string, d ociDescriptor, data []byte) error {
+	if err := l.putBlob(ctx, d, data); err != nil {
+		return err
+	}
+	marker := filepath.Join(l.dir, "oci-layout")
+	if _, err := os.Stat(marker); err != nil {
+		if err := os.WriteFile(marker, []byte(`{"imageLayoutVersion":"1.0.0"}`), 0o644); err != nil {
+			return err
+		}
+	}
+	idx, err := l.readIndex()
+	if err != nil {
+		return err
+	}
+	kept := idx.Manifests[:0]
+	for _, m := range idx.Manifests {
+		if m.Annotations[ociRefNameKey] != tag {
+			kept = append(kept, m)
+		}
+	}
+	d.Annotations = map[string]string{ociRefNameKey: tag}
+	idx.Manifests = append(kept, d)
+	b, err := json.MarshalIndent(idx, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(l.dir, "index.json"), append(b, '\n'), 0o644)
+}
+
+// ociRemote talks to a registry implementing the OCI distribution API,
+// following the bearer token challenge when the registry issues one.
+type ociRemote struct {
+	base    string
+	repo    string
+	actions string
+	token   string
+}
+
+func (c *ociRemote) url(parts ...string) string {
+	return c.base + "/v2/" + c.repo + "/" + strings.Join(parts, "/")
+}
+
+func (c *ociRemote) do(ctx context.Context, method, target string, header http.Header, body []byte) (*http.Response, error) {
+	send := func() (*http.Response, error) {
+		var rd io.Reader
+		if body != nil {
+			rd = bytes.NewReader(body)
+		}
+		req, err := http.NewRequestWithContext(ctx, method, target, rd)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		if c.token != "" {
+			req.Header.Set("Authorization", "Bearer "+c.token)
+		} else {
+			applyAuth(req)
+		}
+		return doHTTP(req)
+	}
+	resp, err := send()
+	if err != nil || resp.StatusCode != http.StatusUnauthorized || c.token != "" {
+		return resp, err
+	}
+	scheme, params := parseChallenge(resp.Header.Get("WWW-Authenticate"))
+	if !strings.EqualFold(scheme, "bearer") || params["realm"] == "" {
+		return resp, nil
+	}
+	resp.Body.Close()
+	if c.token, err = c.fetchToken(ctx, params); err != nil {
+		return nil, err
+	}
+	return send()
+}
+
+// fetchToken exchanges the configured credentials for a registry token.
+func (c *ociRemote) fetchToken(ctx context.Context, params map[string]string) (string, error) {
+	u, err := url.Parse(params["realm"])
+	if err != nil {
+		return "", err
+	}
+	q := u.Query()
+	if params["service"] != "" {
+		q.Set("service", params["service"])
+	}
+	q.Set("scope", "repository:"+c.repo+":"+c.actions)
+	u.RawQuery = q.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	// Credentials belong to the registry host, not the token service.
+	probe, _ := http.NewRequest(http.MethodGet, c.base, nil)
+	applyAuth(probe)
+	if a := probe.Header.Get("Authorization"); a != "" {
+		req.Header.Set("Authorization", a)
+	}
+	resp, err := doHTTP(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return "", fmt.Errorf("token request to %s: %s", u.Host, resp.Status)
+	}
+	var tok struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tok); err != nil {
+		return "", err
+	}
+	if tok.Token == "" {
+		tok.Token = tok.AccessToken
+	}
+	if tok.Token == "" {
+		return "", fmt.Errorf("token request to %s returned no token", u.Host)
+	}
+	return tok.Token, nil
+}
+
+// parseChallenge splits a WWW-Authenticate header into its scheme and
+// key="value" parameters.
+func parseChallenge(h string) (string, map[string]string) {
+	scheme, rest, _ := strings.Cut(strings.TrimSpace(h), " ")
+	params := map[string]string{}
+	for rest = strings.TrimSpace(rest); rest != ""; {
+		key, after, ok := strings.Cut(rest, "=")
+		if !ok {
+			break
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		var val string
+		if strings.HasPrefix(after, `"`) {
+			end := strings.Index(after[1:], `"`)
+			if end < 0 {
+				val, rest = after[1:], ""
+			} else {
+				val, rest = after[1:end+1], after[end+2:]
+			}
+		} else {
+			val, rest, _ = strings.Cut(after, ",")
+		}
+		params[key] = strings.TrimSpace(val)
+		rest = strings.TrimLeft(rest, " ,")
+	}
+	return scheme, params
+}
+
+func ociError(resp *http.Response, what string) error {
+	b, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	return fmt.Errorf("%s: %s: %s", what, resp.Status, strings.TrimSpace(string(b)))
+}
+
+func (c *ociRemote) manifest(ctx context.Context, reference string) ([]byte, error) {
+	target := c.url("manifests", reference)
+	resp, err := c.do(ctx, http.MethodGet, target, http.Header{"Accept": {ociManifestType + ", " + ociIndexType}}, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, ociError(resp, "GET "+target)
+	}
+	return readManifestBody(resp.Body, target)
+}
+
+// readManifestBody reads a manifest of at most ociMaxManifestSize bytes.
+func readManifestBody(r io.Reader, what string) ([]byte, error) {
+	b, err := io.ReadAll(io.LimitReader(r, ociMaxManifestSize+1))
+	if err == nil && len(b) > ociMaxManifestSize {
+		err = fmt.Errorf("%s: manifest is larger than %s", what, formatBytes(ociMaxManifestSize))
+	}
+	return b, err
+}
+
+// blob returns the body of the blob; the caller closes it.
+func (c *ociRemote) blob(ctx context.Context, digest string) (io.ReadCloser, error) {
+	target := c.url("blobs", digest)
+	resp, err := c.do(ctx, http.MethodGet, target, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, ociError(resp, "GET "+target)
+	}
+	return resp.Body, nil
+}
+
+func (c *ociRemote) hasBlob(ctx context.Context, digest string) (bool, error) {
+	resp, err := c.do(ctx, http.MethodHead, c.url("blobs", digest), nil, nil)
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	}
+	return false, fmt.Errorf("HEAD %s: %s", c.url("blobs", digest), resp.Status)
+}
+
+// putBlob performs a monolithic upload: open a session, then PUT the bytes
+// to its location with the digest.
+func (c *ociRemote) putBlob(ctx context.Context, d ociDescriptor, data []byte) error {
+	start := c.url("blobs", "uploads") + "/"
+	resp, err := c.do(ctx, http.MethodPost, start, nil, nil)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusAccepted {
+		defer resp.Body.Close()
+		return ociError(resp, "POST "+start)
+	}
+	resp.Body.Close()
+	loc, err := resp.Location()
+	if err != nil {
+		return fmt.Errorf("POST %s: %w", start, err)
+	}
+	q := loc.Query()
+	q.Set("digest", d.Digest)
+	loc.RawQuery = q.Encode()
+	resp, err = c.do(ctx, http.MethodPut, loc.String(), http.Header{"Content-Type": {"application/octet-stream"}}, data)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return ociError(resp, "PUT blob "+d.Digest)
+	}
+	return nil
+}
+
+func (c *ociRemote) putManifest(ctx context.Context, tag string, d ociDescriptor, data []byte) error {
+	target := c.url("manifests", tag)
+	resp, err := c.do(ctx, http.MethodPut, target, http.Header{"Content-Type": {d.MediaType}}, data)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return ociError(resp, "PUT "+target)
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+/*
+ * // Copyright 2025 getDragon-dev
+ * // Licensed under the Apache License, Version 2.0 (the "License");
+ * // you may not use this file except in compliance with the License.
+ * // You may obtain a copy of the License at http://www.apache.org/licenses/LICENSE-2.0
+ * // Unless required by applicable law or agreed to in writing, software
+ * // distributed under the License is distributed on an "AS IS" BASIS,
+ * // WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * // See the License for the specific language governing permissions and
+ * // limitations under the License.
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestOCILayoutRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	dir := filepath.Join(t.TempDir(), "layout")
+	push := func(tag, data string) ociDescriptor {
+		t.Helper()
+		ref, err := parseOCIRef("oci-layout://" + dir + ":" + tag)
+		if err != nil {
+			t.Fatal(err)
+		}
+		d, err := pushOCI(ctx, ref, ociBlueprintType, ociBundleLayerType, "api.zip", []byte(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+	pull := func(loc string) (string, ociDescriptor, error) {
+		t.Helper()
+		var buf bytes.Buffer
+		layer, err := pullOCI(ctx, loc, &buf, 1<<20)
+		return buf.String(), layer, err
+	}
+
+	first := push("1.0.0", "bundle one")
+	second := push("1.0.0", "bundle two")
+	if first.Digest == second.Digest {
+		t.Fatal("different payloads produced the same manifest digest")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "oci-layout")); err != nil {
+		t.Errorf("oci-layout marker: %v", err)
+	}
+
+	// The tag moved to the second push; a digest pin still gets the first.
+	got, layer, err := pull("oci-layout://" + dir + ":1.0.0")
+	if err != nil || got != "bundle two" {
+		t.Fatalf("pull by tag: %q, %v", got, err)
+	}
+	if layer.MediaType != ociBundleLayerType || layer.Annotations[ociTitleKey] != "api.zip" {
+		t.Errorf("layer descriptor %+v", layer)
+	}
+	if got, _, err := pull("oci-layout://" + dir + "@" + first.Digest); err != nil || got != "bundle one" {
+		t.Errorf("pull by digest: %q, %v", got, err)
+	}
+	if _, _, err := pull("oci-layout://" + dir + ":2.0.0"); err == nil {
+		t.Error("unknown tag: want an error")
+	}
+	var buf bytes.Buffer
+	if _, err := pullOCI(ctx, "oci-layout://"+dir+":1.0.0", &buf, 5); err == nil || !strings.Contains(err.Error(), "limit") || buf.Len() > 0 {
+		t.Errorf("layer over the limit: got %v after %d bytes", err, buf.Len())
+	}
+
+	// A corrupted layer must fail the digest check.
+	p, err := (&ociLayout{dir: dir}).blobPath(layer.Digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(p, []byte("bundle 2!!"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := pull("oci-layout://" + dir + "@" + second.Digest); err == nil || !strings.Contains(err.Error(), "layer") {
+		t.Errorf("corrupted layer: got %v", err)
+	}
+
+	ref, _ := parseOCIRef("oci-layout://" + dir + "@" + first.Digest)
+	if _, err := pushOCI(ctx, ref, ociBlueprintType, ociBundleLayerType, "api.zip", []byte("x")); err == nil {
+		t.Error("push to a digest: want an error")
+	}
+}
+
+func TestOCIRemotePullLimits(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	layer := []byte("bundle!")
+	manifest := func(size int64) []byte {
+		b, _ := json.Marshal(ociManifest{SchemaVersion: 2, MediaType: ociManifestType,
+			Layers: []ociDescriptor{{MediaType: ociBundleLayerType, Digest: sha256Digest(layer), Size: size}}})
+		return b
+	}
+	var served atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/manifests/huge"):
+			_, _ = w.Write(manifest(1 << 40))
+		case strings.HasSuffix(r.URL.Path, "/manifests/lying"):
+			_, _ = w.Write(manifest(3))
+		case strings.Contains(r.URL.Path, "/blobs/"):
+			// Far more than any manifest declares.
+			for range 1 << 10 {
+				n, err := w.Write(bytes.Repeat([]byte("x"), 1<<10))
+				served.Add(int64(n))
+				if err != nil {
+					return
+				}
+			}
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "http://")
+	ctx := context.Background()
+
+	var buf bytes.Buffer
+	if _, err := pullOCI(ctx, "oci://"+host+"/bp:huge", &buf, 1<<20); err == nil || !strings.Contains(err.Error(), "limit") {
+		t.Errorf("huge layer: got %v", err)
+	}
+	if served.Load() != 0 {
+		t.Errorf("huge layer: fetched the blob anyway")
+	}
+	if _, err := pullOCI(ctx, "oci://"+host+"/bp:lying", &buf, 1<<20); err == nil || !strings.Contains(err.Error(), "size") {
+		t.Errorf("oversized blob: got %v", err)
+	}
+	if buf.Len() > 4 {
+		t.Errorf("oversized blob: read %d bytes past a declared size of 3", buf.Len())
+	}
+}
+
+func TestOCIRemotePushErrorDetails(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			http.NotFound(w, r)
+			return
+		}
+		http.Error(w, `{"errors":[{"code":"DENIED","message":"quota exceeded"}]}`, http.StatusForbidden)
+	}))
+	defer srv.Close()
+	ref, err := parseOCIRef("oci://" + strings.TrimPrefix(srv.URL, "http://") + "/bp:1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = pushOCI(context.Background(), ref, ociBlueprintType, ociBundleLayerType, "bp.zip", []byte("x"))
+	if err == nil || !strings.Contains(err.Error(), "quota exceeded") {
+		t.Errorf("got %v, want the registry's error message", err)
+	}
+}
+
+func TestParseOCIRef(t *testing.T) {
+	const digest = "sha256:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	tests := []struct {
+		loc  string
+		want ociRef
+		str  string
+	}{
+		{"oci://ghcr.io/team/api:1.2.0", ociRef{Host: "ghcr.io", Repo: "team/api", Tag: "1.2.0"}, ""},
+		{"oci://localhost:5000/api", ociRef{Host: "localhost:5000", Repo: "api", Tag: "latest"}, "oci://localhost:5000/api:latest"},
+		{"oci://ghcr.io/team/api@" + digest, ociRef{Host: "ghcr.io", Repo: "team/api", Digest: digest}, ""},
+		{"oci-layout:///srv/layout:v1", ociRef{Layout: true, Repo: "/srv/layout", Tag: "v1"}, ""},
+	}
+	for _, tt := range tests {
+		got, err := parseOCIRef(tt.loc)
+		if err != nil || got != tt.want {
+			t.Errorf("parseOCIRef(%q) = %+v, %v; want %+v", tt.loc, got, err, tt.want)
+		}
+		if tt.str == "" {
+			tt.str = tt.loc
+		}
+		if got.String() != tt.str {
+			t.Errorf("String() = %q, want %q", got.String(), tt.str)
+		}
+	}
+	for _, loc := range []string{"https://ghcr.io/api", "oci://ghcr.io", "oci://ghcr.io/api@sha256:zz"} {
+		if _, err := parseOCIRef(loc); err == nil {
+			t.Errorf("parseOCIRef(%q): want error", loc)
+		}
+	}
+}
@@ -0,0 +1,338 @@
+/*
+ * // Copyright 2025 getDragon-dev
+ * // Licensed under the Apache License, Version 2.0 (the "License");
+ * // you may not use this file except in compliance with the License.
+ * // You may obtain a copy of the License at http://www.apache.org/licenses/LICENSE-2.0
+ * // Unless required by applicable law or agreed to in writing, software
+ * // distributed under the License is distributed on an "AS IS" BASIS,
+ * // WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * // See the License for the specific language governing permissions and
+ * // limitations under the License.
+ */
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// projectConfigName is the project-local config file. It uses the same
+// schema as config.json and is layered over it: the nearest one found
+// walking up from the working directory wins.
+const projectConfigName = ".dragon.yaml"
+
+// findProjectConfig returns the path of the nearest .dragon.yaml, or "" when
+// there is none between the working directory and the filesystem root.
+func findProjectConfig() string {
+	dir, err := os.Getwd()
+	if err != nil {
+		return ""
+	}
+	for {
+		p := filepath.Join(dir, projectConfigName)
+		if fi, err := os.Stat(p); err == nil && !fi.IsDir() {
+			return p
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+func warnProject(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, "warning: "+format+"\n", args...)
+}
+
+// readProjectConfig parses a .dragon.yaml. Local registry and blueprint
+// paths are taken relative to the file so the project can be checked out
+// anywhere. A project file comes with whatever repository was cloned, so
+// its registries may not carry auth or public_keys: auth would send the
+// user's secrets to any host the file names, and public_keys belong to the
+// user's trust decisions. For the same reason its http section, which picks
+// trusted CAs and client certificates, is ignored.
+func readProjectConfig(p string) (Config, error) {
+	cfg, _, err := loadConfigFile(p)
+	if err != nil {
+		return Config{}, err
+	}
+	for i, bp := range cfg.BlueprintPaths {
+		cfg.BlueprintPaths[i] = expandPath(bp, filepath.Dir(p))
+	}
+	if cfg.HTTP != nil {
+		warnProject("%s: http is ignored; configure it in the user config", p)
+		cfg.HTTP = nil
+	}
+	for i, r := range cfg.Registries {
+		if r.Name == "" || r.URL == "" {
+			return Config{}, fmt.Errorf("%s: registries[%d] needs a name and a url", p, i)
+		}
+		if !isRemote(r.URL) && !isGit(r.URL) && !isOCI(r.URL) && !filepath.IsAbs(r.URL) {
+			cfg.Registries[i].URL = filepath.Join(filepath.Dir(p), r.URL)
+		}
+		if r.Auth != nil || len(r.PublicKeys) > 0 {
+			warnProject("%s: auth and public_keys of registry %q are ignored; configure them in the user config", p, r.Name)
+			cfg.Registries[i].Auth, cfg.Registries[i].PublicKeys = nil, nil
+		}
+	}
+	return cfg, nil
+}
+
+// configSources records where each effective config value came from, keyed
+// by "default", "order", "cache_ttl", "max_bundle_size", "max_unpacked_size",
+// "blueprint_paths", "registries.<name>", "vars.<key>" and "pins.<name>".
+type configSources map[string]string
+
+type mergedConfig struct {
+	cfg Config
+	src configSources
+}
+
+// merged holds the last merged config and the inputs it was merged from.
+var merged struct {
+	sync.Mutex
+	key string
+	m   mergedConfig
+	err error
+}
+
+// configKey names what mergeConfig reads besides file contents: the user
+// config's path, the working directory and the DRAGON_* environment.
+func configKey() string {
+	wd, _ := os.Getwd()
+	key := []string{configPath(), wd}
+	for _, kv := range os.Environ() {
+		if strings.HasPrefix(kv, "DRAGON_") {
+			key = append(key, kv)
+		}
+	}
+	return strings.Join(key, "\x00")
+}
+
+// loadEffectiveConfig merges the config once for as long as its inputs stay
+// the same, which in a single command is once per process; writeConfig
+// calls resetEffectiveConfig to drop it.
+func loadEffectiveConfig() (mergedConfig, error) {
+	key := configKey()
+	merged.Lock()
+	defer merged.Unlock()
+	if merged.key != key {
+		merged.m, merged.err = mergeConfig()
+		merged.key = key
+	}
+	return merged.m, merged.err
+}
+
+func resetEffectiveConfig() {
+	merged.Lock()
+	merged.key = ""
+	merged.Unlock()
+}
+
+// effectiveConfig returns the merged config. Callers get their own copy of
+// its slices and maps and may change them.
+func effectiveConfig() (Config, configSources, error) {
+	m, err := loadEffectiveConfig()
+	if err != nil {
+		return Config{}, nil, err
+	}
+	cfg := m.cfg
+	cfg.Registries = slices.Clone(cfg.Registries)
+	cfg.Order = slices.Clone(cfg.Order)
+	cfg.BlueprintPaths = slices.Clone(cfg.BlueprintPaths)
+	cfg.Vars = maps.Clone(cfg.Vars)
+	cfg.Pins = maps.Clone(cfg.Pins)
+	return cfg, maps.Clone(m.src), nil
+}
+
+// mergeConfig merges the project's .dragon.yaml over the user config.
+// Scalars set by the project replace the user's, except that the size
+// limits can only be lowered; vars and pins are merged by name with the
+// project winning. A project registry named like a user registry only moves
+// it in the search order: the user's URL, auth and keys stay. Registries
+// and blueprint paths the project declares are searched ahead of the
+// user's. DRAGON_* environment overrides are applied last.
+func mergeConfig() (mergedConfig, error) {
+	src := configSources{}
+	user, err := readConfig()
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return mergedConfig{}, err
+	}
+	for i, bp := range user.BlueprintPaths {
+		user.BlueprintPaths[i] = expandPath(bp, filepath.Dir(configPath()))
+	}
+	userSrc := "user config " + configPath()
+	note(src, user, userSrc)
+
+	p := findProjectConfig()
+	if p == "" {
+		applyEnvConfig(&user, src)
+		return mergedConfig{user, src}, nil
+	}
+	proj, err := readProjectConfig(p)
+	if err != nil {
+		return mergedConfig{}, err
+	}
+	note(src, proj, p)
+
+	cfg := user
+	cfg.Registries = slices.Clone(user.Registries)
+	for _, r := range proj.Registries {
+		if i := slices.IndexFunc(cfg.Registries, func(x Registry) bool { return x.Name == r.Name }); i >= 0 {
+			if cfg.Registries[i].URL != r.URL {
+				warnProject("%s: registry %q is already configured with another url; using the user config's", p, r.Name)
+			}
+			src["registries."+r.Name] = userSrc
+			continue
+		}
+		cfg.Registries = append(cfg.Registries, r)
+	}
+	if proj.Default != "" {
+		cfg.Default = proj.Default
+	}
+	if proj.CacheTTL != "" {
+		cfg.CacheTTL = proj.CacheTTL
+	}
+	if proj.MaxBundleSize != "" {
+		if lowersLimit(p, "max_bundle_size", proj.MaxBundleSize, user.MaxBundleSize, defaultMaxBundleSize) {
+			cfg.MaxBundleSize = proj.MaxBundleSize
+		} else {
+			unnote(src, "max_bundle_size", user.MaxBundleSize, userSrc)
+		}
+	}
+	if proj.MaxUnpackedSize != "" {
+		if lowersLimit(p, "max_unpacked_size", proj.MaxUnpackedSize, user.MaxUnpackedSize, defaultMaxUnpackedSize) {
+			cfg.MaxUnpackedSize = proj.MaxUnpackedSize
+		} else {
+			unnote(src, "max_unpacked_size", user.MaxUnpackedSize, userSrc)
+		}
+	}
+	if len(proj.Order) > 0 || len(proj.Registries) > 0 {
+		order := slices.Clone(proj.Order)
+		for _, r := range proj.Registries {
+			if !slices.Contains(order, r.Name) {
+				order = append(order, r.Name)
+			}
+		}
+		rest := user.Order
+		if len(rest) == 0 {
+			for _, r := range user.Registries {
+				rest = append(rest, r.Name)
+			}
+		}
+		for _, name := range rest {
+			if !slices.Contains(order, name) {
+				order = append(order, name)
+			}
+		}
+		cfg.Order = order
+		src["order"] = p + " (merged over " + userSrc + ")"
+	}
+	if len(proj.BlueprintPaths) > 0 {
+		cfg.BlueprintPaths = slices.Concat(proj.BlueprintPaths, user.BlueprintPaths)
+		if len(user.BlueprintPaths) > 0 {
+			src["blueprint_paths"] = p + " (ahead of " + userSrc + ")"
+		}
+	}
+	cfg.Vars = mergeMaps(user.Vars, proj.Vars)
+	cfg.Pins = mergeMaps(user.Pins, proj.Pins)
+	applyEnvConfig(&cfg, src)
+	return mergedConfig{cfg, src}, nil
+}
+
+// lowersLimit reports whether a project's size limit may replace the user's.
+// A cloned repository must not be able to raise the limits that protect
+// the user from oversized bundles, so only a stricter value is taken.
+func lowersLimit(p, key, proj, user string, def int64) bool {
+	limit := def
+	if user != "" {
+		n, err := parseByteSize(user)
+		if err != nil {
+			// bundleLimits reports the user's error; keep it.
+			return false
+		}
+		limit = n
+	}
+	n, err := parseByteSize(proj)
+	if err != nil {
+		warnProject("%s: %s: %v; ignored", p, key, err)
+		return false
+	}
+	if n > limit {
+		warnProject("%s: %s %s is above the user limit of %s; a project can only lower it", p, key, proj, formatBytes(limit))
+		return false
+	}
+	return true
+}
+
+// unnote gives key back to the user config after a project value for it
+// was ignored.
+func unnote(src configSources, key, user, userSrc string) {
+	if user != "" {
+		src[key] = userSrc
+	} else {
+		delete(src, key)
+	}
+}
+
+func note(src configSources, cfg Config, from string) {
+	if cfg.Default != "" {
+		src["default"] = from
+	}
+	if len(cfg.Order) > 0 {
+		src["order"] = from
+	}
+	if cfg.CacheTTL != "" {
+		src["cache_ttl"] = from
+	}
+	if len(cfg.BlueprintPaths) > 0 {
+		src["blueprint_paths"] = from
+	}
+	if cfg.MaxBundleSize != "" {
+		src["max_bundle_size"] = from
+	}
+	if cfg.MaxUnpackedSize != "" {
+		src["max_unpacked_size"] = from
+	}
+	for _, r := range cfg.Registries {
+		src["registries."+r.Name] = from
+	}
+	for k := range cfg.Vars {
+		src["vars."+k] = from
+	}
+	for k := range cfg.Pins {
+		src["pins."+k] = from
+	}
+	if h := cfg.HTTP; h != nil {
+		for _, kv := range [][2]string{{"ca_file", h.CAFile}, {"client_cert", h.ClientCert}, {"client_key", h.ClientKey}, {"timeout", h.Timeout}} {
+			if kv[1] != "" {
+				src["http."+kv[0]] = from
+			}
+		}
+		if h.Retries != nil {
+			src["http.retries"] = from
+		}
+	}
+}
+
+func mergeMaps[V any](base, over map[string]V) map[string]V {
+	if len(base) == 0 && len(over) == 0 {
+		return nil
+	}
+	out := make(map[string]V, len(base)+len(over))
+	for k, v := range base {
+		out[k] = v
+	}
+	for k, v := range over {
+		out[k] = v
+	}
+	return out
+}
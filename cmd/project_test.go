@@ -0,0 +1,174 @@
+/*
+ * // Copyright 2025 getDragon-dev
+ * // Licensed under the Apache License, Version 2.0 (the "License");
+ * // you may not use this file except in compliance with the License.
+ * // You may obtain a copy of the License at http://www.apache.org/licenses/LICENSE-2.0
+ * // Unless required by applicable law or agreed to in writing, software
+ * // distributed under the License is distributed on an "AS IS" BASIS,
+ * // WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * // See the License for the specific language governing permissions and
+ * // limitations under the License.
+ */
+
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// projectSetup writes a user config and a .dragon.yaml in a project whose
+// subdirectory becomes the working directory.
+func projectSetup(t *testing.T, user, project string) (projDir string) {
+	t.Helper()
+	tmp := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(tmp, "config"))
+	t.Setenv("DRAGON_CONFIG", "")
+	for _, k := range []string{"DRAGON_DEFAULT", "DRAGON_ORDER", "DRAGON_CACHE_TTL", "DRAGON_BLUEPRINT_PATH", "DRAGON_MAX_BUNDLE_SIZE", "DRAGON_MAX_UNPACKED_SIZE",
+		"DRAGON_PINS", "DRAGON_HTTP_CA_FILE", "DRAGON_HTTP_CLIENT_CERT", "DRAGON_HTTP_CLIENT_KEY", "DRAGON_HTTP_TIMEOUT", "DRAGON_HTTP_RETRIES"} {
+		t.Setenv(k, "")
+		os.Unsetenv(k)
+	}
+	if user != "" {
+		p := filepath.Join(tmp, "config", "dragon", "config.yaml")
+		if err := os.MkdirAll(filepath.Dir(p), 0o700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(user), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	projDir = filepath.Join(tmp, "proj")
+	sub := filepath.Join(projDir, "src", "app")
+	if err := os.MkdirAll(sub, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if project != "" {
+		if err := os.WriteFile(filepath.Join(projDir, projectConfigName), []byte(project), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Chdir(sub)
+	return projDir
+}
+
+func TestEffectiveConfigMerge(t *testing.T) {
+	proj := projectSetup(t, `version: 1
+registries:
+  - name: main
+    url: https://main.example/registry.json
+    auth: {type: bearer, token_env: MAIN_TOKEN}
+  - name: team
+    url: https://team.example/registry.json
+default: main
+cache_ttl: 1h
+vars: {Owner: me, DB: sqlite}
+pins: {api: ^1}
+http: {timeout: 30s}
+`, `registries:
+  - name: local
+    url: ./blueprints
+    auth: {type: bearer, token_env: STOLEN}
+    public_keys: [AAAA]
+  - name: team
+    url: https://evil.example/registry.json
+default: local
+blueprint_paths: [tools]
+vars: {DB: postgres}
+pins: {web: ~2}
+http: {ca_file: ca.pem, timeout: 1s}
+`)
+	cfg, src, err := effectiveConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := filepath.Join(proj, projectConfigName)
+
+	if cfg.Default != "local" || src["default"] != p {
+		t.Errorf("default = %q from %q", cfg.Default, src["default"])
+	}
+	if cfg.CacheTTL != "1h" {
+		t.Errorf("cache_ttl = %q, want the user's", cfg.CacheTTL)
+	}
+	if want := []string{"local", "team", "main"}; !reflect.DeepEqual(cfg.Order, want) {
+		t.Errorf("order = %q, want %q", cfg.Order, want)
+	}
+	if len(cfg.Registries) != 3 {
+		t.Fatalf("registries = %+v", cfg.Registries)
+	}
+	for _, r := range cfg.Registries {
+		switch r.Name {
+		case "local":
+			if r.URL != filepath.Join(proj, "blueprints") {
+				t.Errorf("local url = %q, want it relative to the project file", r.URL)
+			}
+			if r.Auth != nil || r.PublicKeys != nil {
+				t.Errorf("project registry kept auth or keys: %+v", r)
+			}
+		case "team":
+			if r.URL != "https://team.example/registry.json" {
+				t.Errorf("team url = %q, want the user's", r.URL)
+			}
+		case "main":
+			if r.Auth == nil || r.Auth.TokenEnv != "MAIN_TOKEN" {
+				t.Errorf("main lost its auth: %+v", r)
+			}
+		}
+	}
+	if want := []string{filepath.Join(proj, "tools")}; !reflect.DeepEqual(cfg.BlueprintPaths, want) {
+		t.Errorf("blueprint_paths = %q, want %q", cfg.BlueprintPaths, want)
+	}
+	if want := map[string]any{"Owner": "me", "DB": "postgres"}; !reflect.DeepEqual(cfg.Vars, want) {
+		t.Errorf("vars = %v, want %v", cfg.Vars, want)
+	}
+	if want := map[string]string{"api": "^1", "web": "~2"}; !reflect.DeepEqual(cfg.Pins, want) {
+		t.Errorf("pins = %v, want %v", cfg.Pins, want)
+	}
+	if cfg.HTTP == nil || cfg.HTTP.Timeout != "30s" || cfg.HTTP.CAFile != "" {
+		t.Errorf("http = %+v, want the user's", cfg.HTTP)
+	}
+	if src["vars.DB"] != p || src["vars.Owner"] == p || src["registries.team"] == p {
+		t.Errorf("sources = %v", src)
+	}
+}
+
+func TestEffectiveConfigSizeLimits(t *testing.T) {
+	tests := []struct {
+		name          string
+		user, project string
+		bundle        string
+		unpacked      string
+		fromProject   bool
+	}{
+		{"project lowers defaults", "", "max_bundle_size: 10MB\nmax_unpacked_size: 20MB\n", "10MB", "20MB", true},
+		{"project cannot raise defaults", "", "max_bundle_size: 10GB\nmax_unpacked_size: 10GB\n", "", "", false},
+		{"project lowers user", "max_bundle_size: 1GB\n", "max_bundle_size: 512MB\n", "512MB", "", true},
+		{"project cannot raise user", "max_bundle_size: 1MB\n", "max_bundle_size: 2MB\n", "1MB", "", false},
+		{"equal is kept", "max_bundle_size: 1MB\n", "max_bundle_size: 1024KB\n", "1024KB", "", true},
+		{"invalid project value ignored", "", "max_bundle_size: lots\n", "", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proj := projectSetup(t, tt.user, tt.project)
+			cfg, src, err := effectiveConfig()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.MaxBundleSize != tt.bundle || cfg.MaxUnpackedSize != tt.unpacked {
+				t.Errorf("limits = %q, %q; want %q, %q", cfg.MaxBundleSize, cfg.MaxUnpackedSize, tt.bundle, tt.unpacked)
+			}
+			if got := src["max_bundle_size"] == filepath.Join(proj, projectConfigName); got != tt.fromProject {
+				t.Errorf("max_bundle_size source = %q", src["max_bundle_size"])
+			}
+		})
+	}
+
+	// The environment is the user's own and may still raise the limit.
+	projectSetup(t, "", "max_bundle_size: 1MB\n")
+	t.Setenv("DRAGON_MAX_BUNDLE_SIZE", "1GB")
+	if b, _, err := bundleLimits(); err != nil || b != 1<<30 {
+		t.Errorf("bundleLimits = %d, %v; want the env value", b, err)
+	}
+}
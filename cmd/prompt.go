@@ -0,0 +1,479 @@
+/*
+ * // Copyright 2025 getDragon-dev
+ * // Licensed under the Apache License, Version 2.0 (the "License");
+ * // you may not use this file except in compliance with the License.
+ * // You may obtain a copy of the License at http://www.apache.org/licenses/LICENSE-2.0
+ * // Unless required by applicable law or agreed to in writing, software
+ * // distributed under the License is distributed on an "AS IS" BASIS,
+ * // WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * // See the License for the specific language governing permissions and
+ * // limitations under the License.
+ */
+
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var errInterrupted = errors.New("interrupted")
+
+// canPrompt reports whether dragon may ask the user for input: stdin and
+// stderr are a terminal and neither --no-input nor --yes is set.
+func canPrompt() bool {
+	return !noInput && !assumeYes && isTerminal(os.Stdin) && isTerminal(os.Stderr)
+}
+
+// prompter asks questions on stderr and reads the answers from stdin. Select
+// lists need the terminal in raw mode, which goes through stty, the same way
+// git is run; without stty they fall back to numbered lists.
+type prompter struct {
+	in  *bufio.Reader
+	out io.Writer
+	raw bool
+}
+
+func newPrompter() *prompter {
+	_, err := exec.LookPath("stty")
+	return &prompter{in: bufio.NewReader(os.Stdin), out: os.Stderr, raw: err == nil && runtime.GOOS != "windows"}
+}
+
+func stty(args ...string) (string, error) {
+	c := exec.Command("stty", args...)
+	c.Stdin = os.Stdin
+	out, err := c.Output()
+	return strings.TrimSpace(string(out)), err
+}
+
+// termSaved holds the terminal settings to restore if dragon is killed
+// while the terminal is in raw or no-echo mode.
+var (
+	termMu    sync.Mutex
+	termSaved string
+)
+
+// termMode applies stty settings and returns a function restoring the
+// previous ones.
+func termMode(settings ...string) (func(), error) {
+	saved, err := stty("-g")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := stty(settings...); err != nil {
+		return nil, err
+	}
+	termMu.Lock()
+	termSaved = saved
+	termMu.Unlock()
+	return restoreTerminal, nil
+}
+
+func restoreTerminal() {
+	termMu.Lock()
+	defer termMu.Unlock()
+	if termSaved != "" {
+		_, _ = stty(termSaved)
+		termSaved = ""
+	}
+}
+
+func (p *prompter) readLine() (string, error) {
+	s, err := p.in.ReadString('\n')
+	if err != nil && s == "" {
+		if errors.Is(err, io.EOF) {
+			return "", errors.New("no input: stdin was closed")
+		}
+		return "", err
+	}
+	return strings.TrimSpace(s), nil
+}
+
+// readSecret reads a line without echoing it.
+func (p *prompter) readSecret() (string, error) {
+	if p.raw {
+		if restore, err := termMode("-echo"); err == nil {
+			defer fmt.Fprintln(p.out)
+			defer restore()
+		}
+	}
+	return p.readLine()
+}
+
+type key int
+
+const (
+	keyOther key = iota
+	keyUp
+	keyDown
+	keyEnter
+	keySpace
+	keyHelp
+	keyAll
+	keyInterrupt
+)
+
+// readKey reads one keypress in raw mode; arrow keys arrive as escape
+// sequences.
+func (p *prompter) readKey() (key, error) {
+	b, err := p.in.ReadByte()
+	if err != nil {
+		return keyInterrupt, err
+	}
+	switch b {
+	case 3, 4:
+		return keyInterrupt, nil
+	case '\r', '\n':
+		return keyEnter, nil
+	case ' ':
+		return keySpace, nil
+	case '?':
+		return keyHelp, nil
+	case 'a':
+		return keyAll, nil
+	case 'k':
+		return keyUp, nil
+	case 'j':
+		return keyDown, nil
+	case 0x1b:
+		if b, _ := p.in.ReadByte(); b != '[' {
+			return keyOther, nil
+		}
+		switch b, _ := p.in.ReadByte(); b {
+		case 'A':
+			return keyUp, nil
+		case 'B':
+			return keyDown, nil
+		}
+	}
+	return keyOther, nil
+}
+
+// menu draws an interactive list in raw mode and redraws it in place after
+// every keypress. It returns the index under the cursor once enter is
+// pressed and done accepts.
+func (p *prompter) menu(label, hint, help string, choices []string, cur int, mark func(i int) string, onKey func(k key, cur int), done func() error) (int, error) {
+	restore, err := termMode("raw", "-echo")
+	if err != nil {
+		return 0, err
+	}
+	defer restore()
+	fmt.Fprint(p.out, "\x1b[?25l")
+	defer fmt.Fprint(p.out, "\x1b[?25h")
+	if help != "" {
+		hint += ", ? for help"
+	}
+	lines, showHelp, problem := 0, false, ""
+	draw := func() {
+		var b strings.Builder
+		if lines > 0 {
+			fmt.Fprintf(&b, "\x1b[%dF\x1b[J", lines)
+		}
+		lines = 0
+		line := func(s string) {
+			b.WriteString(s + "\r\n")
+			lines++
+		}
+		line(fmt.Sprintf("? %s  (%s)", label, hint))
+		if showHelp {
+			for _, h := range strings.Split(strings.TrimSpace(help), "\n") {
+				line("  " + h)
+			}
+		}
+		for i, c := range choices {
+			pointer := "  "
+			if i == cur {
+				pointer = "> "
+			}
+			line(pointer + mark(i) + c)
+		}
+		if problem != "" {
+			line("  ✗ " + problem)
+		}
+		fmt.Fprint(p.out, b.String())
+	}
+	for {
+		draw()
+		k, err := p.readKey()
+		if err != nil {
+			return 0, err
+		}
+		problem = ""
+		switch k {
+		case keyInterrupt:
+			fmt.Fprintf(p.out, "\x1b[%dF\x1b[J", lines)
+			return 0, errInterrupted
+		case keyUp:
+			cur = (cur + len(choices) - 1) % len(choices)
+		case keyDown:
+			cur = (cur + 1) % len(choices)
+		case keyHelp:
+			showHelp = !showHelp && help != ""
+		case keyEnter:
+			if err := done(); err != nil {
+				problem = err.Error()
+				continue
+			}
+			fmt.Fprintf(p.out, "\x1b[%dF\x1b[J", lines)
+			return cur, nil
+		default:
+			onKey(k, cur)
+		}
+	}
+}
+
+// selectOne asks for one of choices.
+func (p *prompter) selectOne(label, help string, choices []string, def string) (string, error) {
+	cur := max(slices.Index(choices, def), 0)
+	if p.raw {
+		i, err := p.menu(label, "↑/↓ to move, enter to select", help, choices, cur,
+			func(int) string { return "" }, func(key, int) {}, func() error { return nil })
+		if err == nil {
+			fmt.Fprintf(p.out, "✔ %s: %s\n", label, choices[i])
+			return choices[i], nil
+		}
+		if errors.Is(err, errInterrupted) {
+			return "", err
+		}
+	}
+	for i, c := range choices {
+		fmt.Fprintf(p.out, "  %d) %s\n", i+1, c)
+	}
+	for {
+		s, err := p.ask(label, choices[cur], help, false)
+		if err != nil {
+			return "", err
+		}
+		if s == "" {
+			return choices[cur], nil
+		}
+		if n, err := strconv.Atoi(s); err == nil && n >= 1 && n <= len(choices) {
+			return choices[n-1], nil
+		}
+		if slices.Contains(choices, s) {
+			return s, nil
+		}
+		fmt.Fprintf(p.out, "  ✗ enter a number from 1 to %d or one of the choices\n", len(choices))
+	}
+}
+
+// multiSelect asks for any number of choices; check validates the
+// selection before it is accepted.
+func (p *prompter) multiSelect(label, help string, choices, defs []string, check func([]string) error) ([]string, error) {
+	picked := make([]bool, len(choices))
+	for i, c := range choices {
+		picked[i] = slices.Contains(defs, c)
+	}
+	selection := func() []string {
+		out := []string{}
+		for i, c := range choices {
+			if picked[i] {
+				out = append(out, c)
+			}
+		}
+		return out
+	}
+	if p.raw {
+		_, err := p.menu(label, "space to toggle, a for all, enter to confirm", help, choices, 0,
+			func(i int) string {
+				if picked[i] {
+					return "[x] "
+				}
+				return "[ ] "
+			},
+			func(k key, cur int) {
+				switch k {
+				case keySpace:
+					picked[cur] = !picked[cur]
+				case keyAll:
+					all := !slices.Contains(picked, false)
+					for i := range picked {
+						picked[i] = !all
+					}
+				}
+			},
+			func() error { return check(selection()) })
+		if err == nil {
+			fmt.Fprintf(p.out, "✔ %s: %s\n", label, strings.Join(selection(), ", "))
+			return selection(), nil
+		}
+		if errors.Is(err, errInterrupted) {
+			return nil, err
+		}
+	}
+	for i, c := range choices {
+		fmt.Fprintf(p.out, "  %d) %s\n", i+1, c)
+	}
+	for {
+		s, err := p.ask(label+" (comma-separated numbers or names)", strings.Join(defs, ","), help, false)
+		if err != nil {
+			return nil, err
+		}
+		if s == "" {
+			s = strings.Join(defs, ",")
+		}
+		var sel []string
+		var bad string
+		for _, it := range splitList(s) {
+			if n, err := strconv.Atoi(it); err == nil && n >= 1 && n <= len(choices) {
+				it = choices[n-1]
+			}
+			if !slices.Contains(choices, it) {
+				bad = it
+				break
+			}
+			if !slices.Contains(sel, it) {
+				sel = append(sel, it)
+			}
+		}
+		switch {
+		case bad != "":
+			fmt.Fprintf(p.out, "  ✗ %q is not one of the choices\n", bad)
+		case check(sel) != nil:
+			fmt.Fprintf(p.out, "  ✗ %v\n", check(sel))
+		default:
+			return sel, nil
+		}
+	}
+}
+
+// ask prints a question and reads a line; "?" shows help and asks again.
+// A secret answer is not echoed.
+func (p *prompter) ask(label, def, help string, secret bool) (string, error) {
+	for {
+		hint := ""
+		if def != "" {
+			hint = " [" + def + "]"
+		}
+		if help != "" {
+			hint += " (? for help)"
+		}
+		fmt.Fprintf(p.out, "? %s%s: ", label, hint)
+		read := p.readLine
+		if secret {
+			read = p.readSecret
+		}
+		s, err := read()
+		if err != nil {
+			return "", err
+		}
+		if s == "?" && help != "" {
+			fmt.Fprintf(p.out, "  %s\n", strings.ReplaceAll(strings.TrimSpace(help), "\n", "\n  "))
+			continue
+		}
+		return s, nil
+	}
+}
+
+// input asks for free text, re-asking until valid accepts it. ok is false
+// when the answer was left empty and required is not set.
+func (p *prompter) input(label, help, def string, secret, required bool, valid func(string) error) (string, bool, error) {
+	for {
+		s, err := p.ask(label, def, help, secret)
+		if err != nil {
+			return "", false, err
+		}
+		if s == "" {
+			if !required || def != "" {
+				return "", false, nil
+			}
+			fmt.Fprintln(p.out, "  ✗ a value is required")
+			continue
+		}
+		if err := valid(s); err != nil {
+			fmt.Fprintf(p.out, "  ✗ %v\n", err)
+			continue
+		}
+		return s, true, nil
+	}
+}
+
+// confirm asks a yes/no question.
+func (p *prompter) confirm(label, help string, def bool) (bool, error) {
+	hint := "y/N"
+	if def {
+		hint = "Y/n"
+	}
+	for {
+		s, err := p.ask(label+" ("+hint+")", "", help, false)
+		if err != nil {
+			return false, err
+		}
+		if s == "" {
+			return def, nil
+		}
+		if b, err := (variable{Type: typeBool}).coerceItem(s); err == nil {
+			return b.(bool), nil
+		}
+		fmt.Fprintln(p.out, "  ✗ answer yes or no")
+	}
+}
+
+// askVariables returns an askFunc that prompts with the control suited to
+// each variable's type: a select list for enums, a confirmation for bools,
+// a multi-select for lists with choices, and masked input for secrets.
+// Unless all is set, only required variables without a default are asked.
+func (p *prompter) askVariables(all bool) askFunc {
+	return func(v variable, vars map[string]any) (any, bool, error) {
+		if !all && (!v.Required || v.Default != nil) {
+			return nil, false, nil
+		}
+		label := v.Name
+		if v.Description != "" {
+			label = v.Description + " (" + v.Name + ")"
+		}
+		var def any
+		if v.Default != nil {
+			def, _ = v.coerce(v.Default)
+		}
+		switch {
+		case v.kind() == typeBool:
+			b, _ := def.(bool)
+			ans, err := p.confirm(label, v.Help, b)
+			return ans, err == nil, err
+		case v.kind() == typeList && len(v.Enum) > 0:
+			defs, _ := def.([]string)
+			sel, err := p.multiSelect(label, v.Help, v.choices(), defs, func(sel []string) error {
+				if v.Required && len(sel) == 0 {
+					return errors.New("select at least one")
+				}
+				return v.check(sel)
+			})
+			return sel, err == nil, err
+		case len(v.Enum) > 0:
+			s, err := p.selectOne(label, v.Help, v.choices(), fmt.Sprint(v.Default))
+			return s, err == nil, err
+		}
+		defText := ""
+		switch d := def.(type) {
+		case nil:
+		case []string:
+			defText = strings.Join(d, ",")
+		default:
+			defText = fmt.Sprint(d)
+		}
+		if v.kind() == typeList {
+			label += " (comma-separated)"
+		}
+		s, ok, err := p.input(label, v.Help, defText, v.Secret, v.Required, func(s string) error {
+			c, err := v.coerce(s)
+			if err == nil {
+				err = v.check(c)
+			}
+			return err
+		})
+		if !ok {
+			return nil, false, err
+		}
+		return s, true, err
+	}
+}
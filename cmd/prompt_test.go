@@ -0,0 +1,148 @@
+/*
+ * // Copyright 2025 getDragon-dev
+ * // Licensed under the Apache License, Version 2.0 (the "License");
+ * // you may not use this file except in compliance with the License.
+ * // You may obtain a copy of the License at http://www.apache.org/licenses/LICENSE-2.0
+ * // Unless required by applicable law or agreed to in writing, software
+ * // distributed under the License is distributed on an "AS IS" BASIS,
+ * // WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * // See the License for the specific language governing permissions and
+ * // limitations under the License.
+ */
+
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// scripted returns a non-raw prompter reading the given answers, as when
+// stty is unavailable, and the buffer it writes to.
+func scripted(answers string) (*prompter, *strings.Builder) {
+	out := &strings.Builder{}
+	return &prompter{in: bufio.NewReader(strings.NewReader(answers)), out: out}, out
+}
+
+func TestPromptNumberedFallback(t *testing.T) {
+	p, out := scripted("9\nmysql\n2\n")
+	got, err := p.selectOne("Database", "", []string{"sqlite", "postgres"}, "sqlite")
+	if err != nil || got != "postgres" {
+		t.Fatalf("selectOne = %q, %v", got, err)
+	}
+	for _, want := range []string{"  1) sqlite\n  2) postgres\n", "? Database [sqlite]: ", "✗ enter a number from 1 to 2"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output lacks %q:\n%s", want, out)
+		}
+	}
+	if n := strings.Count(out.String(), "✗"); n != 2 {
+		t.Errorf("%d errors shown, want 2:\n%s", n, out)
+	}
+
+	p, _ = scripted("\n")
+	if got, err := p.selectOne("Database", "", []string{"sqlite", "postgres"}, "postgres"); err != nil || got != "postgres" {
+		t.Errorf("empty answer = %q, %v; want the default", got, err)
+	}
+	p, _ = scripted("sqlite\n")
+	if got, err := p.selectOne("Database", "", []string{"sqlite", "postgres"}, ""); err != nil || got != "sqlite" {
+		t.Errorf("answer by name = %q, %v", got, err)
+	}
+
+	atLeastOne := func(sel []string) error {
+		if len(sel) == 0 {
+			return errors.New("select at least one")
+		}
+		return nil
+	}
+	p, out = scripted("1,redis\n\n3, 1,3\n")
+	sel, err := p.multiSelect("Features", "", []string{"auth", "metrics", "cache"}, nil, atLeastOne)
+	if err != nil || !reflect.DeepEqual(sel, []string{"cache", "auth"}) {
+		t.Fatalf("multiSelect = %q, %v", sel, err)
+	}
+	for _, want := range []string{"  3) cache\n", `✗ "redis" is not one of the choices`, "✗ select at least one"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output lacks %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestPromptReasks(t *testing.T) {
+	p, out := scripted("?\nmaybe\ny\n")
+	if ok, err := p.confirm("Enable auth", "Adds login routes.", false); err != nil || !ok {
+		t.Fatalf("confirm = %v, %v", ok, err)
+	}
+	if !strings.Contains(out.String(), "  Adds login routes.\n") || !strings.Contains(out.String(), "✗ answer yes or no") {
+		t.Errorf("output:\n%s", out)
+	}
+
+	p, _ = scripted("")
+	if _, err := p.confirm("Enable auth", "", true); err == nil || !strings.Contains(err.Error(), "stdin was closed") {
+		t.Errorf("EOF: err = %v", err)
+	}
+}
+
+func TestAskVariables(t *testing.T) {
+	one := 1
+	vs := []variable{
+		{Name: "Port", Type: typeInt, Min: &one, Required: true},
+		{Name: "Owner", Required: true},
+		{Name: "DB", Enum: []any{"sqlite", "postgres"}, Default: "sqlite"},
+		{Name: "Debug", Type: typeBool},
+		{Name: "Token", Secret: true, Pattern: "^tok_"},
+	}
+	// Port: not a number, below the minimum, then valid. Owner: empty
+	// while required, then set.
+	answers := "abc\n0\n8080\n\nme\n"
+	t.Run("required only", func(t *testing.T) {
+		p, out := scripted(answers)
+		vars := map[string]any{}
+		if err := resolveVariables(vs, vars, p.askVariables(false)); err != nil {
+			t.Fatal(err)
+		}
+		want := map[string]any{"Port": 8080, "Owner": "me", "DB": "sqlite"}
+		if !reflect.DeepEqual(vars, want) {
+			t.Errorf("vars = %v, want %v", vars, want)
+		}
+		for _, w := range []string{`✗ "abc" is not an integer`, "✗ 0 is less than the minimum 1", "✗ a value is required"} {
+			if !strings.Contains(out.String(), w) {
+				t.Errorf("output lacks %q:\n%s", w, out)
+			}
+		}
+	})
+	t.Run("all", func(t *testing.T) {
+		// DB from the numbered list, Debug, then a Token that does not
+		// match and is not echoed back.
+		p, out := scripted(answers + "2\nyes\nsecret\ntok_1\n")
+		vars := map[string]any{}
+		if err := resolveVariables(vs, vars, p.askVariables(true)); err != nil {
+			t.Fatal(err)
+		}
+		want := map[string]any{"Port": 8080, "Owner": "me", "DB": "postgres", "Debug": true, "Token": "tok_1"}
+		if !reflect.DeepEqual(vars, want) {
+			t.Errorf("vars = %v, want %v", vars, want)
+		}
+		if strings.Contains(out.String(), `"secret"`) || !strings.Contains(out.String(), "✗ the value does not match ^tok_") {
+			t.Errorf("output:\n%s", out)
+		}
+	})
+}
+
+func TestNoInputListsMissing(t *testing.T) {
+	for _, flag := range []*bool{&noInput, &assumeYes} {
+		saved := *flag
+		*flag = true
+		if canPrompt() {
+			t.Error("canPrompt with --no-input or --yes")
+		}
+		*flag = saved
+	}
+	// gen passes no askFunc when it cannot prompt.
+	vs := []variable{{Name: "Owner", Required: true}, {Name: "DB", Default: "sqlite"}, {Name: "DSN", Required: true}}
+	err := resolveVariables(vs, map[string]any{}, nil)
+	if err == nil || !strings.Contains(err.Error(), "missing required variable(s): Owner, DSN (pass them with --set NAME=value or --vars)") {
+		t.Errorf("err = %v", err)
+	}
+}
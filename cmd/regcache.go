@@ -0,0 +1,201 @@
+/*
+ * // Copyright 2025 getDragon-dev
+ * // Licensed under the Apache License, Version 2.0 (the "License");
+ * // you may not use this file except in compliance with the License.
+ * // You may obtain a copy of the License at http://www.apache.org/licenses/LICENSE-2.0
+ * // Unless required by applicable law or agreed to in writing, software
+ * // distributed under the License is distributed on an "AS IS" BASIS,
+ * // WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * // See the License for the specific language governing permissions and
+ * // limitations under the License.
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const defaultCacheTTL = 10 * time.Minute
+
+// registryCacheMeta is stored next to each cached registry body and carries
+// what we need to revalidate it with the origin.
+type registryCacheMeta struct {
+	URL          string    `json:"url"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	FetchedAt    time.Time `json:"fetched_at"`
+}
+
+func registryCacheDir() string { return filepath.Join(cacheDir(), "registries") }
+
+func registryCacheFiles(loc string) (string, string) {
+	sum := sha256.Sum256([]byte(loc))
+	key := hex.EncodeToString(sum[:])
+	return filepath.Join(registryCacheDir(), key+".json"), filepath.Join(registryCacheDir(), key+".meta.json")
+}
+
+func readRegistryCache(loc string) ([]byte, registryCacheMeta, error) {
+	bodyPath, metaPath := registryCacheFiles(loc)
+	var meta registryCacheMeta
+	mb, err := os.ReadFile(metaPath)
+	if err != nil {
+		return nil, meta, err
+	}
+	if err := json.Unmarshal(mb, &meta); err != nil {
+		return nil, meta, err
+	}
+	body, err := os.ReadFile(bodyPath)
+	if err != nil {
+		return nil, meta, err
+	}
+	return body, meta, nil
+}
+
+func writeRegistryCache(loc string, body []byte, meta registryCacheMeta) error {
+	if err := privateDir(registryCacheDir()); err != nil {
+		return err
+	}
+	bodyPath, metaPath := registryCacheFiles(loc)
+	mb, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(bodyPath, body, 0o600); err != nil {
+		return err
+	}
+	return writeFileAtomic(metaPath, mb, 0o600)
+}
+
+// privateDir creates dir, or tightens an existing one, so that only the user
+// can read it. Whatever is fetched from a private registry, its index or its
+// bundles, is as sensitive as the credentials that fetched it, so the cache
+// directories holding such data are made with this, like credentials.json is
+// written 0600.
+func privateDir(dir string) error {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	// MkdirAll leaves an existing directory's mode alone.
+	return os.Chmod(dir, 0o700)
+}
+
+// writeFileAtomic writes through a temp file and a rename so concurrent
+// readers never observe a half-written file.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Chmod(tmp, perm); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func cacheTTL() time.Duration {
+	if refresh {
+		return 0
+	}
+	cfg, _, err := effectiveConfig()
+	if err != nil || cfg.CacheTTL == "" {
+		return defaultCacheTTL
+	}
+	d, err := time.ParseDuration(cfg.CacheTTL)
+	if err != nil {
+		return defaultCacheTTL
+	}
+	return d
+}
+
+// fetchRegistry returns the raw registry index at loc, going through the
+// on-disk cache. Entries younger than maxAge are served without a request,
+// older ones are revalidated with If-None-Match/If-Modified-Since, and a
+// cached copy is used as a fallback when the origin cannot be reached.
+func fetchRegistry(ctx context.Context, loc string, maxAge time.Duration) ([]byte, error) {
+	cached, meta, cacheErr := readRegistryCache(loc)
+	if offline {
+		if cacheErr != nil {
+			return nil, fmt.Errorf("registry %s is not cached (run once without --offline)", loc)
+		}
+		return cached, nil
+	}
+	if cacheErr == nil && time.Since(meta.FetchedAt) < maxAge {
+		return cached, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, loc, nil)
+	if err != nil {
+		return nil, err
+	}
+	applyAuth(req)
+	if cacheErr == nil {
+		if meta.ETag != "" {
+			req.Header.Set("If-None-Match", meta.ETag)
+		}
+		if meta.LastModified != "" {
+			req.Header.Set("If-Modified-Since", meta.LastModified)
+		}
+	}
+	resp, err := doHTTP(req)
+	if err != nil {
+		if cacheErr == nil {
+			fmt.Fprintf(os.Stderr, "warning: %v; using cached copy from %s\n", err, meta.FetchedAt.Format(time.RFC3339))
+			return cached, nil
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && cacheErr == nil {
+		meta.FetchedAt = time.Now()
+		_ = writeRegistryCache(loc, cached, meta)
+		return cached, nil
+	}
+	if resp.StatusCode/100 == 5 && cacheErr == nil {
+		fmt.Fprintf(os.Stderr, "warning: GET %s: %d; using cached copy from %s\n", loc, resp.StatusCode, meta.FetchedAt.Format(time.RFC3339))
+		return cached, nil
+	}
+	if resp.StatusCode/100 != 2 {
+		b, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("GET %s: %d: %s", loc, resp.StatusCode, string(b))
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if cacheErr == nil && !bytes.Equal(data, cached) {
+		snapshotBefore(loc, cached, meta.FetchedAt)
+	}
+	meta = registryCacheMeta{
+		URL:          loc,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		FetchedAt:    time.Now(),
+	}
+	if err := writeRegistryCache(loc, data, meta); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: cache registry %s: %v\n", loc, err)
+	}
+	return data, nil
+}
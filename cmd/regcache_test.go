@@ -0,0 +1,77 @@
+/*
+ * // Copyright 2025 getDragon-dev
+ * // Licensed under the Apache License, Version 2.0 (the "License");
+ * // you may not use this file except in compliance with the License.
+ * // You may obtain a copy of the License at http://www.apache.org/licenses/LICENSE-2.0
+ * // Unless required by applicable law or agreed to in writing, software
+ * // distributed under the License is distributed on an "AS IS" BASIS,
+ * // WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * // See the License for the specific language governing permissions and
+ * // limitations under the License.
+ */
+
+package cmd
+
+import (
+	"context"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestFetchRegistryCache(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("XDG_CACHE_HOME", tmp)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(tmp, "config"))
+	// An existing, too open cache directory gets tightened.
+	if err := os.MkdirAll(registryCacheDir(), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	var hits, notModified int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		_, _ = w.Write([]byte(`{"blueprints":[]}`))
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	for i, maxAge := range []time.Duration{time.Hour, time.Hour, 0} {
+		data, err := fetchRegistry(ctx, srv.URL+"/registry.json", maxAge)
+		if err != nil || string(data) != `{"blueprints":[]}` {
+			t.Fatalf("fetch %d: %q, %v", i, data, err)
+		}
+	}
+	if hits != 2 || notModified != 1 {
+		t.Errorf("hits = %d, 304s = %d; want a cache hit, then one revalidation", hits, notModified)
+	}
+
+	if runtime.GOOS == "windows" {
+		return
+	}
+	check := func(p string, want fs.FileMode) {
+		t.Helper()
+		fi, err := os.Stat(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fi.Mode().Perm() != want {
+			t.Errorf("%s: mode %v, want %v", p, fi.Mode().Perm(), want)
+		}
+	}
+	check(registryCacheDir(), 0o700)
+	body, meta := registryCacheFiles(srv.URL + "/registry.json")
+	check(body, 0o600)
+	check(meta, 0o600)
+}
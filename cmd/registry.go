@@ -16,6 +16,8 @@ import (
 	"errors"
 	"fmt"
 	"net/url"
+	"os"
+	"path"
 	"path/filepath"
 	"strings"
 
@@ -25,17 +27,22 @@ import (
 var registryCmd = &cobra.Command{Use: "registry", Short: "Manage registries"}
 
 var registryListCmd = &cobra.Command{Use: "list", RunE: func(cmd *cobra.Command, args []string) error {
-	cfg, err := readConfig()
+	cfg, src, err := effectiveConfig()
 	if err != nil {
 		return err
 	}
+	project := findProjectConfig()
 	fmt.Println("Registries:")
 	for _, r := range cfg.Registries {
 		mark := " "
 		if r.Name == cfg.Default {
 			mark = "*"
 		}
-		fmt.Printf("%s %s -> %s\n", mark, r.Name, r.URL)
+		from := ""
+		if project != "" && src["registries."+r.Name] == project {
+			from = " (" + projectConfigName + ")"
+		}
+		fmt.Printf("%s %s -> %s%s\n", mark, r.Name, r.URL, from)
 	}
 	if len(cfg.Order) > 0 {
 		fmt.Printf("Order: %s\n", strings.Join(cfg.Order, ", "))
@@ -44,25 +51,54 @@ var registryListCmd = &cobra.Command{Use: "list", RunE: func(cmd *cobra.Command,
 }}
 
 var (
-	regName string
-	regURL  string
+	regName        string
+	regURL         string
+	regAuthType    string
+	regTokenEnv    string
+	regUsername    string
+	regPasswordEnv string
+	regPublicKeys  []string
+	regCheck       bool
 )
 
 var registryAddCmd = &cobra.Command{Use: "add", RunE: func(cmd *cobra.Command, args []string) error {
 	if regName == "" || regURL == "" {
 		return errors.New("--name and --url required")
 	}
-	cfg, _ := readConfig()
+	var auth *RegistryAuth
+	if regAuthType != "" || regTokenEnv != "" || regUsername != "" || regPasswordEnv != "" {
+		switch regAuthType {
+		case "", "bearer", "basic", "netrc":
+		default:
+			return fmt.Errorf("unknown --auth %q (want bearer, basic or netrc)", regAuthType)
+		}
+		auth = &RegistryAuth{Type: regAuthType, TokenEnv: regTokenEnv, Username: regUsername, PasswordEnv: regPasswordEnv}
+	}
+	if regCheck {
+		if err := checkRegistry(Registry{Name: regName, URL: regURL, Auth: auth, PublicKeys: regPublicKeys}); err != nil {
+			return err
+		}
+	}
+	cfg, err := readConfig()
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
 	found := false
 	for i := range cfg.Registries {
 		if cfg.Registries[i].Name == regName {
 			cfg.Registries[i].URL = regURL
+			if auth != nil {
+				cfg.Registries[i].Auth = auth
+			}
+			if len(regPublicKeys) > 0 {
+				cfg.Registries[i].PublicKeys = regPublicKeys
+			}
 			found = true
 			break
 		}
 	}
 	if !found {
-		cfg.Registries = append(cfg.Registries, Registry{Name: regName, URL: regURL})
+		cfg.Registries = append(cfg.Registries, Registry{Name: regName, URL: regURL, Auth: auth, PublicKeys: regPublicKeys})
 	}
 	if cfg.Default == "" {
 		cfg.Default = regName
@@ -96,6 +132,12 @@ var registryRemoveCmd = &cobra.Command{Use: "remove <name>", Args: cobra.ExactAr
 		}
 	}
 	cfg.Order = ord
+	if creds, err := readCredentials(); err == nil {
+		if _, ok := creds[name]; ok {
+			delete(creds, name)
+			_ = writeCredentials(creds)
+		}
+	}
 	return writeConfig(cfg)
 }}
 
@@ -129,7 +171,22 @@ var registryDefaultCmd = &cobra.Command{Use: "set-default <name>", Args: cobra.E
 var registryUseCmd = &cobra.Command{Use: "use <url-or-path>", Args: cobra.ExactArgs(1), RunE: func(cmd *cobra.Command, args []string) error {
 	target := args[0]
 	name := autoName(target)
-	cfg, _ := readConfig()
+	cfg, err := readConfig()
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if regCheck {
+		r := Registry{Name: name, URL: target}
+		for _, existing := range cfg.Registries {
+			if existing.Name == name {
+				r = existing
+				r.URL = target
+			}
+		}
+		if err := checkRegistry(r); err != nil {
+			return err
+		}
+	}
 	found := false
 	for i := range cfg.Registries {
 		if cfg.Registries[i].Name == name {
@@ -192,6 +249,22 @@ var registryOrderSetCmd = &cobra.Command{Use: "order set", RunE: func(cmd *cobra
 }}
 
 func autoName(u string) string {
+	if isGit(u) {
+		if g, err := parseGitSource(u); err == nil {
+			if pu, err := url.Parse(g.Repo); err == nil && pu.Host != "" {
+				return pu.Host
+			}
+			return strings.TrimSuffix(path.Base(g.Repo), ".git")
+		}
+	}
+	if isOCI(u) {
+		if ref, err := parseOCIRef(u); err == nil {
+			if ref.Layout {
+				return filepath.Base(ref.Repo)
+			}
+			return ref.Host
+		}
+	}
 	if strings.HasPrefix(u, "http://") || strings.HasPrefix(u, "https://") {
 		if pu, err := url.Parse(u); err == nil && pu.Host != "" {
 			return pu.Host
@@ -207,6 +280,13 @@ func autoName(u string) string {
 func init() {
 	registryAddCmd.Flags().StringVar(&regName, "name", "", "Registry name")
 	registryAddCmd.Flags().StringVar(&regURL, "url", "", "Registry URL or local path")
+	registryAddCmd.Flags().StringVar(&regAuthType, "auth", "", "Auth scheme: bearer|basic|netrc")
+	registryAddCmd.Flags().StringVar(&regTokenEnv, "token-env", "", "Environment variable holding a bearer token")
+	registryAddCmd.Flags().StringVar(&regUsername, "username", "", "Username for basic auth")
+	registryAddCmd.Flags().StringVar(&regPasswordEnv, "password-env", "", "Environment variable holding the basic auth password")
+	registryAddCmd.Flags().StringSliceVar(&regPublicKeys, "public-key", nil, "Trusted base64 ed25519 key for the index signature, repeatable")
+	registryAddCmd.Flags().BoolVar(&regCheck, "check", false, "Probe the registry and refuse to save it if it is unusable")
+	registryUseCmd.Flags().BoolVar(&regCheck, "check", false, "Probe the registry and refuse to switch to it if it is unusable")
 	registryOrderSetCmd.Flags().StringVar(&orderSetInput, "names", "", "Comma-separated registry names in desired order")
 	registryCmd.AddCommand(registryListCmd, registryAddCmd, registryRemoveCmd, registryDefaultCmd, registryUseCmd, registryOrderSetCmd)
 	rootCmd.AddCommand(registryCmd)
@@ -13,17 +13,31 @@
 package cmd
 
 import (
-	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
+// Version is the CLI version, set by main from its -ldflags value.
+var Version = "dev"
+
 var (
 	registryPath string
+	offline      bool
+	refresh      bool
+	strict       bool
+	quiet        bool
+	noInput      bool
+	assumeYes    bool
+	fetchTimeout time.Duration
 )
 
 var rootCmd = &cobra.Command{
@@ -33,25 +47,112 @@ var rootCmd = &cobra.Command{
 
 func init() {
 	rootCmd.PersistentFlags().StringVar(&registryPath, "registry", "", "Path or URL to registry.json (overrides configured registries)")
+	rootCmd.PersistentFlags().BoolVar(&offline, "offline", false, "Serve registries from the local cache only; never touch the network")
+	rootCmd.PersistentFlags().BoolVar(&refresh, "refresh", false, "Revalidate cached registries even if they are still fresh")
+	rootCmd.PersistentFlags().BoolVar(&strict, "strict", false, "Fail when any configured registry cannot be loaded")
+	rootCmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "Suppress progress output")
+	rootCmd.PersistentFlags().BoolVar(&noInput, "no-input", false, "Never prompt; fail when required input is missing")
+	rootCmd.PersistentFlags().BoolVarP(&assumeYes, "yes", "y", false, "Accept defaults instead of prompting")
+	rootCmd.PersistentFlags().DurationVar(&fetchTimeout, "registry-timeout", 15*time.Second, "Timeout for fetching a single registry")
 	rootCmd.CompletionOptions.DisableDefaultCmd = true
+	cobra.OnInitialize(applyEnvFlags)
+}
+
+// applyEnvFlags fills every global flag left unset on the command line from
+// its DRAGON_* environment variable: --registry from DRAGON_REGISTRY,
+// --registry-timeout from DRAGON_REGISTRY_TIMEOUT and so on.
+func applyEnvFlags() {
+	rootCmd.PersistentFlags().VisitAll(func(f *pflag.Flag) {
+		if f.Changed {
+			return
+		}
+		if v, ok := os.LookupEnv(envName(f.Name)); ok {
+			if err := f.Value.Set(v); err != nil {
+				fmt.Fprintf(os.Stderr, "warning: ignoring %s=%q: %v\n", envName(f.Name), v, err)
+			}
+		}
+	})
+}
+
+func envName(key string) string {
+	return "DRAGON_" + strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(key))
 }
 
 func Execute() {
 	ensureConfigDefaults()
-	if err := rootCmd.Execute(); err != nil {
+	cleanupOnSignal()
+	err := rootCmd.Execute()
+	cleanupTemp()
+	if errors.Is(err, errInterrupted) {
+		os.Exit(130)
+	}
+	if err != nil {
 		log.Fatal(err)
 	}
 }
 
 type Config struct {
-	Registries []Registry `json:"registries"`
-	Default    string     `json:"default"`
-	Order      []string   `json:"order,omitempty"`
+	// Version is the config schema version; older files are migrated when
+	// they are read and rewritten on the next write.
+	Version    int        `json:"version" yaml:"version"`
+	Registries []Registry `json:"registries" yaml:"registries"`
+	Default    string     `json:"default" yaml:"default"`
+	Order      []string   `json:"order,omitempty" yaml:"order,omitempty"`
+	CacheTTL   string     `json:"cache_ttl,omitempty" yaml:"cache_ttl,omitempty"`
+	// MaxBundleSize caps bundle downloads and MaxUnpackedSize what one bundle
+	// may extract to, e.g. "256MB"; see parseByteSize.
+	MaxBundleSize   string `json:"max_bundle_size,omitempty" yaml:"max_bundle_size,omitempty"`
+	MaxUnpackedSize string `json:"max_unpacked_size,omitempty" yaml:"max_unpacked_size,omitempty"`
+	// BlueprintPaths are directories searched for local blueprint sources
+	// when gen runs without --remote; relative entries are taken from the
+	// directory of the file that lists them.
+	BlueprintPaths []string `json:"blueprint_paths,omitempty" yaml:"blueprint_paths,omitempty"`
+	// Vars are default template variables; --vars and --set override them.
+	Vars map[string]any `json:"vars,omitempty" yaml:"vars,omitempty"`
+	// Pins map a blueprint name to the version constraint gen and get use
+	// when the reference doesn't carry one.
+	Pins map[string]string `json:"pins,omitempty" yaml:"pins,omitempty"`
+	// HTTP configures the client used for all network access.
+	HTTP *HTTPConfig `json:"http,omitempty" yaml:"http,omitempty"`
+}
+
+// HTTPConfig tunes the shared HTTP client. Proxies come from HTTPS_PROXY,
+// HTTP_PROXY and NO_PROXY; relative paths are taken from the config
+// directory. Only the user config's section is used, so a checked-out
+// .dragon.yaml cannot change which servers are trusted.
+type HTTPConfig struct {
+	// CAFile is a PEM bundle trusted in addition to the system roots.
+	CAFile string `json:"ca_file,omitempty" yaml:"ca_file,omitempty"`
+	// ClientCert and ClientKey are a PEM certificate and key presented to
+	// servers that require mutual TLS.
+	ClientCert string `json:"client_cert,omitempty" yaml:"client_cert,omitempty"`
+	ClientKey  string `json:"client_key,omitempty" yaml:"client_key,omitempty"`
+	// Timeout bounds connecting and waiting for response headers on each
+	// attempt; it does not cut off long downloads.
+	Timeout string `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+	// Retries is how often failed idempotent requests are retried; unset
+	// means 3.
+	Retries *int `json:"retries,omitempty" yaml:"retries,omitempty"`
 }
 
 type Registry struct {
-	Name string `json:"name"`
-	URL  string `json:"url"`
+	Name    string        `json:"name" yaml:"name"`
+	URL     string        `json:"url" yaml:"url"`
+	Timeout string        `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+	Auth    *RegistryAuth `json:"auth,omitempty" yaml:"auth,omitempty"`
+	// PublicKeys are base64 ed25519 keys; when set, the index must carry a
+	// valid detached signature from one of them.
+	PublicKeys []string `json:"public_keys,omitempty" yaml:"public_keys,omitempty"`
+}
+
+// RegistryAuth describes how to authenticate against a registry without
+// storing the secret itself in config.json; secrets come from the
+// environment, ~/.netrc, or `dragon login`.
+type RegistryAuth struct {
+	Type        string `json:"type,omitempty" yaml:"type,omitempty"` // bearer, basic or netrc
+	TokenEnv    string `json:"token_env,omitempty" yaml:"token_env,omitempty"`
+	Username    string `json:"username,omitempty" yaml:"username,omitempty"`
+	PasswordEnv string `json:"password_env,omitempty" yaml:"password_env,omitempty"`
 }
 
 func configDir() string {
@@ -66,28 +167,69 @@ func configDir() string {
 	return filepath.Join(os.Getenv("HOME"), ".config", "dragon")
 }
 
-func configPath() string { return filepath.Join(configDir(), "config.json") }
+func cacheDir() string {
+	if x := os.Getenv("XDG_CACHE_HOME"); x != "" {
+		return filepath.Join(x, "dragon")
+	}
+	if runtime.GOOS == "windows" {
+		if app := os.Getenv("LocalAppData"); app != "" {
+			return filepath.Join(app, "dragon", "cache")
+		}
+	}
+	return filepath.Join(os.Getenv("HOME"), ".cache", "dragon")
+}
 
+// configPath is $DRAGON_CONFIG when set. Otherwise it is config.yaml (or
+// config.yml) in the config directory if one exists, and config.json there
+// if not.
+func configPath() string {
+	if p := os.Getenv("DRAGON_CONFIG"); p != "" {
+		return p
+	}
+	for _, name := range []string{"config.yaml", "config.yml"} {
+		p := filepath.Join(configDir(), name)
+		if _, err := os.Stat(p); err == nil {
+			return p
+		}
+	}
+	return filepath.Join(configDir(), "config.json")
+}
+
+// readConfig loads the user config. Files written by older versions of
+// dragon are migrated in memory only; the file itself is upgraded the next
+// time a command writes the config.
 func readConfig() (Config, error) {
-	p := configPath()
-	b, err := os.ReadFile(p)
+	cfg, _, err := loadConfigFile(configPath())
 	if err != nil {
 		return Config{}, err
 	}
-	var cfg Config
-	if err := json.Unmarshal(b, &cfg); err != nil {
-		return Config{}, err
-	}
 	return cfg, nil
 }
 
+// writeConfig saves cfg at the current schema version. An older file being
+// replaced is kept next to it as <config>.v<N>.bak the first time.
 func writeConfig(cfg Config) error {
-	_ = os.MkdirAll(configDir(), 0o755)
-	b, err := json.MarshalIndent(cfg, "", "  ")
+	p := configPath()
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+	cfg.Version = currentConfigVersion
+	b, err := encodeConfig(p, cfg)
+	if err != nil {
+		return err
+	}
+	backup, from, err := backupOldConfig(p)
 	if err != nil {
+		return fmt.Errorf("back up %s before migrating it: %w", p, err)
+	}
+	if err := writeFileAtomic(p, b, 0o644); err != nil {
 		return err
 	}
-	return os.WriteFile(configPath(), b, 0o644)
+	resetEffectiveConfig()
+	if backup != "" {
+		fmt.Fprintf(os.Stderr, "Migrated %s from config version %d to %d (backup in %s)\n", p, from, currentConfigVersion, backup)
+	}
+	return nil
 }
 
 func ensureConfigDefaults() {
@@ -95,7 +237,6 @@ func ensureConfigDefaults() {
 	if _, err := os.Stat(p); err == nil {
 		return
 	}
-	_ = os.MkdirAll(configDir(), 0o755)
 	cfg := Config{
 		Registries: []Registry{{Name: "public", URL: "https://getdragon.dev/registry.json"}},
 		Default:    "public",
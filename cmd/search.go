@@ -22,6 +22,7 @@ import (
 var searchAll bool
 var searchQuery string
 var searchTag string
+var searchIncludeYanked bool
 
 var searchCmd = &cobra.Command{Use: "search", Short: "Search blueprints by name/description/tags",
 	RunE: func(cmd *cobra.Command, args []string) error {
@@ -41,6 +42,9 @@ var searchCmd = &cobra.Command{Use: "search", Short: "Search blueprints by name/
 			for _, s := range loaded {
 				names := []string{}
 				for _, bp := range s.DB.Blueprints {
+					if _, ok := s.listed(bp, searchIncludeYanked); !ok {
+						continue
+					}
 					if match(bp.Name, bp.Description, bp.Tags, q, searchTag) {
 						names = append(names, bp.Name)
 					}
@@ -51,13 +55,15 @@ var searchCmd = &cobra.Command{Use: "search", Short: "Search blueprints by name/
 				}{s.URL, names})
 			}
 		} else {
-			db, err := loadRegistry()
+			set, err := loadDefaultSet()
 			if err != nil {
 				return err
 			}
-			src, _ := resolveRegistry()
 			names := []string{}
-			for _, bp := range db.Blueprints {
+			for _, bp := range set.DB.Blueprints {
+				if _, ok := set.listed(bp, searchIncludeYanked); !ok {
+					continue
+				}
 				if match(bp.Name, bp.Description, bp.Tags, q, searchTag) {
 					names = append(names, bp.Name)
 				}
@@ -65,7 +71,7 @@ var searchCmd = &cobra.Command{Use: "search", Short: "Search blueprints by name/
 			sets = append(sets, struct {
 				URL   string
 				Names []string
-			}{src, names})
+			}{set.URL, names})
 		}
 		for _, s := range sets {
 			fmt.Println(s.URL)
@@ -112,5 +118,6 @@ func init() {
 	searchCmd.Flags().BoolVar(&searchAll, "all", true, "search across all registries by default")
 	searchCmd.Flags().StringVar(&searchQuery, "query", "", "substring to search for")
 	searchCmd.Flags().StringVar(&searchTag, "tag", "", "filter by exact tag")
+	searchCmd.Flags().BoolVar(&searchIncludeYanked, "include-yanked", false, "include blueprints whose releases have all been yanked")
 	rootCmd.AddCommand(searchCmd)
 }
@@ -0,0 +1,434 @@
+/*
+ * // Copyright 2025 getDragon-dev
+ * // Licensed under the Apache License, Version 2.0 (the "License");
+ * // you may not use this file except in compliance with the License.
+ * // You may obtain a copy of the License at http://www.apache.org/licenses/LICENSE-2.0
+ * // Unless required by applicable law or agreed to in writing, software
+ * // distributed under the License is distributed on an "AS IS" BASIS,
+ * // WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * // See the License for the specific language governing permissions and
+ * // limitations under the License.
+ */
+
+package cmd
+
+import (
+	"cmp"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// semver is a SemVer 2.0.0 version. Build metadata is kept for display but
+// never takes part in precedence.
+type semver struct {
+	Major, Minor, Patch uint64
+	Pre                 []string
+	Build               string
+}
+
+func (v semver) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if len(v.Pre) > 0 {
+		s += "-" + strings.Join(v.Pre, ".")
+	}
+	if v.Build != "" {
+		s += "+" + v.Build
+	}
+	return s
+}
+
+// parseVersion parses a full MAJOR.MINOR.PATCH[-pre][+build] version. A
+// leading "v" is accepted since that is how tags are usually written.
+func parseVersion(s string) (semver, error) {
+	in := s
+	s = strings.TrimPrefix(strings.TrimSpace(s), "v")
+	var v semver
+	if i := strings.IndexByte(s, '+'); i >= 0 {
+		v.Build = s[i+1:]
+		s = s[:i]
+		if err := checkIdents(v.Build, false); err != nil {
+			return semver{}, fmt.Errorf("invalid version %q: build metadata: %w", in, err)
+		}
+	}
+	if i := strings.IndexByte(s, '-'); i >= 0 {
+		pre := s[i+1:]
+		s = s[:i]
+		if err := checkIdents(pre, true); err != nil {
+			return semver{}, fmt.Errorf("invalid version %q: prerelease: %w", in, err)
+		}
+		v.Pre = strings.Split(pre, ".")
+	}
+	parts := strings.Split(s, ".")
+	if len(parts) != 3 {
+		return semver{}, fmt.Errorf("invalid version %q: want MAJOR.MINOR.PATCH", in)
+	}
+	nums := [3]uint64{}
+	for i, p := range parts {
+		n, err := parseNumeric(p)
+		if err != nil {
+			return semver{}, fmt.Errorf("invalid version %q: %w", in, err)
+		}
+		nums[i] = n
+	}
+	v.Major, v.Minor, v.Patch = nums[0], nums[1], nums[2]
+	return v, nil
+}
+
+func parseNumeric(p string) (uint64, error) {
+	if p == "" {
+		return 0, fmt.Errorf("empty numeric component")
+	}
+	if len(p) > 1 && p[0] == '0' {
+		return 0, fmt.Errorf("leading zero in %q", p)
+	}
+	n, err := strconv.ParseUint(p, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("bad number %q", p)
+	}
+	return n, nil
+}
+
+func checkIdents(s string, numericNoZero bool) error {
+	for _, id := range strings.Split(s, ".") {
+		if id == "" {
+			return fmt.Errorf("empty identifier")
+		}
+		digits := true
+		for _, r := range id {
+			switch {
+			case r >= '0' && r <= '9':
+			case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '-':
+				digits = false
+			default:
+				return fmt.Errorf("invalid character %q in %q", r, id)
+			}
+		}
+		if numericNoZero && digits && len(id) > 1 && id[0] == '0' {
+			return fmt.Errorf("leading zero in %q", id)
+		}
+	}
+	return nil
+}
+
+// compare orders versions by SemVer precedence.
+func (v semver) compare(o semver) int {
+	if c := cmp.Compare(v.Major, o.Major); c != 0 {
+		return c
+	}
+	if c := cmp.Compare(v.Minor, o.Minor); c != 0 {
+		return c
+	}
+	if c := cmp.Compare(v.Patch, o.Patch); c != 0 {
+		return c
+	}
+	// A version without prerelease ranks above any prerelease of it.
+	switch {
+	case len(v.Pre) == 0 && len(o.Pre) == 0:
+		return 0
+	case len(v.Pre) == 0:
+		return 1
+	case len(o.Pre) == 0:
+		return -1
+	}
+	for i := 0; i < len(v.Pre) && i < len(o.Pre); i++ {
+		if c := comparePreIdent(v.Pre[i], o.Pre[i]); c != 0 {
+			return c
+		}
+	}
+	return cmp.Compare(len(v.Pre), len(o.Pre))
+}
+
+func comparePreIdent(a, b string) int {
+	an, aerr := strconv.ParseUint(a, 10, 64)
+	bn, berr := strconv.ParseUint(b, 10, 64)
+	switch {
+	case aerr == nil && berr == nil:
+		return cmp.Compare(an, bn)
+	case aerr == nil:
+		return -1
+	case berr == nil:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+// compareVersions orders two version strings; anything that fails to parse
+// sorts below every valid version.
+func compareVersions(a, b string) int {
+	va, errA := parseVersion(a)
+	vb, errB := parseVersion(b)
+	switch {
+	case errA != nil && errB != nil:
+		return strings.Compare(a, b)
+	case errA != nil:
+		return -1
+	case errB != nil:
+		return 1
+	}
+	return va.compare(vb)
+}
+
+type comparator struct {
+	op string // one of = < <= > >=
+	v  semver
+}
+
+func (c comparator) match(v semver) bool {
+	r := v.compare(c.v)
+	switch c.op {
+	case "<":
+		return r < 0
+	case "<=":
+		return r <= 0
+	case ">":
+		return r > 0
+	case ">=":
+		return r >= 0
+	}
+	return r == 0
+}
+
+// versionRange is a disjunction of comparator sets, the shape of an npm
+// range: "^1.0 || >=2.1 <2.4".
+type versionRange [][]comparator
+
+// parseRange parses npm/Cargo-style range expressions: comparators
+// (=, <, <=, >, >=) joined by spaces or commas, "||" alternatives, caret and
+// tilde ranges, x-ranges (1.x, 1.2.*, *) and hyphen ranges (1.2 - 1.4). An
+// empty range matches every release.
+func parseRange(s string) (versionRange, error) {
+	var out versionRange
+	for _, alt := range strings.Split(s, "||") {
+		set, err := parseComparatorSet(strings.TrimSpace(alt))
+		if err != nil {
+			return nil, fmt.Errorf("invalid constraint %q: %w", s, err)
+		}
+		out = append(out, set)
+	}
+	return out, nil
+}
+
+func parseComparatorSet(s string) ([]comparator, error) {
+	fields := strings.Fields(strings.ReplaceAll(s, ",", " "))
+	if len(fields) == 0 {
+		return []comparator{{op: ">=", v: semver{}}}, nil
+	}
+	if len(fields) == 3 && fields[1] == "-" {
+		lo, err := parsePartial(fields[0])
+		if err != nil {
+			return nil, err
+		}
+		hi, err := parsePartial(fields[2])
+		if err != nil {
+			return nil, err
+		}
+		set := []comparator{{op: ">=", v: lo.floor()}}
+		if hi.n == 0 {
+			return set, nil
+		}
+		if hi.n < 3 {
+			return append(set, comparator{op: "<", v: hi.bump()}), nil
+		}
+		return append(set, comparator{op: "<=", v: hi.floor()}), nil
+	}
+	// Let "> 1.2" mean ">1.2" by gluing a bare operator to its operand.
+	var toks []string
+	for i := 0; i < len(fields); i++ {
+		f := fields[i]
+		if isOperator(f) && i+1 < len(fields) {
+			f += fields[i+1]
+			i++
+		}
+		toks = append(toks, f)
+	}
+	var set []comparator
+	for _, t := range toks {
+		cs, err := parseSimple(t)
+		if err != nil {
+			return nil, err
+		}
+		set = append(set, cs...)
+	}
+	return set, nil
+}
+
+func isOperator(s string) bool {
+	switch s {
+	case "=", "<", "<=", ">", ">=", "^", "~", "~>":
+		return true
+	}
+	return false
+}
+
+// partial is a possibly incomplete version such as "1", "1.2" or "1.x";
+// n counts the components given.
+type partial struct {
+	v semver
+	n int
+}
+
+func parsePartial(s string) (partial, error) {
+	s = strings.TrimPrefix(s, "v")
+	if s == "" || s == "*" || s == "x" || s == "X" {
+		return partial{}, nil
+	}
+	core, rest := s, ""
+	if i := strings.IndexAny(s, "-+"); i >= 0 {
+		core, rest = s[:i], s[i:]
+	}
+	parts := strings.Split(core, ".")
+	if len(parts) > 3 {
+		return partial{}, fmt.Errorf("bad version %q", s)
+	}
+	var p partial
+	nums := [3]uint64{}
+	for i, c := range parts {
+		if c == "x" || c == "X" || c == "*" {
+			break
+		}
+		n, err := parseNumeric(c)
+		if err != nil {
+			return partial{}, err
+		}
+		nums[i] = n
+		p.n = i + 1
+	}
+	p.v.Major, p.v.Minor, p.v.Patch = nums[0], nums[1], nums[2]
+	if rest != "" {
+		if p.n < 3 {
+			return partial{}, fmt.Errorf("prerelease on incomplete version %q", s)
+		}
+		full, err := parseVersion(s)
+		if err != nil {
+			return partial{}, err
+		}
+		p.v = full
+	}
+	return p, nil
+}
+
+// floor is the lowest version the partial covers.
+func (p partial) floor() semver { return p.v }
+
+// bump is the first version past what the partial covers, as a "-0"
+// prerelease so that prereleases of the next release stay excluded.
+func (p partial) bump() semver {
+	v := semver{Pre: []string{"0"}}
+	switch p.n {
+	case 0:
+		// Nothing lies past "*"; callers never ask.
+	case 1:
+		v.Major = p.v.Major + 1
+	case 2:
+		v.Major, v.Minor = p.v.Major, p.v.Minor+1
+	default:
+		v.Major, v.Minor, v.Patch = p.v.Major, p.v.Minor, p.v.Patch+1
+	}
+	return v
+}
+
+func parseSimple(t string) ([]comparator, error) {
+	op := ""
+	for _, o := range []string{">=", "<=", "~>", ">", "<", "=", "^", "~"} {
+		if strings.HasPrefix(t, o) {
+			op, t = o, strings.TrimSpace(t[len(o):])
+			break
+		}
+	}
+	p, err := parsePartial(t)
+	if err != nil {
+		return nil, err
+	}
+	lo := p.floor()
+	switch op {
+	case "", "=":
+		if p.n == 0 {
+			return []comparator{{">=", semver{}}}, nil
+		}
+		if p.n == 3 {
+			return []comparator{{"=", lo}}, nil
+		}
+		return []comparator{{">=", lo}, {"<", p.bump()}}, nil
+	case ">":
+		if p.n == 0 {
+			// Nothing is greater than every version.
+			return []comparator{{"<", semver{Pre: []string{"0"}}}}, nil
+		}
+		if p.n == 3 {
+			return []comparator{{">", lo}}, nil
+		}
+		// ">1.2" starts at 1.3.0 itself; the "-0" floor of bump would let
+		// 1.3.0-beta through.
+		v := p.bump()
+		v.Pre = nil
+		return []comparator{{">=", v}}, nil
+	case ">=":
+		return []comparator{{">=", lo}}, nil
+	case "<":
+		if p.n == 0 {
+			return []comparator{{"<", semver{Pre: []string{"0"}}}}, nil
+		}
+		return []comparator{{"<", lo}}, nil
+	case "<=":
+		if p.n == 0 {
+			return []comparator{{">=", semver{}}}, nil
+		}
+		if p.n == 3 {
+			return []comparator{{"<=", lo}}, nil
+		}
+		return []comparator{{"<", p.bump()}}, nil
+	case "~", "~>":
+		if p.n == 0 {
+			return []comparator{{">=", semver{}}}, nil
+		}
+		q := p
+		if q.n > 2 {
+			q.n = 2
+		}
+		return []comparator{{">=", lo}, {"<", q.bump()}}, nil
+	case "^":
+		if p.n == 0 {
+			return []comparator{{">=", semver{}}}, nil
+		}
+		// The caret pins the left-most non-zero component that was given.
+		q := partial{v: p.v}
+		switch {
+		case p.v.Major > 0 || p.n == 1:
+			q.n = 1
+		case p.v.Minor > 0 || p.n == 2:
+			q.n = 2
+		default:
+			q.n = 3
+		}
+		return []comparator{{">=", lo}, {"<", q.bump()}}, nil
+	}
+	return nil, fmt.Errorf("bad comparator %q", t)
+}
+
+// match reports whether v is in the range. As with npm, a prerelease only
+// matches a comparator set that names a prerelease of the same
+// MAJOR.MINOR.PATCH, so "^1.0" never picks up 1.5.0-beta.
+func (r versionRange) match(v semver) bool {
+	for _, set := range r {
+		ok := true
+		for _, c := range set {
+			if !c.match(v) {
+				ok = false
+				break
+			}
+		}
+		if !ok {
+			continue
+		}
+		if len(v.Pre) == 0 {
+			return true
+		}
+		for _, c := range set {
+			if len(c.v.Pre) > 0 && c.v.Major == v.Major && c.v.Minor == v.Minor && c.v.Patch == v.Patch {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,97 @@
+/*
+ * // Copyright 2025 getDragon-dev
+ * // Licensed under the Apache License, Version 2.0 (the "License");
+ * // you may not use this file except in compliance with the License.
+ * // You may obtain a copy of the License at http://www.apache.org/licenses/LICENSE-2.0
+ * // Unless required by applicable law or agreed to in writing, software
+ * // distributed under the License is distributed on an "AS IS" BASIS,
+ * // WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * // See the License for the specific language governing permissions and
+ * // limitations under the License.
+ */
+
+package cmd
+
+import "testing"
+
+func TestRangeMatch(t *testing.T) {
+	tests := []struct {
+		rng string
+		yes []string
+		no  []string
+	}{
+		{">1.2", []string{"1.3.0", "1.3.1", "2.0.0"}, []string{"1.2.0", "1.2.9", "1.3.0-beta", "1.3.0-0"}},
+		{">1", []string{"2.0.0", "3.1.0"}, []string{"1.9.9", "2.0.0-rc.1"}},
+		{">1.2.3", []string{"1.2.4", "1.3.0"}, []string{"1.2.3", "1.2.4-beta"}},
+		{">*", nil, []string{"0.0.0", "1.0.0"}},
+		{"<1.2", []string{"1.1.9", "0.1.0"}, []string{"1.2.0", "1.2.0-beta", "1.1.9-rc.1"}},
+		{"<1.2.3", []string{"1.2.2"}, []string{"1.2.3", "1.2.3-beta"}},
+		{"<=1.2", []string{"1.2.9", "1.0.0"}, []string{"1.3.0", "1.3.0-0", "1.3.0-alpha"}},
+		{">=1.2.0 <2", []string{"1.2.0", "1.9.9"}, []string{"1.1.9", "2.0.0", "2.0.0-rc.1"}},
+		{"1.2 - 1.4", []string{"1.2.0", "1.4.9"}, []string{"1.1.9", "1.5.0", "1.5.0-0"}},
+		{"1.2.3 - 1.4.5", []string{"1.2.3", "1.4.5"}, []string{"1.2.2", "1.4.6"}},
+		{"1.2 - *", []string{"1.2.0", "9.0.0"}, []string{"1.1.0"}},
+		{"1.x", []string{"1.0.0", "1.9.9"}, []string{"0.9.9", "2.0.0", "1.5.0-beta"}},
+		{"1.2.*", []string{"1.2.0", "1.2.7"}, []string{"1.3.0", "1.1.9"}},
+		{"*", []string{"0.0.1", "5.0.0"}, []string{"1.0.0-beta"}},
+		{"", []string{"0.0.0", "1.2.3"}, nil},
+		{"^1.2", []string{"1.2.0", "1.9.0"}, []string{"2.0.0", "1.1.0", "1.5.0-beta"}},
+		{"^0.2.3", []string{"0.2.3", "0.2.9"}, []string{"0.3.0"}},
+		{"~1.2.3", []string{"1.2.3", "1.2.9"}, []string{"1.3.0"}},
+		{"~> 1.2", []string{"1.2.0", "1.2.5"}, []string{"1.3.0"}},
+		{"^1.0 || >=2.1 <2.4", []string{"1.5.0", "2.1.0", "2.3.9"}, []string{"2.0.0", "2.4.0", "0.9.0"}},
+		{"<1 || >3", []string{"0.5.0", "4.0.0"}, []string{"1.0.0", "3.9.9", "4.0.0-rc.1"}},
+		{">=1.2.3-beta.2", []string{"1.2.3-beta.2", "1.2.3-beta.10", "1.2.3-rc.1", "1.2.3", "1.3.0"}, []string{"1.2.3-beta.1", "1.2.3-alpha", "1.3.0-beta"}},
+		{"1.2.3-rc.1", []string{"1.2.3-rc.1"}, []string{"1.2.3", "1.2.3-rc.2"}},
+	}
+	for _, tt := range tests {
+		r, err := parseRange(tt.rng)
+		if err != nil {
+			t.Errorf("parseRange(%q): %v", tt.rng, err)
+			continue
+		}
+		for _, s := range tt.yes {
+			if !r.match(mustVersion(t, s)) {
+				t.Errorf("%q should match %s", tt.rng, s)
+			}
+		}
+		for _, s := range tt.no {
+			if r.match(mustVersion(t, s)) {
+				t.Errorf("%q should not match %s", tt.rng, s)
+			}
+		}
+	}
+}
+
+func TestParseRangeErrors(t *testing.T) {
+	for _, s := range []string{"1.2.3.4", ">=a.b", "1.2-beta", "^01.2", "1.2.3 - "} {
+		if _, err := parseRange(s); err == nil {
+			t.Errorf("parseRange(%q): want error", s)
+		}
+	}
+}
+
+func TestComparePrerelease(t *testing.T) {
+	// Ascending, from the SemVer 2.0.0 spec, section 11.
+	order := []string{
+		"1.0.0-alpha", "1.0.0-alpha.1", "1.0.0-alpha.beta", "1.0.0-beta",
+		"1.0.0-beta.2", "1.0.0-beta.11", "1.0.0-rc.1", "1.0.0",
+	}
+	for i := 0; i+1 < len(order); i++ {
+		if c := compareVersions(order[i], order[i+1]); c >= 0 {
+			t.Errorf("compareVersions(%s, %s) = %d, want < 0", order[i], order[i+1], c)
+		}
+	}
+	if c := compareVersions("1.0.0+build.1", "1.0.0+build.2"); c != 0 {
+		t.Errorf("build metadata must not affect order, got %d", c)
+	}
+}
+
+func mustVersion(t *testing.T, s string) semver {
+	t.Helper()
+	v, err := parseVersion(s)
+	if err != nil {
+		t.Fatalf("parseVersion(%q): %v", s, err)
+	}
+	return v
+}
@@ -0,0 +1,339 @@
+/*
+ * // Copyright 2025 getDragon-dev
+ * // Licensed under the Apache License, Version 2.0 (the "License");
+ * // you may not use this file except in compliance with the License.
+ * // You may obtain a copy of the License at http://www.apache.org/licenses/LICENSE-2.0
+ * // Unless required by applicable law or agreed to in writing, software
+ * // distributed under the License is distributed on an "AS IS" BASIS,
+ * // WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * // See the License for the specific language governing permissions and
+ * // limitations under the License.
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"path"
+	"strings"
+	"time"
+
+	corereg "github.com/getDragon-dev/dragon-core/registry"
+	"github.com/spf13/cobra"
+)
+
+var (
+	serveDir     string
+	serveAddr    string
+	serveToken   string
+	serveBaseURL string
+	serveIndex   string
+	serveRewrite bool
+)
+
+var registryServeCmd = &cobra.Command{Use: "serve", Short: "Serve a registry.json and its bundles over HTTP", RunE: func(cmd *cobra.Command, args []string) error {
+	root, err := os.OpenRoot(serveDir)
+	if err != nil {
+		return err
+	}
+	defer root.Close()
+	if _, err := root.Stat(serveIndex); err != nil {
+		return fmt.Errorf("no %s in %s: %w", serveIndex, serveDir, err)
+	}
+	// A rewritten index no longer matches its signature, and clients that
+	// trust the registry's key would reject it; serve signed indexes as is
+	// unless --rewrite is asked for explicitly.
+	if _, err := root.Stat(serveIndex + signatureSuffix); err == nil {
+		if !cmd.Flags().Changed("rewrite") {
+			serveRewrite = false
+		} else if serveRewrite {
+			fmt.Fprintf(os.Stderr, "warning: %s is signed; with --rewrite its signature is not served\n", serveIndex)
+		}
+	}
+	srv := &http.Server{Addr: serveAddr, Handler: registryHandler(root), ReadHeaderTimeout: 10 * time.Second}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdown)
+	}()
+	fmt.Printf("Serving %s on %s\n", serveDir, serveAddr)
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}}
+
+func registryHandler(root *os.Root) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /"+serveIndex, func(w http.ResponseWriter, r *http.Request) {
+		data, err := root.ReadFile(serveIndex)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if serveRewrite {
+			data, err = rewriteDownloadURLs(data, func(u string) string { return localBundleURL(root, r, u) })
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		serveBytes(w, r, "application/json", data)
+	})
+	if !serveRewrite {
+		// A rewritten index no longer matches its signature, so the
+		// signature is only published alongside the original bytes.
+		mux.HandleFunc("GET /"+serveIndex+signatureSuffix, func(w http.ResponseWriter, r *http.Request) {
+			data, err := root.ReadFile(serveIndex + signatureSuffix)
+			if err != nil {
+				http.NotFound(w, r)
+				return
+			}
+			serveBytes(w, r, "text/plain", data)
+		})
+	}
+	mux.HandleFunc("GET /bundles/{file}", func(w http.ResponseWriter, r *http.Request) {
+		// Only bundles the index points at are served, never whatever else
+		// sits in --dir, such as a signing key.
+		name := r.PathValue("file")
+		data, err := root.ReadFile(serveIndex)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if names, err := indexedBundles(data); err != nil || !names[name] {
+			http.NotFound(w, r)
+			return
+		}
+		f, err := root.Open(name)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		defer f.Close()
+		fi, err := f.Stat()
+		if err != nil || fi.IsDir() {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("ETag", fmt.Sprintf(`"%x-%x"`, fi.ModTime().UnixNano(), fi.Size()))
+		http.ServeContent(w, r, name, fi.ModTime(), f)
+	})
+	return requireToken(mux)
+}
+
+func requireToken(next http.Handler) http.Handler {
+	if serveToken == "" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if _, pass, ok := r.BasicAuth(); ok {
+			got = pass
+		}
+		if subtle.ConstantTimeCompare([]byte(got), []byte(serveToken)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="dragon"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// serveBytes writes data with a content ETag, answering conditional requests
+// with 304 and gzip-compressing the body for clients that accept it.
+func serveBytes(w http.ResponseWriter, r *http.Request, contentType string, data []byte) {
+	sum := sha256.Sum256(data)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Vary", "Accept-Encoding")
+	if match := r.Header.Get("If-None-Match"); match != "" && strings.Contains(match, etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+		_, _ = w.Write(data)
+		return
+	}
+	w.Header().Set("Content-Encoding", "gzip")
+	zw := gzip.NewWriter(w)
+	_, _ = zw.Write(data)
+	_ = zw.Close()
+}
+
+// indexedBundles returns the file names the download URLs of every release
+// in the index end in.
+func indexedBundles(data []byte) (map[string]bool, error) {
+	var set registrySet
+	if err := decodeIndex(&set, data); err != nil {
+		return nil, err
+	}
+	names := map[string]bool{}
+	for _, bp := range set.DB.Blueprints {
+		for _, rel := range set.releases(bp) {
+			if u, err := url.Parse(rel.DownloadURL); err == nil && u.Path != "" {
+				names[path.Base(u.Path)] = true
+			}
+		}
+	}
+	return names, nil
+}
+
+// localBundleURL points u at this server when a file with the same name
+// exists in the served directory, and leaves it untouched otherwise.
+func localBundleURL(root *os.Root, r *http.Request, u string) string {
+	pu, err := url.Parse(u)
+	if err != nil {
+		return u
+	}
+	name := path.Base(pu.Path)
+	if name == "." || name == "/" {
+		return u
+	}
+	if fi, err := root.Stat(name); err != nil || fi.IsDir() {
+		return u
+	}
+	base := strings.TrimSuffix(serveBaseURL, "/")
+	if base == "" {
+		scheme := "http"
+		if r.TLS != nil {
+			scheme = "https"
+		}
+		base = scheme + "://" + r.Host
+	}
+	return base + "/bundles/" + url.PathEscape(name)
+}
+
+// rewriteDownloadURLs maps every download URL in a registry index, both the
+// one dragon-core knows about and those in version histories, while leaving
+// all other fields exactly as published.
+func rewriteDownloadURLs(data []byte, rewrite func(string) string) ([]byte, error) {
+	var top map[string]json.RawMessage
+	if err := json.Unmarshal(data, &top); err != nil {
+		return nil, err
+	}
+	raw, ok := top["blueprints"]
+	if !ok {
+		return data, nil
+	}
+	var entries []map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &entries); err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		raw, err := json.Marshal(e)
+		if err != nil {
+			return nil, err
+		}
+		var bp corereg.Blueprint
+		if err := json.Unmarshal(raw, &bp); err != nil {
+			return nil, err
+		}
+		orig := bp
+		bp.DownloadURL = rewrite(bp.DownloadURL)
+		if err := overlayJSON(e, orig, bp); err != nil {
+			return nil, err
+		}
+		if vraw, ok := e["versions"]; ok {
+			var versions []map[string]json.RawMessage
+			if err := json.Unmarshal(vraw, &versions); err != nil {
+				return nil, err
+			}
+			for _, v := range versions {
+				vb, err := json.Marshal(v)
+				if err != nil {
+					return nil, err
+				}
+				var iv indexVersion
+				if err := json.Unmarshal(vb, &iv); err != nil {
+					return nil, err
+				}
+				orig := iv
+				iv.DownloadURL = rewrite(iv.DownloadURL)
+				if err := overlayJSON(v, orig, iv); err != nil {
+					return nil, err
+				}
+			}
+			if e["versions"], err = json.Marshal(versions); err != nil {
+				return nil, err
+			}
+		}
+	}
+	var err error
+	if top["blueprints"], err = json.Marshal(entries); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(top); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// overlayJSON copies the fields that differ between the JSON encodings of
+// before and after into dst, reusing dst's spelling of each key. This lets us
+// edit values owned by another package's struct without knowing its JSON
+// keys and without disturbing anything else in the document.
+func overlayJSON(dst map[string]json.RawMessage, before, after any) error {
+	old, err := jsonFields(before)
+	if err != nil {
+		return err
+	}
+	cur, err := jsonFields(after)
+	if err != nil {
+		return err
+	}
+	for k, v := range cur {
+		if bytes.Equal(old[k], v) {
+			continue
+		}
+		key := k
+		for dk := range dst {
+			if strings.EqualFold(dk, k) {
+				key = dk
+				break
+			}
+		}
+		dst[key] = v
+	}
+	return nil
+}
+
+func jsonFields(v any) (map[string]json.RawMessage, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	return fields, json.Unmarshal(b, &fields)
+}
+
+func init() {
+	registryServeCmd.Flags().StringVar(&serveDir, "dir", ".", "Directory holding the registry index and bundles")
+	registryServeCmd.Flags().StringVar(&serveAddr, "addr", "127.0.0.1:8080", "Address to listen on")
+	registryServeCmd.Flags().StringVar(&serveToken, "token", "", "Require this bearer token (or basic auth password) on every request")
+	registryServeCmd.Flags().StringVar(&serveBaseURL, "base-url", "", "Public URL of this server used in rewritten download URLs (default: from the request)")
+	registryServeCmd.Flags().StringVar(&serveIndex, "index", "registry.json", "Name of the index file inside --dir")
+	registryServeCmd.Flags().BoolVar(&serveRewrite, "rewrite", true, "Point download URLs of bundles found in --dir at this server (off by default for a signed index)")
+	registryCmd.AddCommand(registryServeCmd)
+}
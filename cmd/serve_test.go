@@ -0,0 +1,192 @@
+/*
+ * // Copyright 2025 getDragon-dev
+ * // Licensed under the Apache License, Version 2.0 (the "License");
+ * // you may not use this file except in compliance with the License.
+ * // You may obtain a copy of the License at http://www.apache.org/licenses/LICENSE-2.0
+ * // Unless required by applicable law or agreed to in writing, software
+ * // distributed under the License is distributed on an "AS IS" BASIS,
+ * // WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * // See the License for the specific language governing permissions and
+ * // limitations under the License.
+ */
+
+package cmd
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testIndex = `{
+  "blueprints": [
+    {
+      "name": "api",
+      "version": "1.1.0",
+      "download_url": "https://cdn.example/api-1.1.0.zip",
+      "custom": {"kept": true},
+      "versions": [
+        {"version": "1.0.0", "download_url": "https://cdn.example/api-1.0.0.zip"},
+        {"version": "0.9.0", "download_url": "https://cdn.example/gone.zip"}
+      ]
+    }
+  ]
+}
+`
+
+// serveTestRegistry serves a directory with an index, a signature and two
+// bundles; set configures the serve flags for the test.
+func serveTestRegistry(t *testing.T, set func()) *httptest.Server {
+	t.Helper()
+	dir := t.TempDir()
+	files := map[string]string{
+		"registry.json":       testIndex,
+		"registry.json.sig":   "c2ln\n",
+		"api-1.1.0.zip":       "zip 1.1.0",
+		"api-1.0.0.zip":       "zip 1.0.0",
+		"other.zip":           "not listed",
+		"dragon-registry.key": "private",
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	root, err := os.OpenRoot(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { root.Close() })
+
+	index, token, baseURL, rewrite := serveIndex, serveToken, serveBaseURL, serveRewrite
+	t.Cleanup(func() { serveIndex, serveToken, serveBaseURL, serveRewrite = index, token, baseURL, rewrite })
+	serveIndex, serveToken, serveBaseURL, serveRewrite = "registry.json", "", "", false
+	set()
+	srv := httptest.NewServer(registryHandler(root))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func get(t *testing.T, url string, header map[string]string) (*http.Response, string) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	// A transport that leaves Accept-Encoding alone, so gzip is visible.
+	resp, err := (&http.Transport{DisableCompression: true}).RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var body io.Reader = resp.Body
+	if resp.Header.Get("Content-Encoding") == "gzip" {
+		zr, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body = zr
+	}
+	b, err := io.ReadAll(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, string(b)
+}
+
+func TestServeETagAndGzip(t *testing.T) {
+	srv := serveTestRegistry(t, func() {})
+	resp, body := get(t, srv.URL+"/registry.json", nil)
+	if resp.StatusCode != http.StatusOK || body != testIndex {
+		t.Fatalf("got %d %q", resp.StatusCode, body)
+	}
+	etag := resp.Header.Get("ETag")
+	if etag == "" {
+		t.Fatal("no ETag")
+	}
+	if resp, _ := get(t, srv.URL+"/registry.json", map[string]string{"If-None-Match": etag}); resp.StatusCode != http.StatusNotModified {
+		t.Errorf("If-None-Match: got %d, want 304", resp.StatusCode)
+	}
+	resp, body = get(t, srv.URL+"/registry.json", map[string]string{"Accept-Encoding": "gzip"})
+	if resp.Header.Get("Content-Encoding") != "gzip" || body != testIndex {
+		t.Errorf("gzip: encoding %q, body %q", resp.Header.Get("Content-Encoding"), body)
+	}
+	if resp.Header.Get("ETag") != etag {
+		t.Errorf("gzip ETag %q differs from %q", resp.Header.Get("ETag"), etag)
+	}
+	if resp, body := get(t, srv.URL+"/registry.json.sig", nil); resp.StatusCode != http.StatusOK || body != "c2ln\n" {
+		t.Errorf("signature: got %d %q", resp.StatusCode, body)
+	}
+	if resp, body := get(t, srv.URL+"/bundles/api-1.0.0.zip", nil); resp.StatusCode != http.StatusOK || body != "zip 1.0.0" {
+		t.Errorf("bundle: got %d %q", resp.StatusCode, body)
+	}
+	for _, p := range []string{"..%2fregistry.json", "registry.json", "dragon-registry.key", "other.zip"} {
+		if resp, _ := get(t, srv.URL+"/bundles/"+p, nil); resp.StatusCode != http.StatusNotFound {
+			t.Errorf("/bundles/%s: got %d, want 404", p, resp.StatusCode)
+		}
+	}
+}
+
+func TestServeToken(t *testing.T) {
+	srv := serveTestRegistry(t, func() { serveToken = "s3cret" })
+	tests := []struct {
+		header map[string]string
+		want   int
+	}{
+		{nil, http.StatusUnauthorized},
+		{map[string]string{"Authorization": "Bearer wrong"}, http.StatusUnauthorized},
+		{map[string]string{"Authorization": "Bearer s3cret"}, http.StatusOK},
+		// Basic auth with the token as password, as netrc would send it.
+		{map[string]string{"Authorization": "Basic dXNlcjpzM2NyZXQ="}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		if resp, _ := get(t, srv.URL+"/registry.json", tt.header); resp.StatusCode != tt.want {
+			t.Errorf("%v: got %d, want %d", tt.header, resp.StatusCode, tt.want)
+		}
+	}
+}
+
+func TestServeRewrite(t *testing.T) {
+	srv := serveTestRegistry(t, func() { serveRewrite = true })
+	_, body := get(t, srv.URL+"/registry.json", nil)
+	var idx struct {
+		Blueprints []struct {
+			DownloadURL string          `json:"download_url"`
+			Custom      map[string]bool `json:"custom"`
+			Versions    []indexVersion  `json:"versions"`
+		} `json:"blueprints"`
+	}
+	if err := json.Unmarshal([]byte(body), &idx); err != nil {
+		t.Fatal(err)
+	}
+	bp := idx.Blueprints[0]
+	if want := srv.URL + "/bundles/api-1.1.0.zip"; bp.DownloadURL != want {
+		t.Errorf("download_url = %q, want %q", bp.DownloadURL, want)
+	}
+	if want := srv.URL + "/bundles/api-1.0.0.zip"; bp.Versions[0].DownloadURL != want {
+		t.Errorf("versions[0] = %q, want %q", bp.Versions[0].DownloadURL, want)
+	}
+	if bp.Versions[1].DownloadURL != "https://cdn.example/gone.zip" {
+		t.Errorf("missing bundle was rewritten to %q", bp.Versions[1].DownloadURL)
+	}
+	if !bp.Custom["kept"] {
+		t.Errorf("unknown field changed to %v", bp.Custom)
+	}
+	if resp, _ := get(t, srv.URL+"/registry.json.sig", nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("signature of a rewritten index: got %d, want 404", resp.StatusCode)
+	}
+
+	srv = serveTestRegistry(t, func() { serveRewrite, serveBaseURL = true, "https://reg.example/" })
+	if _, body := get(t, srv.URL+"/registry.json", nil); !json.Valid([]byte(body)) || !strings.Contains(body, `"https://reg.example/bundles/api-1.1.0.zip"`) {
+		t.Errorf("--base-url not used:\n%s", body)
+	}
+}
@@ -0,0 +1,180 @@
+/*
+ * // Copyright 2025 getDragon-dev
+ * // Licensed under the Apache License, Version 2.0 (the "License");
+ * // you may not use this file except in compliance with the License.
+ * // You may obtain a copy of the License at http://www.apache.org/licenses/LICENSE-2.0
+ * // Unless required by applicable law or agreed to in writing, software
+ * // distributed under the License is distributed on an "AS IS" BASIS,
+ * // WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * // See the License for the specific language governing permissions and
+ * // limitations under the License.
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// A registry index is signed by publishing the base64 ed25519 signature of
+// its exact bytes next to it, at <index>.sig.
+const signatureSuffix = ".sig"
+
+func decodeBase64(s []byte) ([]byte, error) {
+	return base64.StdEncoding.DecodeString(string(bytes.TrimSpace(s)))
+}
+
+func verifyIndex(keys []string, data, sig []byte) error {
+	raw, err := decodeBase64(sig)
+	if err != nil {
+		return fmt.Errorf("malformed signature: %w", err)
+	}
+	for _, k := range keys {
+		pub, err := decodeBase64([]byte(k))
+		if err != nil || len(pub) != ed25519.PublicKeySize {
+			return fmt.Errorf("malformed public key %q", k)
+		}
+		if ed25519.Verify(ed25519.PublicKey(pub), data, raw) {
+			return nil
+		}
+	}
+	return errors.New("signature does not match any trusted key")
+}
+
+// verifyRemoteIndex checks *data against the signature published next to
+// r.URL. The index and its signature are cached independently, so a failed
+// check is retried once against freshly revalidated copies of both. A cached
+// signature older than maxAge is revalidated first.
+func verifyRemoteIndex(ctx context.Context, r Registry, data *[]byte, maxAge time.Duration) error {
+	sigURL := r.URL + signatureSuffix
+	sig, err := fetchRegistry(ctx, sigURL, maxAge)
+	if err == nil && verifyIndex(r.PublicKeys, *data, sig) == nil {
+		return nil
+	}
+	if offline {
+		if err != nil {
+			return fmt.Errorf("registry %s: missing signature: %w", r.Name, err)
+		}
+		return fmt.Errorf("registry %s: %w", r.Name, verifyIndex(r.PublicKeys, *data, sig))
+	}
+	fresh, err := fetchRegistry(ctx, r.URL, 0)
+	if err != nil {
+		return err
+	}
+	sig, err = fetchRegistry(ctx, sigURL, 0)
+	if err != nil {
+		return fmt.Errorf("registry %s: missing signature: %w", r.Name, err)
+	}
+	if err := verifyIndex(r.PublicKeys, fresh, sig); err != nil {
+		return fmt.Errorf("registry %s: %w", r.Name, err)
+	}
+	*data = fresh
+	return nil
+}
+
+func readPrivateKey(path string) (ed25519.PrivateKey, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := decodeBase64(b)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	switch len(raw) {
+	case ed25519.SeedSize:
+		return ed25519.NewKeyFromSeed(raw), nil
+	case ed25519.PrivateKeySize:
+		return ed25519.PrivateKey(raw), nil
+	}
+	return nil, fmt.Errorf("%s: not an ed25519 private key", path)
+}
+
+var (
+	signKey  string
+	signFile string
+	signOut  string
+	keygenTo string
+)
+
+var registrySignCmd = &cobra.Command{Use: "sign", Short: "Write a detached signature for a registry.json", RunE: func(cmd *cobra.Command, args []string) error {
+	if signKey == "" {
+		return errors.New("--key required")
+	}
+	priv, err := readPrivateKey(signKey)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(signFile)
+	if err != nil {
+		return err
+	}
+	out := signOut
+	if out == "" {
+		out = signFile + signatureSuffix
+	}
+	sig := base64.StdEncoding.EncodeToString(ed25519.Sign(priv, data))
+	if err := os.WriteFile(out, []byte(sig+"\n"), 0o644); err != nil {
+		return err
+	}
+	fmt.Println("Signed", signFile, "->", out)
+	fmt.Println("Public key:", base64.StdEncoding.EncodeToString(priv.Public().(ed25519.PublicKey)))
+	return nil
+}}
+
+var registryKeygenCmd = &cobra.Command{Use: "keygen", Short: "Generate an ed25519 key pair for signing registries", RunE: func(cmd *cobra.Command, args []string) error {
+	// The key is kept out of the working directory by default, where it
+	// could end up published along with the registry it signs.
+	out := keygenTo
+	if out == "" {
+		out = filepath.Join(configDir(), "registry.key")
+		if err := os.MkdirAll(configDir(), 0o700); err != nil {
+			return err
+		}
+	}
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(out, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if errors.Is(err, fs.ErrExist) {
+		return fmt.Errorf("%s already exists", out)
+	} else if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(f, base64.StdEncoding.EncodeToString(priv))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(out)
+		return err
+	}
+	pubText := base64.StdEncoding.EncodeToString(pub)
+	if err := os.WriteFile(out+".pub", []byte(pubText+"\n"), 0o644); err != nil {
+		return err
+	}
+	fmt.Println("Private key:", out)
+	fmt.Println("Public key:", pubText)
+	return nil
+}}
+
+func init() {
+	registrySignCmd.Flags().StringVar(&signKey, "key", "", "Path to the base64 ed25519 private key")
+	registrySignCmd.Flags().StringVar(&signFile, "file", "registry.json", "Registry index to sign")
+	registrySignCmd.Flags().StringVar(&signOut, "out", "", "Signature path (default <file>.sig)")
+	registryKeygenCmd.Flags().StringVar(&keygenTo, "out", "", "Where to write the private key (default <config dir>/registry.key); the public key goes to <out>.pub")
+	registryCmd.AddCommand(registrySignCmd, registryKeygenCmd)
+}
@@ -0,0 +1,225 @@
+/*
+ * // Copyright 2025 getDragon-dev
+ * // Licensed under the Apache License, Version 2.0 (the "License");
+ * // you may not use this file except in compliance with the License.
+ * // You may obtain a copy of the License at http://www.apache.org/licenses/LICENSE-2.0
+ * // Unless required by applicable law or agreed to in writing, software
+ * // distributed under the License is distributed on an "AS IS" BASIS,
+ * // WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * // See the License for the specific language governing permissions and
+ * // limitations under the License.
+ */
+
+package cmd
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestKey(t *testing.T) (pub string, priv ed25519.PrivateKey) {
+	t.Helper()
+	p, k, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(p), k
+}
+
+func signBytes(k ed25519.PrivateKey, data []byte) []byte {
+	return []byte(base64.StdEncoding.EncodeToString(ed25519.Sign(k, data)) + "\n")
+}
+
+func TestVerifyIndex(t *testing.T) {
+	pub, priv := newTestKey(t)
+	other, _ := newTestKey(t)
+	data := []byte(`{"blueprints":[]}`)
+	sig := signBytes(priv, data)
+
+	tests := []struct {
+		name string
+		keys []string
+		data []byte
+		sig  []byte
+		err  string
+	}{
+		{"good", []string{pub}, data, sig, ""},
+		{"any trusted key", []string{other, pub}, data, sig, ""},
+		{"tampered index", []string{pub}, []byte(`{"blueprints":[{}]}`), sig, "does not match any trusted key"},
+		{"wrong key", []string{other}, data, sig, "does not match any trusted key"},
+		{"no keys", nil, data, sig, "does not match any trusted key"},
+		{"empty signature", []string{pub}, data, nil, "does not match any trusted key"},
+		{"malformed signature", []string{pub}, data, []byte("not base64!"), "malformed signature"},
+		{"malformed key", []string{"AAAA"}, data, sig, `malformed public key "AAAA"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verifyIndex(tt.keys, tt.data, tt.sig)
+			if tt.err == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("err = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestVerifyRemoteIndex(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	pub, priv := newTestKey(t)
+	_, wrong := newTestKey(t)
+
+	var mu sync.Mutex
+	var index, sig []byte
+	hits := map[string]int{}
+	publish := func(data []byte, k ed25519.PrivateKey) {
+		mu.Lock()
+		defer mu.Unlock()
+		index = data
+		sig = nil
+		if k != nil {
+			sig = signBytes(k, data)
+		}
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		hits[r.URL.Path]++
+		switch {
+		case r.URL.Path == "/registry.json":
+			_, _ = w.Write(index)
+		case r.URL.Path == "/registry.json.sig" && sig != nil:
+			_, _ = w.Write(sig)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	reg := Registry{Name: "signed", URL: srv.URL + "/registry.json", PublicKeys: []string{pub}}
+	ctx := context.Background()
+
+	// Both cached and valid: nothing is refetched.
+	v1 := []byte(`{"blueprints":[],"v":1}`)
+	publish(v1, priv)
+	data, err := fetchRegistry(ctx, reg.URL, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := verifyRemoteIndex(ctx, reg, &data, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if hits["/registry.json"] != 1 || hits["/registry.json.sig"] != 1 {
+		t.Fatalf("hits = %v", hits)
+	}
+
+	// The index was refreshed but the cached signature is the old one:
+	// both are fetched again once and the fresh pair verifies.
+	v2 := []byte(`{"blueprints":[],"v":2}`)
+	publish(v2, priv)
+	data = v2
+	if err := verifyRemoteIndex(ctx, reg, &data, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != string(v2) || hits["/registry.json"] != 2 || hits["/registry.json.sig"] != 2 {
+		t.Fatalf("data = %s, hits = %v", data, hits)
+	}
+
+	// A tampered index is rejected after the single retry.
+	publish([]byte(`{"blueprints":[],"v":3}`), wrong)
+	data = []byte(`{"blueprints":[],"v":3}`)
+	if err := verifyRemoteIndex(ctx, reg, &data, time.Hour); err == nil || !strings.Contains(err.Error(), "does not match any trusted key") {
+		t.Fatalf("tampered: err = %v", err)
+	}
+	if hits["/registry.json"] != 3 || hits["/registry.json.sig"] != 3 {
+		t.Fatalf("hits = %v, want one retry", hits)
+	}
+
+	// No signature published at all.
+	publish(v1, nil)
+	data = v1
+	if err := verifyRemoteIndex(ctx, reg, &data, 0); err == nil || !strings.Contains(err.Error(), "missing signature") {
+		t.Fatalf("unsigned: err = %v", err)
+	}
+}
+
+func TestSignAndKeygenCommands(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(dir, "config"))
+	saved := []string{signKey, signFile, signOut, keygenTo}
+	t.Cleanup(func() { signKey, signFile, signOut, keygenTo = saved[0], saved[1], saved[2], saved[3] })
+
+	// keygen writes below the config directory unless --out is given.
+	keygenTo = ""
+	if err := registryKeygenCmd.RunE(registryKeygenCmd, nil); err != nil {
+		t.Fatal(err)
+	}
+	key := filepath.Join(configDir(), "registry.key")
+	fi, err := os.Stat(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runtime.GOOS != "windows" && fi.Mode().Perm() != 0o600 {
+		t.Errorf("key mode = %v, want 0600", fi.Mode().Perm())
+	}
+	if err := registryKeygenCmd.RunE(registryKeygenCmd, nil); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("second keygen: err = %v", err)
+	}
+	pub, err := os.ReadFile(key + ".pub")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	index := filepath.Join(dir, "registry.json")
+	data := []byte(`{"blueprints":[]}`)
+	if err := os.WriteFile(index, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	signKey, signFile, signOut = key, index, ""
+	if err := registrySignCmd.RunE(registrySignCmd, nil); err != nil {
+		t.Fatal(err)
+	}
+	sig, err := os.ReadFile(index + signatureSuffix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := []string{strings.TrimSpace(string(pub))}
+	if err := verifyIndex(keys, data, sig); err != nil {
+		t.Errorf("fresh signature: %v", err)
+	}
+	if err := verifyIndex(keys, append(data, ' '), sig); err == nil {
+		t.Error("signature verified a modified index")
+	}
+
+	keygenTo = filepath.Join(dir, "other.key")
+	if err := registryKeygenCmd.RunE(registryKeygenCmd, nil); err != nil {
+		t.Fatal(err)
+	}
+	other, err := os.ReadFile(keygenTo + ".pub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := verifyIndex([]string{strings.TrimSpace(string(other))}, data, sig); err == nil {
+		t.Error("signature verified against the wrong key")
+	}
+
+	signKey = ""
+	if err := registrySignCmd.RunE(registrySignCmd, nil); err == nil {
+		t.Error("sign without --key succeeded")
+	}
+}
@@ -1,208 +1,428 @@
 package cmd
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
-	"io"
-	"net/http"
-	"strconv"
+	"os"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	corereg "github.com/getDragon-dev/dragon-core/registry"
 )
 
-func resolveRegistry() (string, error) {
+func defaultRegistry() (Registry, error) {
 	if registryPath != "" {
-		return registryPath, nil
+		return Registry{Name: autoName(registryPath), URL: registryPath}, nil
 	}
-	cfg, err := readConfig()
+	cfg, _, err := effectiveConfig()
 	if err != nil {
-		return "", err
+		return Registry{}, err
 	}
 	if cfg.Default == "" || len(cfg.Registries) == 0 {
-		return "", fmt.Errorf("no default registry configured")
+		return Registry{}, fmt.Errorf("no default registry configured")
 	}
 	for _, r := range cfg.Registries {
 		if r.Name == cfg.Default {
-			return r.URL, nil
+			return r, nil
 		}
 	}
-	return "", fmt.Errorf("default registry %q not found", cfg.Default)
+	return Registry{}, fmt.Errorf("default registry %q not found", cfg.Default)
 }
 
-func resolveOrder() ([]string, error) {
+func resolveOrder() ([]Registry, error) {
 	if registryPath != "" {
-		return []string{registryPath}, nil
+		return []Registry{{Name: autoName(registryPath), URL: registryPath}}, nil
 	}
-	cfg, err := readConfig()
+	cfg, _, err := effectiveConfig()
 	if err != nil {
 		return nil, err
 	}
-	urls := []string{}
+	regs := []Registry{}
 	if len(cfg.Order) > 0 {
 		for _, name := range cfg.Order {
 			for _, r := range cfg.Registries {
 				if r.Name == name {
-					urls = append(urls, r.URL)
+					regs = append(regs, r)
 					break
 				}
 			}
 		}
-		return urls, nil
+		return regs, nil
 	}
 	for _, r := range cfg.Registries {
 		if r.Name == cfg.Default {
-			urls = append(urls, r.URL)
+			regs = append(regs, r)
 			break
 		}
 	}
 	for _, r := range cfg.Registries {
 		if r.Name != cfg.Default {
-			urls = append(urls, r.URL)
+			regs = append(regs, r)
 		}
 	}
-	return urls, nil
+	return regs, nil
 }
 
-func loadLocation(loc string) (corereg.Database, error) {
-	if strings.HasPrefix(loc, "http://") || strings.HasPrefix(loc, "https://") {
-		resp, err := http.Get(loc)
+func isRemote(loc string) bool {
+	return strings.HasPrefix(loc, "http://") || strings.HasPrefix(loc, "https://")
+}
+
+// fetchTimeoutFor returns the per-registry timeout, falling back to
+// --registry-timeout when the registry doesn't set its own.
+func fetchTimeoutFor(r Registry) time.Duration {
+	if r.Timeout != "" {
+		if d, err := time.ParseDuration(r.Timeout); err == nil {
+			return d
+		}
+	}
+	return fetchTimeout
+}
+
+// loadLocation loads the index of r, verifying its detached signature first
+// when the registry has trusted keys configured.
+func loadLocation(ctx context.Context, r Registry) (registrySet, error) {
+	set := registrySet{Name: r.Name, URL: r.URL}
+	loc := r.URL
+	var data []byte
+	var err error
+	switch {
+	case isGit(loc):
+		g, err := parseGitSource(loc)
 		if err != nil {
-			return corereg.Database{}, err
+			return set, err
 		}
-		defer resp.Body.Close()
-		if resp.StatusCode/100 != 2 {
-			b, _ := io.ReadAll(resp.Body)
-			return corereg.Database{}, fmt.Errorf("GET %s: %d: %s", loc, resp.StatusCode, string(b))
+		file := g.Path
+		if file == "" {
+			file = "registry.json"
 		}
-		var db corereg.Database
-		data, _ := io.ReadAll(resp.Body)
-		if err := json.Unmarshal(data, &db); err != nil {
-			return corereg.Database{}, err
+		if data, err = g.readFile(ctx, file); err != nil {
+			return set, err
 		}
-		if db.Blueprints == nil {
-			db.Blueprints = []corereg.Blueprint{}
+		if len(r.PublicKeys) > 0 {
+			sig, err := g.readFile(ctx, file+signatureSuffix)
+			if err != nil {
+				return set, fmt.Errorf("registry %s: missing signature: %w", r.Name, err)
+			}
+			if err := verifyIndex(r.PublicKeys, data, sig); err != nil {
+				return set, fmt.Errorf("registry %s: %w", r.Name, err)
+			}
+		}
+	case isOCI(loc):
+		if len(r.PublicKeys) > 0 {
+			return set, fmt.Errorf("registry %s: signatures are not supported for OCI registries; pin the index by digest instead", r.Name)
+		}
+		// An index is held in memory; cap it like a bundle download.
+		limit, _, err := bundleLimits()
+		if err != nil {
+			return set, err
+		}
+		var buf bytes.Buffer
+		if _, err := pullOCI(ctx, loc, &buf, limit); err != nil {
+			return set, err
+		}
+		data = buf.Bytes()
+	case isRemote(loc):
+		data, err = fetchRegistry(ctx, loc, cacheTTL())
+		if err != nil {
+			return set, err
+		}
+		if len(r.PublicKeys) > 0 {
+			if err := verifyRemoteIndex(ctx, r, &data, cacheTTL()); err != nil {
+				return set, err
+			}
+		}
+	default:
+		data, err = os.ReadFile(loc)
+		if err != nil {
+			return set, err
+		}
+		if len(r.PublicKeys) > 0 {
+			sig, err := os.ReadFile(loc + signatureSuffix)
+			if err != nil {
+				return set, fmt.Errorf("registry %s: missing signature: %w", r.Name, err)
+			}
+			if err := verifyIndex(r.PublicKeys, data, sig); err != nil {
+				return set, fmt.Errorf("registry %s: %w", r.Name, err)
+			}
 		}
-		return db, nil
 	}
-	return corereg.Load(loc)
+	// Decode the bytes that were verified; reading the file again would
+	// let it change after the signature check.
+	return set, decodeIndex(&set, data)
+}
+
+// decodeIndex fills in set from the raw index data.
+func decodeIndex(set *registrySet, data []byte) error {
+	if err := json.Unmarshal(data, &set.DB); err != nil {
+		return err
+	}
+	if set.DB.Blueprints == nil {
+		set.DB.Blueprints = []corereg.Blueprint{}
+	}
+	var err error
+	set.Extras, err = decodeIndexExtras(data)
+	return err
 }
 
 func loadRegistry() (corereg.Database, error) {
-	loc, err := resolveRegistry()
+	set, err := loadDefaultSet()
+	return set.DB, err
+}
+
+func loadDefaultSet() (registrySet, error) {
+	r, err := defaultRegistry()
 	if err != nil {
-		return corereg.Database{}, err
+		return registrySet{}, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), fetchTimeoutFor(r))
+	defer cancel()
+	return loadLocation(ctx, r)
+}
+
+type registrySet struct {
+	Name   string
+	URL    string
+	DB     corereg.Database
+	Extras map[string]indexEntry
+}
+
+// resolve pairs a core blueprint entry with its extra index fields.
+func (s registrySet) resolve(bp corereg.Blueprint) resolvedBlueprint {
+	e := s.Extras[bp.Name]
+	return resolvedBlueprint{Blueprint: bp, Digest: e.Digest, Size: e.Size, TemplateRoot: e.TemplateRoot, Deprecated: e.Deprecated, Yanked: e.Yanked, Registry: s.Name, Source: s.URL}
+}
+
+// releases lists every version of bp published in s, newest first. Entries
+// in the version history override the top-level one when versions collide.
+func (s registrySet) releases(bp corereg.Blueprint) []resolvedBlueprint {
+	out := []resolvedBlueprint{}
+	seen := map[string]bool{}
+	for _, v := range s.Extras[bp.Name].Versions {
+		if seen[v.Version] {
+			continue
+		}
+		seen[v.Version] = true
+		r := s.resolve(bp)
+		r.Version, r.DownloadURL, r.Digest, r.Size, r.Yanked = v.Version, v.DownloadURL, v.Digest, v.Size, v.Yanked
+		if v.TemplateRoot != "" {
+			r.TemplateRoot = v.TemplateRoot
+		}
+		if v.Deprecated != nil {
+			r.Deprecated = v.Deprecated
+		}
+		out = append(out, r)
+	}
+	if bp.Version != "" && !seen[bp.Version] {
+		out = append(out, s.resolve(bp))
+	}
+	sort.SliceStable(out, func(i, j int) bool { return compareVersions(out[i].Version, out[j].Version) > 0 })
+	return out
+}
+
+// listed returns the release of bp that listings show: the newest one that
+// has not been yanked, or the top-level entry when includeYanked is set.
+// ok is false when every release is yanked and includeYanked is not set.
+func (s registrySet) listed(bp corereg.Blueprint, includeYanked bool) (resolvedBlueprint, bool) {
+	if includeYanked {
+		return s.resolve(bp), true
 	}
-	return loadLocation(loc)
+	for _, r := range s.releases(bp) {
+		if !r.Yanked {
+			return r, true
+		}
+	}
+	return resolvedBlueprint{}, false
 }
 
-func loadAllRegistries() ([]struct {
-	URL string
-	DB  corereg.Database
-}, error) {
-	urls, err := resolveOrder()
+// loadAllRegistries fetches every configured registry concurrently and
+// returns them in priority order. A registry that fails to load is reported
+// as a warning and skipped, unless --strict is set or nothing loaded at all.
+func loadAllRegistries() ([]registrySet, error) {
+	regs, err := resolveOrder()
 	if err != nil {
 		return nil, err
 	}
-	res := make([]struct {
-		URL string
-		DB  corereg.Database
-	}, 0, len(urls))
-	for _, u := range urls {
-		db, err := loadLocation(u)
-		if err != nil {
-			return nil, fmt.Errorf("load %s: %w", u, err)
+	sets := make([]registrySet, len(regs))
+	errs := make([]error, len(regs))
+	var wg sync.WaitGroup
+	for i, r := range regs {
+		wg.Go(func() {
+			ctx, cancel := context.WithTimeout(context.Background(), fetchTimeoutFor(r))
+			defer cancel()
+			sets[i], errs[i] = loadLocation(ctx, r)
+		})
+	}
+	wg.Wait()
+
+	res := make([]registrySet, 0, len(regs))
+	for i, r := range regs {
+		if errs[i] != nil {
+			if strict {
+				return nil, fmt.Errorf("load %s: %w", r.URL, errs[i])
+			}
+			fmt.Fprintf(os.Stderr, "warning: skipping registry %s: %v\n", r.Name, errs[i])
+			continue
 		}
-		res = append(res, struct {
-			URL string
-			DB  corereg.Database
-		}{URL: u, DB: db})
+		res = append(res, sets[i])
+	}
+	if len(res) == 0 && len(regs) > 0 {
+		return nil, fmt.Errorf("no registry could be loaded")
 	}
 	return res, nil
 }
 
-// semver
-func parseSemver(v string) (int, int, int) {
-	v = strings.TrimSpace(strings.TrimPrefix(v, "v"))
-	p := strings.SplitN(v, ".", 3)
-	to := func(s string) int { n, _ := strconv.Atoi(s); return n }
-	a, b, c := 0, 0, 0
-	if len(p) > 0 {
-		a = to(p[0])
+// blueprintRef is a parsed "[registry/]name[@constraint]" reference.
+type blueprintRef struct {
+	Registry   string
+	Name       string
+	Constraint string
+}
+
+func parseRef(ref string) blueprintRef {
+	var r blueprintRef
+	if i := strings.LastIndex(ref, "@"); i > 0 {
+		ref, r.Constraint = ref[:i], ref[i+1:]
 	}
-	if len(p) > 1 {
-		b = to(p[1])
+	if i := strings.Index(ref, "/"); i > 0 {
+		r.Registry, ref = ref[:i], ref[i+1:]
 	}
-	if len(p) > 2 {
-		c = to(p[2])
+	r.Name = ref
+	return r
+}
+
+// resolveBlueprint finds the blueprint named by ref
+// ("[registry/]name[@constraint]"; constraint may also come from a flag) and
+// picks the highest release matching the constraint across all registries,
+// or only the named one. When two registries publish the same version, the
+// higher-priority one wins.
+func resolveBlueprint(ref, constraint string) (resolvedBlueprint, error) {
+	if r := parseRef(ref); r.Constraint == "" && constraint == "" {
+		constraint = pinnedConstraint(r)
 	}
-	return a, b, c
+	sets, err := loadAllRegistries()
+	if err != nil {
+		return resolvedBlueprint{}, err
+	}
+	return resolveIn(sets, ref, constraint)
 }
-func satisfies(v, constraint string) bool {
-	if constraint == "" {
-		return true
+
+// pinnedConstraint returns the version pin configured for r, preferring a
+// registry-qualified pin ("internal/api-service") over a bare one.
+func pinnedConstraint(r blueprintRef) string {
+	cfg, _, err := effectiveConfig()
+	if err != nil {
+		return ""
 	}
-	vM, vm, vp := parseSemver(v)
-	op := ""
-	ver := constraint
-	for _, o := range []string{">=", "<=", ">", "<", "=", "^", "~"} {
-		if strings.HasPrefix(constraint, o) {
-			op = o
-			ver = strings.TrimSpace(strings.TrimPrefix(constraint, o))
-			break
+	if r.Registry != "" {
+		if c, ok := cfg.Pins[r.Registry+"/"+r.Name]; ok {
+			return c
 		}
 	}
-	tM, tm, tp := parseSemver(ver)
-	cmp := func(aM, aN, aP, bM, bN, bP int) int {
-		if aM != bM {
-			if aM < bM {
-				return -1
+	return cfg.Pins[r.Name]
+}
+
+func resolveIn(sets []registrySet, ref, constraint string) (resolvedBlueprint, error) {
+	r := parseRef(ref)
+	name, c := r.Name, r.Constraint
+	if c != "" && constraint != "" && c != constraint {
+		return resolvedBlueprint{}, fmt.Errorf("conflicting version constraints %q and %q", c, constraint)
+	}
+	if c == "" {
+		c = constraint
+	}
+	rng, err := parseRange(c)
+	if err != nil {
+		return resolvedBlueprint{}, err
+	}
+	if r.Registry != "" {
+		var only []registrySet
+		for _, s := range sets {
+			if s.Name == r.Registry {
+				only = append(only, s)
 			}
-			return 1
 		}
-		if aN != bN {
-			if aN < bN {
-				return -1
+		if len(only) == 0 {
+			return resolvedBlueprint{}, fmt.Errorf("registry %q is not configured or could not be loaded", r.Registry)
+		}
+		sets = only
+	}
+	releases := blueprintVersions(sets, name)
+	// Yanked releases never satisfy a range; they can only be selected by
+	// pinning the exact version with --allow-yanked.
+	pin, exact := exactVersion(c)
+	var best *resolvedBlueprint
+	var bestV semver
+	found := []string{}
+	yanked := []resolvedBlueprint{}
+	for _, r := range releases {
+		if r.Yanked {
+			yanked = append(yanked, r)
+			continue
+		}
+		found = append(found, r.Version)
+		v, err := parseVersion(r.Version)
+		if err != nil || !rng.match(v) {
+			continue
+		}
+		if best == nil || v.compare(bestV) > 0 {
+			best, bestV = &r, v
+		}
+	}
+	if best != nil {
+		return *best, nil
+	}
+	if exact {
+		for _, r := range yanked {
+			if v, err := parseVersion(r.Version); err == nil && v.compare(pin) == 0 {
+				if !allowYanked {
+					return resolvedBlueprint{}, fmt.Errorf("%s %s has been yanked; pass --allow-yanked to use it anyway", name, r.Version)
+				}
+				return r, nil
 			}
-			return 1
 		}
-		if aP != bP {
-			if aP < bP {
-				return -1
+	}
+	if c == "" && len(found) > 0 {
+		// Without a constraint, fall back to the newest entry even if it is
+		// a prerelease or doesn't parse, as older registries may list those.
+		var latest *resolvedBlueprint
+		for _, r := range releases {
+			if !r.Yanked && (latest == nil || compareVersions(r.Version, latest.Version) > 0) {
+				latest = &r
 			}
-			return 1
-		}
-		return 0
-	}
-	switch op {
-	case ">=":
-		return cmp(vM, vm, vp, tM, tm, tp) >= 0
-	case "<=":
-		return cmp(vM, vm, vp, tM, tm, tp) <= 0
-	case ">":
-		return cmp(vM, vm, vp, tM, tm, tp) > 0
-	case "<":
-		return cmp(vM, vm, vp, tM, tm, tp) < 0
-	case "=":
-		return cmp(vM, vm, vp, tM, tm, tp) == 0
-	case "^":
-		return cmp(vM, vm, vp, tM, tm, tp) >= 0 && vM == tM
-	case "~":
-		return cmp(vM, vm, vp, tM, tm, tp) >= 0 && vM == tM && vm == tm
-	default:
-		return cmp(vM, vm, vp, tM, tm, tp) == 0
+		}
+		return *latest, nil
+	}
+	if len(found) == 0 && len(yanked) > 0 {
+		if showYanked {
+			return yanked[0], nil
+		}
+		return resolvedBlueprint{}, fmt.Errorf("every release of %s has been yanked", name)
+	}
+	if len(found) > 0 {
+		return resolvedBlueprint{}, fmt.Errorf("no version of %s satisfies %s (available: %s)", name, c, strings.Join(found, ", "))
 	}
+	return resolvedBlueprint{}, fmt.Errorf("blueprint %q not found", name)
 }
 
-func findBlueprint(name string) (corereg.Blueprint, string, error) {
-	sets, err := loadAllRegistries()
-	if err != nil {
-		return corereg.Blueprint{}, "", err
-	}
+// exactVersion reports whether constraint pins a single version, as in
+// "1.2.0" or "=1.2.0".
+func exactVersion(constraint string) (semver, bool) {
+	v, err := parseVersion(strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(constraint), "=")))
+	return v, err == nil
+}
+
+// blueprintVersions lists every published release of name across sets, in
+// registry priority order and newest first within each registry.
+func blueprintVersions(sets []registrySet, name string) []resolvedBlueprint {
+	out := []resolvedBlueprint{}
 	for _, s := range sets {
 		if bp, err := corereg.Find(s.DB, name); err == nil {
-			return *bp, s.URL, nil
+			out = append(out, s.releases(*bp)...)
 		}
 	}
-	return corereg.Blueprint{}, "", fmt.Errorf("blueprint %q not found", name)
+	return out
 }
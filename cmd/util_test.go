@@ -0,0 +1,340 @@
+/*
+ * // Copyright 2025 getDragon-dev
+ * // Licensed under the Apache License, Version 2.0 (the "License");
+ * // you may not use this file except in compliance with the License.
+ * // You may obtain a copy of the License at http://www.apache.org/licenses/LICENSE-2.0
+ * // Unless required by applicable law or agreed to in writing, software
+ * // distributed under the License is distributed on an "AS IS" BASIS,
+ * // WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * // See the License for the specific language governing permissions and
+ * // limitations under the License.
+ */
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testSet decodes index as the registry called name.
+func testSet(t *testing.T, name, index string) registrySet {
+	t.Helper()
+	set := registrySet{Name: name, URL: "https://" + name + ".example/registry.json"}
+	if err := decodeIndex(&set, []byte(index)); err != nil {
+		t.Fatal(err)
+	}
+	return set
+}
+
+func TestResolveIn(t *testing.T) {
+	sets := []registrySet{
+		testSet(t, "internal", `{"blueprints": [
+			{"name": "api", "version": "1.4.0", "download_url": "https://internal.example/api-1.4.0.zip",
+			 "versions": [
+				{"version": "1.2.0", "download_url": "https://internal.example/api-1.2.0.zip"},
+				{"version": "2.1.0", "download_url": "https://internal.example/api-2.1.0.zip", "yanked": true}
+			 ]},
+			{"name": "gone", "version": "1.0.0", "yanked": true}
+		]}`),
+		testSet(t, "public", `{"blueprints": [
+			{"name": "api", "version": "2.0.0", "download_url": "https://public.example/api-2.0.0.zip",
+			 "versions": [
+				{"version": "1.4.0", "download_url": "https://public.example/api-1.4.0.zip"},
+				{"version": "3.0.0-beta.1", "download_url": "https://public.example/api-3.0.0-beta.1.zip"}
+			 ]},
+			{"name": "web", "version": "0.3.0"}
+		]}`),
+	}
+	tests := []struct {
+		name       string
+		ref, flag  string
+		allow      bool
+		version    string
+		registry   string
+		errContain string
+	}{
+		{"highest across registries", "api", "", false, "2.0.0", "public", ""},
+		{"range", "api", "^1.0", false, "1.4.0", "internal", ""},
+		{"same version prefers priority", "api@1.4.0", "", false, "1.4.0", "internal", ""},
+		{"name@version", "api@1.2.0", "", false, "1.2.0", "internal", ""},
+		{"flag constraint", "api", "~1.2", false, "1.2.0", "internal", ""},
+		{"matching constraints", "api@^1", "^1", false, "1.4.0", "internal", ""},
+		{"conflicting constraints", "api@^1", "^2", false, "", "", "conflicting version constraints"},
+		{"range skips yanked", "api", ">=2.1.0", false, "", "", "no version of api satisfies"},
+		{"range skips yanked even when allowed", "api", "^2", true, "2.0.0", "public", ""},
+		{"exact pin to yanked", "api@2.1.0", "", false, "", "", "has been yanked; pass --allow-yanked"},
+		{"exact pin to yanked allowed", "api@=2.1.0", "", true, "2.1.0", "internal", ""},
+		{"prerelease by exact pin", "api@3.0.0-beta.1", "", false, "3.0.0-beta.1", "public", ""},
+		{"every release yanked", "gone", "", false, "", "", "every release of gone has been yanked"},
+		{"only one registry", "web", "", false, "0.3.0", "public", ""},
+		{"unknown blueprint", "nope", "", false, "", "", `blueprint "nope" not found`},
+		{"bad constraint", "api@>>1", "", false, "", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			allowYanked = tt.allow
+			defer func() { allowYanked = false }()
+			got, err := resolveIn(sets, tt.ref, tt.flag)
+			if tt.version == "" {
+				if err == nil {
+					t.Fatalf("resolved %s from %s, want an error", got.Version, got.Registry)
+				}
+				if !strings.Contains(err.Error(), tt.errContain) {
+					t.Fatalf("err = %v, want it to mention %q", err, tt.errContain)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Version != tt.version || got.Registry != tt.registry {
+				t.Errorf("resolved %s from %s, want %s from %s", got.Version, got.Registry, tt.version, tt.registry)
+			}
+			want := "https://" + tt.registry + ".example/api-" + tt.version + ".zip"
+			if strings.HasPrefix(tt.ref, "api") && got.DownloadURL != want {
+				t.Errorf("download_url = %q, want %q", got.DownloadURL, want)
+			}
+		})
+	}
+}
+
+func TestBlueprintVersions(t *testing.T) {
+	sets := []registrySet{
+		testSet(t, "a", `{"blueprints": [{"name": "api", "version": "1.0.0", "versions": [
+			{"version": "1.10.0"}, {"version": "1.2.0"}, {"version": "1.0.0", "yanked": true}]}]}`),
+		testSet(t, "b", `{"blueprints": [{"name": "api", "version": "2.0.0"}, {"name": "web", "version": "1.0.0"}]}`),
+	}
+	var got []string
+	for _, r := range blueprintVersions(sets, "api") {
+		got = append(got, r.Registry+":"+r.Version)
+	}
+	// The history's entry for 1.0.0 wins over the top-level one.
+	if want := []string{"a:1.10.0", "a:1.2.0", "a:1.0.0", "b:2.0.0"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("versions = %q, want %q", got, want)
+	}
+	if r := blueprintVersions(sets, "api")[2]; !r.Yanked {
+		t.Error("1.0.0 lost its yanked flag")
+	}
+	if got := blueprintVersions(sets, "nope"); len(got) != 0 {
+		t.Errorf("versions of an unknown blueprint: %v", got)
+	}
+}
+
+func TestParseRef(t *testing.T) {
+	tests := []struct {
+		ref  string
+		want blueprintRef
+	}{
+		{"api-service", blueprintRef{Name: "api-service"}},
+		{"api-service@1.2.0", blueprintRef{Name: "api-service", Constraint: "1.2.0"}},
+		{"internal/api-service", blueprintRef{Registry: "internal", Name: "api-service"}},
+		{"internal/api-service@^2", blueprintRef{Registry: "internal", Name: "api-service", Constraint: "^2"}},
+		{"internal/tools/lint", blueprintRef{Registry: "internal", Name: "tools/lint"}},
+		{"/api-service", blueprintRef{Name: "/api-service"}},
+	}
+	for _, tt := range tests {
+		if got := parseRef(tt.ref); got != tt.want {
+			t.Errorf("parseRef(%q) = %+v, want %+v", tt.ref, got, tt.want)
+		}
+	}
+}
+
+func TestResolveQualified(t *testing.T) {
+	sets := []registrySet{
+		testSet(t, "internal", `{"blueprints": [{"name": "api-service", "version": "1.5.0"}]}`),
+		testSet(t, "public", `{"blueprints": [
+			{"name": "api-service", "version": "2.3.0"},
+			{"name": "tools/lint", "version": "1.0.0"}
+		]}`),
+	}
+	tests := []struct {
+		ref, version, registry string
+		errContain             string
+	}{
+		{"internal/api-service", "1.5.0", "internal", ""},
+		{"public/api-service@^2", "2.3.0", "public", ""},
+		{"internal/api-service@^2", "", "", "no version of api-service satisfies ^2"},
+		// An unknown registry is an error, not a search of the others.
+		{"mirror/api-service", "", "", `registry "mirror" is not configured`},
+		// The part before the first slash always names a registry.
+		{"tools/lint", "", "", `registry "tools" is not configured`},
+		{"public/tools/lint", "1.0.0", "public", ""},
+		{"internal/tools/lint", "", "", `blueprint "tools/lint" not found`},
+	}
+	for _, tt := range tests {
+		got, err := resolveIn(sets, tt.ref, "")
+		if tt.version == "" {
+			if err == nil || !strings.Contains(err.Error(), tt.errContain) {
+				t.Errorf("%s: resolved %s from %s, %v; want an error mentioning %q", tt.ref, got.Version, got.Registry, err, tt.errContain)
+			}
+			continue
+		}
+		if err != nil || got.Version != tt.version || got.Registry != tt.registry {
+			t.Errorf("%s: resolved %s from %s, %v; want %s from %s", tt.ref, got.Version, got.Registry, err, tt.version, tt.registry)
+		}
+	}
+}
+
+func TestResolvedRegistries(t *testing.T) {
+	projectSetup(t, "", "")
+	sets := []registrySet{
+		testSet(t, "internal", `{"blueprints": [{"name": "api-service", "version": "2.0.0"}, {"name": "old", "version": "1.0.0"}]}`),
+		testSet(t, "public", `{"blueprints": [
+			{"name": "api-service", "version": "1.0.0"},
+			{"name": "old", "version": "1.1.0"},
+			{"name": "web", "version": "1.0.0"}
+		]}`),
+	}
+	// A higher version elsewhere wins over priority, so internal's old is
+	// the shadowed one.
+	want := map[string]string{"api-service": "internal", "old": "public", "web": "public"}
+	if got := resolvedRegistries(sets); !reflect.DeepEqual(got, want) {
+		t.Errorf("resolved = %v, want %v", got, want)
+	}
+
+	// A pin decides which release a plain reference picks, and so which
+	// entry is shadowed; a pin nothing matches resolves nowhere.
+	t.Setenv("DRAGON_PINS", "old=1.0.0,web=^2")
+	want = map[string]string{"api-service": "internal", "old": "internal", "web": ""}
+	if got := resolvedRegistries(sets); !reflect.DeepEqual(got, want) {
+		t.Errorf("resolved with pins = %v, want %v", got, want)
+	}
+}
+
+// registryServers starts one index server per name and configures them as
+// the user's registries, in that order, with an empty cache. A server
+// listed in slow answers after delay; one listed in hang never answers; any
+// other name not in ok fails with 404.
+func registryServers(t *testing.T, names []string, ok, slow, hang map[string]bool, delay time.Duration) {
+	t.Helper()
+	release := make(chan struct{})
+	t.Cleanup(func() { close(release) })
+	cfg := Config{Order: names}
+	for _, name := range names {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch {
+			case hang[name]:
+				select {
+				case <-r.Context().Done():
+				case <-release:
+				}
+				return
+			case slow[name]:
+				time.Sleep(delay)
+			case !ok[name]:
+				http.NotFound(w, r)
+				return
+			}
+			fmt.Fprintf(w, `{"blueprints": [{"name": "from-%s", "version": "1.0.0"}]}`, name)
+		}))
+		t.Cleanup(srv.Close)
+		r := Registry{Name: name, URL: srv.URL + "/registry.json"}
+		if hang[name] {
+			r.Timeout = "200ms"
+		}
+		cfg.Registries = append(cfg.Registries, r)
+	}
+	writeTestConfig(t, cfg)
+}
+
+// writeTestConfig points the config and cache directories at fresh
+// temporary ones and saves cfg as the user config.
+func writeTestConfig(t *testing.T, cfg Config) {
+	t.Helper()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	b, err := json.Marshal(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(configDir(), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(configDir(), "config.json"), b, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func setNames(sets []registrySet) []string {
+	var out []string
+	for _, s := range sets {
+		out = append(out, s.Name)
+	}
+	return out
+}
+
+func TestLoadAllRegistries(t *testing.T) {
+	all := map[string]bool{"first": true, "second": true, "third": true, "down": true}
+	names := []string{"first", "second", "third"}
+
+	t.Run("priority order", func(t *testing.T) {
+		// The registries answer in no particular order, and in parallel.
+		const delay = 300 * time.Millisecond
+		registryServers(t, names, all, all, nil, delay)
+		start := time.Now()
+		sets, err := loadAllRegistries()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d := time.Since(start); d >= time.Duration(len(names)-1)*delay+delay/2 {
+			t.Errorf("took %v; registries were not fetched concurrently", d)
+		}
+		if got := setNames(sets); !reflect.DeepEqual(got, names) {
+			t.Errorf("order = %q, want %q", got, names)
+		}
+		if bp := sets[0].DB.Blueprints; len(bp) != 1 || bp[0].Name != "from-first" {
+			t.Errorf("first registry holds %+v", bp)
+		}
+	})
+
+	t.Run("failure is skipped", func(t *testing.T) {
+		registryServers(t, []string{"first", "broken", "third"}, all, nil, nil, 0)
+		sets, err := loadAllRegistries()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := setNames(sets), []string{"first", "third"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("loaded %q, want %q", got, want)
+		}
+	})
+
+	t.Run("strict", func(t *testing.T) {
+		registryServers(t, []string{"first", "broken"}, all, nil, nil, 0)
+		strict = true
+		defer func() { strict = false }()
+		if _, err := loadAllRegistries(); err == nil || !strings.Contains(err.Error(), "404") {
+			t.Errorf("err = %v, want the broken registry's 404", err)
+		}
+	})
+
+	t.Run("all fail", func(t *testing.T) {
+		registryServers(t, []string{"broken", "gone"}, nil, nil, nil, 0)
+		if _, err := loadAllRegistries(); err == nil || !strings.Contains(err.Error(), "no registry could be loaded") {
+			t.Errorf("err = %v", err)
+		}
+	})
+
+	t.Run("per-registry timeout", func(t *testing.T) {
+		registryServers(t, []string{"first", "down"}, all, nil, map[string]bool{"down": true}, 0)
+		start := time.Now()
+		sets, err := loadAllRegistries()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := setNames(sets); !reflect.DeepEqual(got, []string{"first"}) {
+			t.Errorf("loaded %q, want only first", got)
+		}
+		if d := time.Since(start); d > 5*time.Second {
+			t.Errorf("took %v; the hung registry's 200ms timeout was not applied", d)
+		}
+	})
+}
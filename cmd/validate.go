@@ -22,35 +22,82 @@ import (
 )
 
 type vmanifest struct {
-	Name        string   `yaml:"name"`
-	Version     string   `yaml:"version"`
-	Description string   `yaml:"description"`
-	Tags        []string `yaml:"tags"`
+	Name        string       `yaml:"name"`
+	Version     string       `yaml:"version"`
+	Description string       `yaml:"description"`
+	Tags        []string     `yaml:"tags"`
+	Deprecated  *deprecation `yaml:"deprecated"`
+	Variables   []variable   `yaml:"variables"`
 }
 
 var validateFile string
 
 var validateCmd = &cobra.Command{Use: "validate", Short: "Validate a blueprint manifest.yaml",
+	Long: `Validate a blueprint manifest.yaml.
+
+Besides name, version, description, tags and deprecated, a manifest may
+declare the template variables gen accepts:
+
+  variables:
+    - name: Router
+      description: HTTP router
+      default: servemux
+      enum: [chi, gorilla, httprouter, servemux]
+    - name: Port
+      type: int
+      default: 8080
+      min: 1
+      max: 65535
+    - name: Features
+      type: list
+      enum: [auth, metrics, tracing]
+    - name: DSN
+      help: Connection string, e.g. postgres://user@localhost/app
+      required: true
+      secret: true
+      pattern: '^postgres://'
+      when: DB == postgres-native
+
+type is string (the default), bool, int or list; --set values are
+converted to it, and lists are given comma-separated. min and max bound an
+int's value, a string's length or a list's length; enum and pattern apply
+to each list item. help is shown when prompting and secret masks the
+input. A variable whose when condition is false is skipped. Conditions
+combine NAME, !NAME, NAME == value and NAME != value with && and ||, and
+may only refer to variables declared earlier.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		b, err := os.ReadFile(validateFile)
-		if err != nil {
-			return err
-		}
-		var m vmanifest
-		if err := yaml.Unmarshal(b, &m); err != nil {
+		if _, err := readManifest(validateFile); err != nil {
 			return err
 		}
-		if m.Name == "" {
-			return errors.New("name is required")
-		}
-		if m.Version == "" {
-			return errors.New("version is required")
-		}
 		fmt.Println("OK:", validateFile)
 		return nil
 	},
 }
 
+func readManifest(path string) (vmanifest, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return vmanifest{}, err
+	}
+	var m vmanifest
+	if err := yaml.Unmarshal(b, &m); err != nil {
+		return vmanifest{}, err
+	}
+	if m.Name == "" {
+		return vmanifest{}, errors.New("name is required")
+	}
+	if m.Version == "" {
+		return vmanifest{}, errors.New("version is required")
+	}
+	if _, err := parseVersion(m.Version); err != nil {
+		return vmanifest{}, err
+	}
+	if err := checkVariables(m.Variables); err != nil {
+		return vmanifest{}, err
+	}
+	return m, nil
+}
+
 func init() {
 	validateCmd.Flags().StringVar(&validateFile, "file", "manifest.yaml", "Path to manifest.yaml")
 	rootCmd.AddCommand(validateCmd)
@@ -0,0 +1,462 @@
+/*
+ * // Copyright 2025 getDragon-dev
+ * // Licensed under the Apache License, Version 2.0 (the "License");
+ * // you may not use this file except in compliance with the License.
+ * // You may obtain a copy of the License at http://www.apache.org/licenses/LICENSE-2.0
+ * // Unless required by applicable law or agreed to in writing, software
+ * // distributed under the License is distributed on an "AS IS" BASIS,
+ * // WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * // See the License for the specific language governing permissions and
+ * // limitations under the License.
+ */
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// variable declares one template variable in a blueprint's manifest.yaml:
+//
+//	variables:
+//	  - name: DB
+//	    type: string
+//	    description: Database driver
+//	    default: sqlite-native
+//	    enum: [sqlite-native, postgres-native]
+//	  - name: Port
+//	    type: int
+//	    min: 1
+//	    max: 65535
+//	  - name: DSN
+//	    required: true
+//	    secret: true
+//	    when: DB != sqlite-native
+//
+// min and max bound an int's value, a string's length and a list's number of
+// items. enum and pattern apply to each item of a list. help is shown on
+// request when prompting, and secret masks the input.
+type variable struct {
+	Name        string `yaml:"name"`
+	Type        string `yaml:"type"`
+	Description string `yaml:"description"`
+	Help        string `yaml:"help"`
+	Default     any    `yaml:"default"`
+	Enum        []any  `yaml:"enum"`
+	Pattern     string `yaml:"pattern"`
+	Min         *int   `yaml:"min"`
+	Max         *int   `yaml:"max"`
+	Required    bool   `yaml:"required"`
+	Secret      bool   `yaml:"secret"`
+	When        string `yaml:"when"`
+}
+
+const (
+	typeString = "string"
+	typeBool   = "bool"
+	typeInt    = "int"
+	typeList   = "list"
+)
+
+var varNameRE = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+func (v variable) kind() string {
+	if v.Type == "" {
+		return typeString
+	}
+	return v.Type
+}
+
+// checkVariables validates the variables section of a manifest.
+func checkVariables(vs []variable) error {
+	var errs []error
+	declared := map[string]bool{}
+	for i, v := range vs {
+		bad := func(format string, args ...any) {
+			name := v.Name
+			if name == "" {
+				name = fmt.Sprintf("#%d", i+1)
+			}
+			errs = append(errs, fmt.Errorf("variable %s: %s", name, fmt.Sprintf(format, args...)))
+		}
+		switch {
+		case v.Name == "":
+			bad("name is required")
+		case !varNameRE.MatchString(v.Name):
+			bad("name must be a letter or underscore followed by letters, digits or underscores")
+		case declared[v.Name]:
+			bad("declared twice")
+		}
+		if !slices.Contains([]string{typeString, typeBool, typeInt, typeList}, v.kind()) {
+			bad("unknown type %q (want string, bool, int or list)", v.Type)
+			continue
+		}
+		if v.Pattern != "" {
+			if _, err := regexp.Compile(v.Pattern); err != nil {
+				bad("pattern: %v", err)
+			}
+		}
+		if v.Min != nil && v.Max != nil && *v.Min > *v.Max {
+			bad("min %d is greater than max %d", *v.Min, *v.Max)
+		}
+		if v.kind() == typeBool && (len(v.Enum) > 0 || v.Pattern != "" || v.Min != nil || v.Max != nil) {
+			bad("enum, pattern, min and max do not apply to bool")
+		}
+		if v.Secret && (v.kind() != typeString || len(v.Enum) > 0) {
+			bad("only free-form string variables can be secret")
+		}
+		for _, e := range v.Enum {
+			if _, err := v.coerceItem(e); err != nil {
+				bad("enum value %v: %v", e, err)
+			}
+		}
+		if v.Default != nil {
+			if d, err := v.coerce(v.Default); err != nil {
+				bad("default: %v", err)
+			} else if err := v.check(d); err != nil {
+				bad("default: %v", err)
+			}
+		}
+		if v.When != "" {
+			for _, ref := range whenRefs(v.When) {
+				if !declared[ref] {
+					bad("when refers to %s, which is not declared before it", ref)
+				}
+			}
+		}
+		declared[v.Name] = true
+	}
+	return errors.Join(errs...)
+}
+
+// coerce converts a value from --set (always a string), --vars or a config
+// file to the variable's type.
+func (v variable) coerce(val any) (any, error) {
+	if v.kind() != typeList {
+		if _, ok := val.([]any); ok {
+			return nil, fmt.Errorf("want a %s, got a list", v.kind())
+		}
+		return v.coerceItem(val)
+	}
+	var items []any
+	switch x := val.(type) {
+	case []any:
+		items = x
+	case []string:
+		for _, s := range x {
+			items = append(items, s)
+		}
+	case string:
+		for _, s := range splitList(x) {
+			items = append(items, s)
+		}
+	default:
+		items = []any{x}
+	}
+	out := make([]string, 0, len(items))
+	for _, it := range items {
+		s, err := v.coerceItem(it)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, s.(string))
+	}
+	return out, nil
+}
+
+// coerceItem converts a scalar; list items are strings.
+func (v variable) coerceItem(val any) (any, error) {
+	switch v.kind() {
+	case typeBool:
+		switch x := val.(type) {
+		case bool:
+			return x, nil
+		case string:
+			switch strings.ToLower(strings.TrimSpace(x)) {
+			case "true", "yes", "y", "on", "1":
+				return true, nil
+			case "false", "no", "n", "off", "0":
+				return false, nil
+			}
+		}
+		return nil, fmt.Errorf("%q is not a bool (use true or false)", fmt.Sprint(val))
+	case typeInt:
+		switch x := val.(type) {
+		case int:
+			return x, nil
+		case int64:
+			return int(x), nil
+		case uint64:
+			if x <= math.MaxInt {
+				return int(x), nil
+			}
+		case float64:
+			if x == math.Trunc(x) && math.Abs(x) < 1<<53 {
+				return int(x), nil
+			}
+		case string:
+			if n, err := strconv.Atoi(strings.TrimSpace(x)); err == nil {
+				return n, nil
+			}
+		}
+		return nil, fmt.Errorf("%q is not an integer", fmt.Sprint(val))
+	}
+	if val == nil {
+		return "", nil
+	}
+	if _, ok := val.(map[string]any); ok {
+		return nil, errors.New("want a string, got a mapping")
+	}
+	return fmt.Sprint(val), nil
+}
+
+// check validates an already coerced value against enum, pattern, min and
+// max.
+func (v variable) check(val any) error {
+	switch x := val.(type) {
+	case int:
+		if v.Min != nil && x < *v.Min {
+			return fmt.Errorf("%d is less than the minimum %d", x, *v.Min)
+		}
+		if v.Max != nil && x > *v.Max {
+			return fmt.Errorf("%d is more than the maximum %d", x, *v.Max)
+		}
+		return v.checkItem(x)
+	case string:
+		if n := len([]rune(x)); v.Min != nil && n < *v.Min {
+			return fmt.Errorf("%s is shorter than %d characters", v.quote(x), *v.Min)
+		} else if v.Max != nil && n > *v.Max {
+			return fmt.Errorf("%s is longer than %d characters", v.quote(x), *v.Max)
+		}
+		return v.checkItem(x)
+	case []string:
+		if v.Min != nil && len(x) < *v.Min {
+			return fmt.Errorf("needs at least %d item(s), got %d", *v.Min, len(x))
+		}
+		if v.Max != nil && len(x) > *v.Max {
+			return fmt.Errorf("allows at most %d item(s), got %d", *v.Max, len(x))
+		}
+		for _, it := range x {
+			if err := v.checkItem(it); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (v variable) checkItem(val any) error {
+	if len(v.Enum) > 0 && !slices.Contains(v.choices(), fmt.Sprint(val)) {
+		return fmt.Errorf("%s is not one of %s", v.quote(fmt.Sprint(val)), strings.Join(v.choices(), ", "))
+	}
+	if v.Pattern != "" {
+		s := fmt.Sprint(val)
+		if re, err := regexp.Compile(v.Pattern); err == nil && !re.MatchString(s) {
+			return fmt.Errorf("%s does not match %s", v.quote(s), v.Pattern)
+		}
+	}
+	return nil
+}
+
+// quote formats a value for an error message. Secret values are never
+// echoed: prompt errors go to the terminal and gen errors into CI logs.
+func (v variable) quote(s string) string {
+	if v.Secret {
+		return "the value"
+	}
+	return strconv.Quote(s)
+}
+
+// choices returns the enum values as strings.
+func (v variable) choices() []string {
+	out := make([]string, len(v.Enum))
+	for i, e := range v.Enum {
+		out[i] = fmt.Sprint(e)
+	}
+	return out
+}
+
+// A when condition is a list of clauses joined by && and ||, where && binds
+// tighter. A clause is NAME (true when set, non-empty, not false and not 0),
+// !NAME, NAME == value or NAME != value; a list equals every item it
+// contains. Values may be quoted.
+func evalWhen(expr string, vars map[string]any) bool {
+	for _, alt := range strings.Split(expr, "||") {
+		all := true
+		for _, clause := range strings.Split(alt, "&&") {
+			if !evalClause(strings.TrimSpace(clause), vars) {
+				all = false
+				break
+			}
+		}
+		if all {
+			return true
+		}
+	}
+	return false
+}
+
+func evalClause(c string, vars map[string]any) bool {
+	for _, op := range []string{"==", "!="} {
+		if name, want, ok := strings.Cut(c, op); ok {
+			want = strings.TrimSpace(want)
+			if uq, err := strconv.Unquote(want); err == nil {
+				want = uq
+			} else {
+				want = strings.Trim(want, "'")
+			}
+			eq := false
+			switch x := vars[strings.TrimSpace(name)].(type) {
+			case []string:
+				eq = slices.Contains(x, want)
+			case nil:
+				eq = want == ""
+			default:
+				eq = fmt.Sprint(x) == want
+			}
+			return eq == (op == "==")
+		}
+	}
+	if name, ok := strings.CutPrefix(c, "!"); ok {
+		return !truthy(vars[strings.TrimSpace(name)])
+	}
+	return truthy(vars[c])
+}
+
+func truthy(val any) bool {
+	switch x := val.(type) {
+	case nil:
+		return false
+	case bool:
+		return x
+	case int:
+		return x != 0
+	case string:
+		return x != "" && x != "false" && x != "0"
+	case []string:
+		return len(x) > 0
+	}
+	return true
+}
+
+// whenRefs returns the variable names a when condition mentions.
+func whenRefs(expr string) []string {
+	var out []string
+	for _, alt := range strings.Split(expr, "||") {
+		for _, clause := range strings.Split(alt, "&&") {
+			c := strings.TrimSpace(clause)
+			if name, _, ok := strings.Cut(c, "=="); ok {
+				c = name
+			} else if name, _, ok := strings.Cut(c, "!="); ok {
+				c = name
+			}
+			c = strings.TrimSpace(strings.TrimPrefix(c, "!"))
+			if c != "" && !slices.Contains(out, c) {
+				out = append(out, c)
+			}
+		}
+	}
+	return out
+}
+
+// askFunc obtains a value for v interactively. ok is false when the user
+// gave none, so the default applies.
+type askFunc func(v variable, vars map[string]any) (val any, ok bool, err error)
+
+// resolveVariables applies the schema to vars in declaration order: values
+// are coerced and checked, missing ones are asked for when ask is set, and
+// defaults are filled in. Variables whose when condition is false are left
+// alone. Every problem is reported, not just the first.
+func resolveVariables(vs []variable, vars map[string]any, ask askFunc) error {
+	var errs []string
+	var missing []string
+	for _, v := range vs {
+		if v.When != "" && !evalWhen(v.When, vars) {
+			continue
+		}
+		val, set := vars[v.Name]
+		if set && val == nil {
+			set = false
+		}
+		if !set && ask != nil {
+			a, ok, err := ask(v, vars)
+			if err != nil {
+				return err
+			}
+			val, set = a, ok
+		}
+		if !set && v.Default != nil {
+			val, set = v.Default, true
+		}
+		if !set {
+			if v.Required {
+				missing = append(missing, v.Name)
+			}
+			continue
+		}
+		c, err := v.coerce(val)
+		if err == nil {
+			err = v.check(c)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", v.Name, err))
+			continue
+		}
+		if v.Required && (c == "" || (v.kind() == typeList && len(c.([]string)) == 0)) {
+			missing = append(missing, v.Name)
+			continue
+		}
+		vars[v.Name] = c
+	}
+	if len(missing) > 0 {
+		errs = append(errs, "missing required variable(s): "+strings.Join(missing, ", ")+" (pass them with --set NAME=value or --vars)")
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid variables:\n  %s", strings.Join(errs, "\n  "))
+	}
+	return nil
+}
+
+// blueprintVariables reads the variables declared by the manifest.yaml that
+// sits next to the template root in src's bundle or blueprint directory. ok
+// is false when there is none, as with bundles built before manifests were
+// included. Only the variables block is checked: gen must not reject a
+// published blueprint over, say, a non-SemVer version.
+func blueprintVariables(src, root string) (vs []variable, ok bool, err error) {
+	dir := src
+	if root != "" {
+		for range strings.Split(path.Clean(root), "/") {
+			dir = filepath.Dir(dir)
+		}
+	}
+	p := filepath.Join(dir, "manifest.yaml")
+	b, err := os.ReadFile(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	var m struct {
+		Variables []variable `yaml:"variables"`
+	}
+	if err := yaml.Unmarshal(b, &m); err != nil {
+		return nil, false, fmt.Errorf("%s: %w", p, err)
+	}
+	if err := checkVariables(m.Variables); err != nil {
+		return nil, false, fmt.Errorf("%s: %w", p, err)
+	}
+	return m.Variables, true, nil
+}
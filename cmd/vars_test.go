@@ -0,0 +1,397 @@
+/*
+ * // Copyright 2025 getDragon-dev
+ * // Licensed under the Apache License, Version 2.0 (the "License");
+ * // you may not use this file except in compliance with the License.
+ * // You may obtain a copy of the License at http://www.apache.org/licenses/LICENSE-2.0
+ * // Unless required by applicable law or agreed to in writing, software
+ * // distributed under the License is distributed on an "AS IS" BASIS,
+ * // WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * // See the License for the specific language governing permissions and
+ * // limitations under the License.
+ */
+
+package cmd
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBlueprintVariables(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "template")
+	if err := os.Mkdir(src, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, err := blueprintVariables(src, "template"); ok || err != nil {
+		t.Fatalf("no manifest: ok=%v err=%v", ok, err)
+	}
+
+	// Only the variables block matters; a non-SemVer version is fine.
+	write := func(s string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, "manifest.yaml"), []byte(s), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("name: old\nversion: \"1.0\"\nvariables:\n  - name: DB\n    default: sqlite-native\n")
+	vs, ok, err := blueprintVariables(src, "template")
+	if err != nil || !ok || len(vs) != 1 || vs[0].Name != "DB" {
+		t.Fatalf("got %+v, %v, %v", vs, ok, err)
+	}
+
+	write("variables:\n  - name: Port\n    type: int\n    default: high\n")
+	if _, _, err := blueprintVariables(src, "template"); err == nil {
+		t.Fatal("want an error for a bad default")
+	}
+
+	// A manifest that can't be read is not the same as none.
+	if err := os.Remove(filepath.Join(dir, "manifest.yaml")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "manifest.yaml"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, err := blueprintVariables(src, "template"); ok || err == nil {
+		t.Errorf("unreadable manifest: ok=%v err=%v; want an error", ok, err)
+	}
+}
+
+func TestSecretValuesNotEchoed(t *testing.T) {
+	one, ten := 1, 10
+	vs := []variable{
+		{Name: "Token", Secret: true, Pattern: "^tok_", Max: &ten},
+		{Name: "Pass", Secret: true, Min: &ten},
+		{Name: "Name", Pattern: "^[a-z]+$", Min: &one},
+	}
+	vars := map[string]any{"Token": "hunter2hunter2", "Pass": "hunter2", "Name": "Hunter2"}
+	err := resolveVariables(vs, vars, nil)
+	if err == nil {
+		t.Fatal("want errors")
+	}
+	if strings.Contains(err.Error(), "hunter2") {
+		t.Errorf("secret value in error:\n%v", err)
+	}
+	if !strings.Contains(err.Error(), `"Hunter2"`) {
+		t.Errorf("non-secret value should be quoted:\n%v", err)
+	}
+}
+
+func TestCheckVariables(t *testing.T) {
+	one, two := 1, 2
+	tests := []struct {
+		name string
+		vs   []variable
+		want []string // substrings of the error; none means valid
+	}{
+		{"valid", []variable{
+			{Name: "DB", Default: "sqlite", Enum: []any{"sqlite", "postgres"}},
+			{Name: "Port", Type: typeInt, Min: &one, Default: 8080},
+			{Name: "Tags", Type: typeList, Max: &two},
+			{Name: "DSN", Secret: true, When: "DB != sqlite"},
+		}, nil},
+		{"missing name", []variable{{}}, []string{"variable #1: name is required"}},
+		{"bad name", []variable{{Name: "1st"}}, []string{"variable 1st: name must be"}},
+		{"duplicate", []variable{{Name: "A"}, {Name: "A"}}, []string{"variable A: declared twice"}},
+		{"unknown type", []variable{{Name: "A", Type: "float"}}, []string{`unknown type "float"`}},
+		{"bad pattern", []variable{{Name: "A", Pattern: "("}}, []string{"variable A: pattern:"}},
+		{"min over max", []variable{{Name: "A", Type: typeInt, Min: &two, Max: &one}}, []string{"min 2 is greater than max 1"}},
+		{"bool with enum", []variable{{Name: "A", Type: typeBool, Enum: []any{true}}}, []string{"do not apply to bool"}},
+		{"secret enum", []variable{{Name: "A", Secret: true, Enum: []any{"x"}}}, []string{"only free-form string"}},
+		{"secret int", []variable{{Name: "A", Type: typeInt, Secret: true}}, []string{"only free-form string"}},
+		{"bad enum value", []variable{{Name: "A", Type: typeInt, Enum: []any{"x"}}}, []string{`enum value x: "x" is not an integer`}},
+		{"bad default type", []variable{{Name: "A", Type: typeBool, Default: "maybe"}}, []string{"default:", "is not a bool"}},
+		{"default outside enum", []variable{{Name: "A", Enum: []any{"x"}, Default: "y"}}, []string{`default: "y" is not one of x`}},
+		{"when before declaration", []variable{{Name: "A", When: "B"}, {Name: "B"}}, []string{"when refers to B"}},
+		{"when to itself", []variable{{Name: "A", When: "A == x"}}, []string{"when refers to A"}},
+		{"every problem", []variable{{Name: "1st"}, {Name: "B", Type: "float"}}, []string{"variable 1st", "variable B"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkVariables(tt.vs)
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("want an error")
+			}
+			for _, w := range tt.want {
+				if !strings.Contains(err.Error(), w) {
+					t.Errorf("error %q does not mention %q", err, w)
+				}
+			}
+		})
+	}
+}
+
+func TestCoerce(t *testing.T) {
+	tests := []struct {
+		typ  string
+		in   any
+		want any
+		err  string
+	}{
+		{typeString, "x", "x", ""},
+		{typeString, 42, "42", ""},
+		{typeString, nil, "", ""},
+		{typeString, map[string]any{"a": 1}, nil, "got a mapping"},
+		{typeString, []any{"a"}, nil, "want a string, got a list"},
+		{typeBool, true, true, ""},
+		{typeBool, " Yes ", true, ""},
+		{typeBool, "off", false, ""},
+		{typeBool, "0", false, ""},
+		{typeBool, "maybe", nil, `"maybe" is not a bool`},
+		{typeBool, 1, nil, "is not a bool"},
+		{typeInt, 7, 7, ""},
+		{typeInt, int64(7), 7, ""},
+		{typeInt, uint64(7), 7, ""},
+		{typeInt, float64(7), 7, ""},
+		{typeInt, " 42 ", 42, ""},
+		{typeInt, 1.5, nil, `"1.5" is not an integer`},
+		{typeInt, "ten", nil, "is not an integer"},
+		{typeInt, uint64(1 << 63), nil, "is not an integer"},
+		{typeList, "a, b,,c", []string{"a", "b", "c"}, ""},
+		{typeList, "", []string{}, ""},
+		{typeList, []any{"a", 1}, []string{"a", "1"}, ""},
+		{typeList, []string{"a"}, []string{"a"}, ""},
+		{typeList, 5, []string{"5"}, ""},
+		{typeList, []any{map[string]any{}}, nil, "got a mapping"},
+	}
+	for _, tt := range tests {
+		got, err := variable{Name: "V", Type: tt.typ}.coerce(tt.in)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s %#v: err = %v, want %q", tt.typ, tt.in, err, tt.err)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s %#v = %#v, %v; want %#v", tt.typ, tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	two, three := 2, 3
+	tests := []struct {
+		name string
+		v    variable
+		val  any
+		err  string
+	}{
+		{"int in range", variable{Min: &two, Max: &three}, 3, ""},
+		{"int below min", variable{Min: &two}, 1, "1 is less than the minimum 2"},
+		{"int above max", variable{Max: &three}, 4, "4 is more than the maximum 3"},
+		{"int enum", variable{Enum: []any{1, 2}}, 3, `"3" is not one of 1, 2`},
+		{"string length", variable{Min: &two, Max: &three}, "héé", ""},
+		{"string too short", variable{Min: &two}, "a", `"a" is shorter than 2 characters`},
+		{"string too long", variable{Max: &two}, "abc", `"abc" is longer than 2 characters`},
+		{"string enum", variable{Enum: []any{"a", "b"}}, "b", ""},
+		{"string not in enum", variable{Enum: []any{"a", "b"}}, "c", `"c" is not one of a, b`},
+		{"string pattern", variable{Pattern: "^[a-z]+$"}, "abc", ""},
+		{"string pattern mismatch", variable{Pattern: "^[a-z]+$"}, "ABC", `"ABC" does not match ^[a-z]+$`},
+		{"list count", variable{Min: &two}, []string{"a", "b"}, ""},
+		{"list too few", variable{Min: &two}, []string{"a"}, "needs at least 2 item(s), got 1"},
+		{"list too many", variable{Max: &two}, []string{"a", "b", "c"}, "allows at most 2 item(s), got 3"},
+		{"list item enum", variable{Enum: []any{"a"}}, []string{"a", "b"}, `"b" is not one of a`},
+		{"bool", variable{}, true, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.v.check(tt.val)
+			if tt.err == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.err {
+				t.Fatalf("err = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestEvalWhen(t *testing.T) {
+	vars := map[string]any{
+		"DB":     "postgres",
+		"Auth":   true,
+		"Off":    false,
+		"Zero":   0,
+		"Port":   8080,
+		"Empty":  "",
+		"Falsey": "false",
+		"Tags":   []string{"api", "web"},
+		"None":   []string{},
+	}
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{"Auth", true},
+		{"Off", false},
+		{"Zero", false},
+		{"Port", true},
+		{"Empty", false},
+		{"Falsey", false},
+		{"Unset", false},
+		{"None", false},
+		{"Tags", true},
+		{"!Auth", false},
+		{"!Unset", true},
+		{"! Off", true},
+		{"DB == postgres", true},
+		{`DB == "postgres"`, true},
+		{"DB == 'postgres'", true},
+		{"DB != postgres", false},
+		{"DB==sqlite", false},
+		{"Port == 8080", true},
+		{"Tags == web", true},
+		{"Tags != web", false},
+		{"Tags == cli", false},
+		{"Unset == ''", true},
+		{`Unset != ""`, false},
+		{"Auth && DB == postgres", true},
+		{"Auth && Off", false},
+		{"Off || DB == postgres", true},
+		{"Off || Zero", false},
+		{"Off && Auth || Tags == api", true},
+		{"Auth || Off && Zero", true},
+	}
+	for _, tt := range tests {
+		if got := evalWhen(tt.expr, vars); got != tt.want {
+			t.Errorf("evalWhen(%q) = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestWhenRefs(t *testing.T) {
+	tests := []struct {
+		expr string
+		want []string
+	}{
+		{"Auth", []string{"Auth"}},
+		{"!Auth", []string{"Auth"}},
+		{"DB != sqlite && Auth", []string{"DB", "Auth"}},
+		{"DB == a || DB == b || !Tags", []string{"DB", "Tags"}},
+		{"DB==x&&Port!=1", []string{"DB", "Port"}},
+		{"  ", nil},
+	}
+	for _, tt := range tests {
+		if got := whenRefs(tt.expr); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("whenRefs(%q) = %q, want %q", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestResolveVariables(t *testing.T) {
+	one := 1
+	schema := []variable{
+		{Name: "DB", Default: "sqlite", Enum: []any{"sqlite", "postgres"}},
+		{Name: "DSN", Required: true, When: "DB != sqlite"},
+		{Name: "Port", Type: typeInt, Default: 8080},
+		{Name: "Debug", Type: typeBool},
+		{Name: "Tags", Type: typeList, Min: &one},
+		{Name: "Owner", Required: true},
+	}
+	tests := []struct {
+		name string
+		vars map[string]any
+		ask  askFunc
+		want map[string]any
+		err  []string // substrings of the error
+	}{
+		{
+			name: "defaults and coercion",
+			vars: map[string]any{"Owner": "me", "Port": "9000", "Debug": "yes", "Tags": "a, b"},
+			want: map[string]any{"DB": "sqlite", "Owner": "me", "Port": 9000, "Debug": true, "Tags": []string{"a", "b"}},
+		},
+		{
+			name: "when skips the variable",
+			vars: map[string]any{"Owner": "me", "DSN": 42},
+			// DSN is neither checked nor coerced while DB is sqlite.
+			want: map[string]any{"DB": "sqlite", "Owner": "me", "Port": 8080, "DSN": 42},
+		},
+		{
+			name: "when sees earlier defaults",
+			vars: map[string]any{"DB": "postgres", "Owner": "me", "DSN": "pg://"},
+			want: map[string]any{"DB": "postgres", "Owner": "me", "Port": 8080, "DSN": "pg://"},
+		},
+		{
+			name: "nil counts as unset",
+			vars: map[string]any{"Owner": "me", "Port": nil},
+			want: map[string]any{"DB": "sqlite", "Owner": "me", "Port": 8080},
+		},
+		{
+			name: "all missing listed together",
+			vars: map[string]any{"DB": "postgres"},
+			err:  []string{"missing required variable(s): DSN, Owner"},
+		},
+		{
+			name: "empty required value is missing",
+			vars: map[string]any{"Owner": ""},
+			err:  []string{"missing required variable(s): Owner"},
+		},
+		{
+			name: "every problem reported",
+			vars: map[string]any{"DB": "mysql", "Port": "high", "Tags": ""},
+			err: []string{
+				`DB: "mysql" is not one of sqlite, postgres`,
+				`Port: "high" is not an integer`,
+				"Tags: needs at least 1 item(s), got 0",
+				"missing required variable(s): DSN, Owner",
+			},
+		},
+		{
+			name: "ask fills gaps",
+			vars: map[string]any{"DB": "postgres"},
+			ask: func(v variable, vars map[string]any) (any, bool, error) {
+				switch v.Name {
+				case "DSN", "Owner":
+					return "x", true, nil
+				case "Tags":
+					return []string{"t"}, true, nil
+				}
+				return nil, false, nil
+			},
+			want: map[string]any{"DB": "postgres", "DSN": "x", "Owner": "x", "Port": 8080, "Tags": []string{"t"}},
+		},
+		{
+			name: "ask errors stop resolution",
+			vars: map[string]any{},
+			ask: func(v variable, vars map[string]any) (any, bool, error) {
+				return nil, false, errors.New("interrupted")
+			},
+			err: []string{"interrupted"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := resolveVariables(schema, tt.vars, tt.ask)
+			if len(tt.err) > 0 {
+				if err == nil {
+					t.Fatal("want an error")
+				}
+				for _, w := range tt.err {
+					if !strings.Contains(err.Error(), w) {
+						t.Errorf("error does not mention %q:\n%v", w, err)
+					}
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(tt.vars, tt.want) {
+				t.Errorf("vars = %#v\nwant %#v", tt.vars, tt.want)
+			}
+		})
+	}
+}
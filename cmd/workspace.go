@@ -0,0 +1,98 @@
+/*
+ * // Copyright 2025 getDragon-dev
+ * // Licensed under the Apache License, Version 2.0 (the "License");
+ * // you may not use this file except in compliance with the License.
+ * // You may obtain a copy of the License at http://www.apache.org/licenses/LICENSE-2.0
+ * // Unless required by applicable law or agreed to in writing, software
+ * // distributed under the License is distributed on an "AS IS" BASIS,
+ * // WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * // See the License for the specific language governing permissions and
+ * // limitations under the License.
+ */
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// legacyBlueprintRoot is where gen looked for templates before search roots
+// were configurable; it is still tried, last, when none are configured.
+const legacyBlueprintRoot = "../dragon-blueprints"
+
+// expandPath resolves p against base, expanding a leading "~/".
+func expandPath(p, base string) string {
+	if rest, ok := strings.CutPrefix(p, "~/"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, rest)
+		}
+	}
+	if filepath.IsAbs(p) || base == "" {
+		return p
+	}
+	return filepath.Join(base, p)
+}
+
+// localTemplateCandidates returns every directory that may hold bp's
+// template, in search order: the configured roots (DRAGON_BLUEPRINT_PATH,
+// then .dragon.yaml, then the user config), then a local registry's own
+// directory, since blueprint paths in an index are usually relative to it,
+// then legacyBlueprintRoot when no roots are configured. The path and name
+// come from the index, so one that would leave its root is refused.
+func localTemplateCandidates(bp resolvedBlueprint, root string) ([]string, error) {
+	p := bp.Path
+	if p == "" {
+		p = bp.Name
+	}
+	rel, err := cleanName(p)
+	if err != nil {
+		return nil, fmt.Errorf("blueprint %s: path %q must be relative and stay inside its search root", bp.Name, p)
+	}
+	var roots []string
+	if cfg, _, err := effectiveConfig(); err == nil {
+		roots = slices.Clone(cfg.BlueprintPaths)
+	}
+	configured := len(roots) > 0
+	if bp.Source != "" && !isRemote(bp.Source) && !isGit(bp.Source) && !isOCI(bp.Source) {
+		roots = append(roots, filepath.Dir(bp.Source))
+	}
+	if !configured {
+		roots = append(roots, legacyBlueprintRoot)
+	}
+	var out []string
+	for _, r := range roots {
+		c := filepath.Join(r, filepath.FromSlash(rel), filepath.FromSlash(root))
+		if !within(r, c) {
+			return nil, fmt.Errorf("blueprint %s: %s is outside %s", bp.Name, c, r)
+		}
+		if !slices.Contains(out, c) {
+			out = append(out, c)
+		}
+	}
+	return out, nil
+}
+
+// within reports whether p is dir or below it.
+func within(dir, p string) bool {
+	rel, err := filepath.Rel(dir, p)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// findLocalTemplate returns the first candidate directory that exists,
+// along with every path it tried.
+func findLocalTemplate(bp resolvedBlueprint, root string) (string, []string, error) {
+	tried, err := localTemplateCandidates(bp, root)
+	if err != nil {
+		return "", nil, err
+	}
+	for _, c := range tried {
+		if fi, err := os.Stat(c); err == nil && fi.IsDir() {
+			return c, tried, nil
+		}
+	}
+	return "", tried, nil
+}
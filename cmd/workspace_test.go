@@ -0,0 +1,108 @@
+/*
+ * // Copyright 2025 getDragon-dev
+ * // Licensed under the Apache License, Version 2.0 (the "License");
+ * // you may not use this file except in compliance with the License.
+ * // You may obtain a copy of the License at http://www.apache.org/licenses/LICENSE-2.0
+ * // Unless required by applicable law or agreed to in writing, software
+ * // distributed under the License is distributed on an "AS IS" BASIS,
+ * // WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * // See the License for the specific language governing permissions and
+ * // limitations under the License.
+ */
+
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	corereg "github.com/getDragon-dev/dragon-core/registry"
+)
+
+func candidates(t *testing.T, bp resolvedBlueprint) []string {
+	t.Helper()
+	c, err := localTemplateCandidates(bp, "template")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestLocalTemplateCandidates(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	userCfg := filepath.Join(dir, "config.yaml")
+	t.Setenv("DRAGON_CONFIG", userCfg)
+	t.Setenv("DRAGON_BLUEPRINT_PATH", "")
+	proj := filepath.Join(dir, "proj")
+	if err := os.Mkdir(proj, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(proj)
+	write := func(p, s string) {
+		t.Helper()
+		if err := os.WriteFile(p, []byte(s), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(userCfg, "version: 1\n")
+
+	reg := filepath.Join(dir, "reg")
+	local := resolvedBlueprint{Blueprint: corereg.Blueprint{Name: "api", Path: "services/api"}, Source: filepath.Join(reg, "registry.json")}
+	remote := resolvedBlueprint{Blueprint: corereg.Blueprint{Name: "api"}, Source: "https://example.com/registry.json"}
+	cand := func(root string, rel ...string) string {
+		return filepath.Join(append([]string{root}, append(rel, "template")...)...)
+	}
+
+	// Nothing configured: the registry's directory, then the legacy root.
+	if got, want := candidates(t, local), []string{cand(reg, "services", "api"), cand(legacyBlueprintRoot, "services", "api")}; !reflect.DeepEqual(got, want) {
+		t.Errorf("local registry:\n got %q\nwant %q", got, want)
+	}
+	if got, want := candidates(t, remote), []string{cand(legacyBlueprintRoot, "api")}; !reflect.DeepEqual(got, want) {
+		t.Errorf("remote registry:\n got %q\nwant %q", got, want)
+	}
+
+	// An index cannot point gen outside the search roots.
+	for _, p := range []string{"../../etc", "/etc", "services/../../x"} {
+		bad := local
+		bad.Path = p
+		if got, err := localTemplateCandidates(bad, "template"); err == nil {
+			t.Errorf("path %q: candidates %q, want an error", p, got)
+		}
+	}
+	escape := remote
+	escape.Name = ".."
+	if _, _, err := findLocalTemplate(escape, "template"); err == nil {
+		t.Error("name \"..\": want an error")
+	}
+
+	// Configured roots replace the legacy one: environment, project, user,
+	// then the registry.
+	write(userCfg, "version: 1\nblueprint_paths: [user, "+reg+"]\n")
+	write(filepath.Join(proj, projectConfigName), "blueprint_paths: [shared]\n")
+	t.Setenv("DRAGON_BLUEPRINT_PATH", filepath.Join(dir, "env"))
+	want := []string{
+		cand(filepath.Join(dir, "env"), "services", "api"),
+		cand(filepath.Join(proj, "shared"), "services", "api"),
+		cand(filepath.Join(dir, "user"), "services", "api"),
+		cand(reg, "services", "api"), // listed once
+	}
+	if got := candidates(t, local); !reflect.DeepEqual(got, want) {
+		t.Errorf("configured:\n got %q\nwant %q", got, want)
+	}
+
+	// The first existing directory wins; everything is reported as tried.
+	if src, tried, err := findLocalTemplate(local, "template"); err != nil || src != "" || !reflect.DeepEqual(tried, want) {
+		t.Errorf("nothing on disk: %q, tried %q", src, tried)
+	}
+	for _, c := range want[2:] {
+		if err := os.MkdirAll(c, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if src, tried, err := findLocalTemplate(local, "template"); err != nil || src != want[2] || !reflect.DeepEqual(tried, want) {
+		t.Errorf("found %q, tried %q; want %q", src, tried, want[2])
+	}
+}